@@ -0,0 +1,213 @@
+// oemctl imports and exports the switch inventory of an OEM server.
+//
+//	oemctl [-url URL] [-user U -password P] import [-format F] [-dry-run] FILE
+//	oemctl [-url URL] [-user U -password P] export [-format F] [-o FILE]
+//
+// The token in OEM_TOKEN is used when set, otherwise it logs in with -user
+// and -password.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func main() {
+	baseURL := flag.String("url", envOr("OEM_URL", "http://localhost:8080"), "OEM server URL")
+	user := flag.String("user", os.Getenv("OEM_USER"), "username, if OEM_TOKEN is not set")
+	password := flag.String("password", os.Getenv("OEM_PASSWORD"), "password, if OEM_TOKEN is not set")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: oemctl [flags] import [-format csv|json|yaml] [-dry-run] FILE")
+		fmt.Fprintln(os.Stderr, "       oemctl [flags] export [-format csv|json|yaml] [-o FILE]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	c := &client{base: strings.TrimRight(*baseURL, "/"), token: os.Getenv("OEM_TOKEN")}
+	if c.token == "" {
+		if err := c.login(*user, *password); err != nil {
+			fatal(err)
+		}
+	}
+
+	var err error
+	switch flag.Arg(0) {
+	case "import":
+		err = runImport(c, flag.Args()[1:])
+	case "export":
+		err = runExport(c, flag.Args()[1:])
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fatal(err)
+	}
+}
+
+func runImport(c *client, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	format := fs.String("format", "", "file format, by default taken from the file extension")
+	dryRun := fs.Bool("dry-run", false, "validate and report without applying")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return fmt.Errorf("import needs exactly one file")
+	}
+
+	path := fs.Arg(0)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if *format == "" {
+		*format = strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+	}
+
+	query := url.Values{"format": {*format}}
+	if *dryRun {
+		query.Set("dry_run", "true")
+	}
+	status, body, err := c.do("POST", "/api/v1/inventory/import?"+query.Encode(), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+
+	var report struct {
+		Error   string         `json:"error"`
+		DryRun  bool           `json:"dry_run"`
+		Applied bool           `json:"applied"`
+		Summary map[string]int `json:"summary"`
+		Rows    []struct {
+			Row       int      `json:"row"`
+			IPAddress string   `json:"ip_address"`
+			Action    string   `json:"action"`
+			SwitchID  int      `json:"switch_id"`
+			Changes   []string `json:"changes"`
+			Errors    []string `json:"errors"`
+		} `json:"rows"`
+	}
+	if err := json.Unmarshal(body, &report); err != nil {
+		return fmt.Errorf("unexpected response (%d): %s", status, body)
+	}
+	if report.Error != "" {
+		return fmt.Errorf("%s", report.Error)
+	}
+
+	for _, row := range report.Rows {
+		line := fmt.Sprintf("row %d\t%s\t%s", row.Row, row.IPAddress, row.Action)
+		if len(row.Changes) > 0 {
+			line += "\t" + strings.Join(row.Changes, ",")
+		}
+		if len(row.Errors) > 0 {
+			line += "\t" + strings.Join(row.Errors, "; ")
+		}
+		fmt.Println(line)
+	}
+	fmt.Printf("create %d, update %d, unchanged %d, error %d\n",
+		report.Summary["create"], report.Summary["update"], report.Summary["unchanged"], report.Summary["error"])
+
+	switch {
+	case report.Summary["error"] > 0:
+		return fmt.Errorf("nothing imported, fix the rows above")
+	case report.DryRun:
+		fmt.Println("dry run, nothing applied")
+	}
+	return nil
+}
+
+func runExport(c *client, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	format := fs.String("format", "", "csv, json or yaml; by default taken from -o, else json")
+	out := fs.String("o", "", "output file, stdout if empty")
+	fs.Parse(args)
+
+	if *format == "" {
+		*format = strings.TrimPrefix(strings.ToLower(filepath.Ext(*out)), ".")
+		if *format == "" {
+			*format = "json"
+		}
+	}
+
+	status, body, err := c.do("GET", "/api/v1/inventory/export?format="+url.QueryEscape(*format), nil)
+	if err != nil {
+		return err
+	}
+	if status != http.StatusOK {
+		return fmt.Errorf("export failed (%d): %s", status, body)
+	}
+	if *out == "" {
+		_, err = os.Stdout.Write(body)
+		return err
+	}
+	return os.WriteFile(*out, body, 0o644)
+}
+
+type client struct {
+	base  string
+	token string
+}
+
+func (c *client) login(user, password string) error {
+	if user == "" || password == "" {
+		return fmt.Errorf("set OEM_TOKEN or pass -user and -password")
+	}
+	creds, _ := json.Marshal(map[string]string{"username": user, "password": password})
+	status, body, err := c.do("POST", "/api/v1/auth/login", bytes.NewReader(creds))
+	if err != nil {
+		return err
+	}
+	var resp struct {
+		Token string `json:"token"`
+		Error string `json:"error"`
+	}
+	json.Unmarshal(body, &resp)
+	if status != http.StatusOK || resp.Token == "" {
+		return fmt.Errorf("login failed: %s", resp.Error)
+	}
+	c.token = resp.Token
+	return nil
+}
+
+func (c *client) do(method, path string, body io.Reader) (int, []byte, error) {
+	req, err := http.NewRequest(method, c.base+path, body)
+	if err != nil {
+		return 0, nil, err
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	if strings.HasPrefix(path, "/api/v1/auth/") {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	return resp.StatusCode, data, err
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, "oemctl:", err)
+	os.Exit(1)
+}
@@ -1,11 +1,12 @@
 package main
 
 import (
-	"log"
+	"log/slog"
 	"os"
 
 	"github.com/JarvisTchibClawBot/OpenExtremeManagement/internal/api"
 	"github.com/JarvisTchibClawBot/OpenExtremeManagement/internal/config"
+	"github.com/JarvisTchibClawBot/OpenExtremeManagement/internal/logging"
 	"github.com/joho/godotenv"
 )
 
@@ -21,6 +22,9 @@ func main() {
 	// Load configuration
 	cfg := config.Load()
 
+	// Structured logging; the standard logger is routed through it too
+	slog.SetDefault(logging.New(os.Stdout, cfg.LogLevel, cfg.LogFormat))
+
 	// Initialize and start API server
 	server := api.NewServer(cfg)
 
@@ -29,8 +33,9 @@ func main() {
 		port = "8080"
 	}
 
-	log.Printf("🚀 OpenExtremeManagement %s (built %s) starting on port %s", Version, BuildDate, port)
+	slog.Info("OpenExtremeManagement starting", "version", Version, "build_date", BuildDate, "port", port)
 	if err := server.Run(":" + port); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+		slog.Error("failed to start server", "error", err)
+		os.Exit(1)
 	}
 }
@@ -0,0 +1,138 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/JarvisTchibClawBot/OpenExtremeManagement/internal/logging"
+	"github.com/gin-gonic/gin"
+)
+
+// Number of audit entries kept in memory
+const auditLogSize = 10000
+
+// Most audit entries returned by one listing
+const maxAuditPage = 1000
+
+// AuditEntry records an action taken against a switch
+type AuditEntry struct {
+	ID        int         `json:"id"`
+	Timestamp time.Time   `json:"timestamp"`
+	User      string      `json:"user"`
+	Role      string      `json:"role"`
+	Action    string      `json:"action"`
+	SwitchID  int         `json:"switch_id,omitempty"`
+	RequestID string      `json:"request_id,omitempty"`
+	Result    string      `json:"result"` // success, failure, denied
+	Details   interface{} `json:"details,omitempty"`
+}
+
+// AuditLog is a bounded in-memory audit trail
+type AuditLog struct {
+	mu      sync.RWMutex
+	entries []AuditEntry
+	nextID  int
+}
+
+func NewAuditLog() *AuditLog {
+	return &AuditLog{nextID: 1}
+}
+
+// record stores an entry and writes it to the log so it survives restarts
+// in the log pipeline
+func (a *AuditLog) record(ctx context.Context, entry AuditEntry) AuditEntry {
+	a.mu.Lock()
+	entry.ID = a.nextID
+	entry.Timestamp = time.Now()
+	entry.RequestID = logging.RequestID(ctx)
+	a.nextID++
+	a.entries = append(a.entries, entry)
+	if len(a.entries) > auditLogSize {
+		a.entries = a.entries[len(a.entries)-auditLogSize:]
+	}
+	a.mu.Unlock()
+
+	logging.FromContext(ctx).Info("audit",
+		"audit_id", entry.ID,
+		"user", entry.User,
+		"action", entry.Action,
+		"audit_switch_id", entry.SwitchID,
+		"result", entry.Result,
+		"details", scrubDetails(entry.Details),
+	)
+
+	return entry
+}
+
+// scrubDetails flattens details to JSON so credentials nested inside are
+// masked too; the log handler only sees top-level attributes
+func scrubDetails(details interface{}) string {
+	if details == nil {
+		return ""
+	}
+	data, err := json.Marshal(details)
+	if err != nil {
+		return ""
+	}
+	return logging.Scrub(string(data))
+}
+
+// audit records an action performed by the authenticated user of c
+func (s *Server) audit(c *gin.Context, action string, switchID int, result string, details interface{}) {
+	s.auditLog.record(c.Request.Context(), AuditEntry{
+		User:     c.GetString("username"),
+		Role:     c.GetString("role"),
+		Action:   action,
+		SwitchID: switchID,
+		Result:   result,
+		Details:  details,
+	})
+}
+
+// listAudit returns audit entries, newest first, optionally filtered by
+// switch_id, user and action
+func (s *Server) listAudit(c *gin.Context) {
+	var switchID int
+	if idStr := c.Query("switch_id"); idStr != "" {
+		if _, err := fmt.Sscanf(idStr, "%d", &switchID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid switch ID"})
+			return
+		}
+	}
+
+	limit := 100
+	if limitStr := c.Query("limit"); limitStr != "" {
+		if _, err := fmt.Sscanf(limitStr, "%d", &limit); err != nil || limit <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+			return
+		}
+	}
+
+	user := c.Query("user")
+	action := c.Query("action")
+
+	s.auditLog.mu.RLock()
+	defer s.auditLog.mu.RUnlock()
+
+	limit = min(limit, maxAuditPage, len(s.auditLog.entries))
+	entries := make([]AuditEntry, 0, limit)
+	for i := len(s.auditLog.entries) - 1; i >= 0 && len(entries) < limit; i-- {
+		entry := s.auditLog.entries[i]
+		if switchID != 0 && entry.SwitchID != switchID {
+			continue
+		}
+		if user != "" && entry.User != user {
+			continue
+		}
+		if action != "" && entry.Action != action {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"entries": entries})
+}
@@ -100,3 +100,33 @@ func (s *Server) authMiddleware() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// queryTokenAuth lets a route take the token from ?access_token, for
+// EventSource, which cannot set headers. Tokens in URLs end up in proxy
+// logs and browser history, so only the event stream uses it. Must run
+// before authMiddleware.
+func (s *Server) queryTokenAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetHeader("Authorization") == "" && c.Query("access_token") != "" {
+			c.Request.Header.Set("Authorization", "Bearer "+c.Query("access_token"))
+		}
+		c.Next()
+	}
+}
+
+// requireRole rejects requests from users whose role is not in roles.
+// Must run after authMiddleware.
+func (s *Server) requireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role := c.GetString("role")
+		for _, r := range roles {
+			if role == r {
+				c.Next()
+				return
+			}
+		}
+
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		c.Abort()
+	}
+}
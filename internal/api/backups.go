@@ -0,0 +1,230 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	backupInterval      = time.Hour
+	maxBackupsPerSwitch = 20
+)
+
+// ConfigBackup is a copy of a switch's running configuration
+type ConfigBackup struct {
+	ID        int       `json:"id"`
+	SwitchID  int       `json:"switch_id"`
+	TakenAt   time.Time `json:"taken_at"`
+	CheckedAt time.Time `json:"checked_at"` // Last time the switch still had this config
+	Trigger   string    `json:"trigger"`    // scheduled, manual, pre-change, post-change
+	SHA256    string    `json:"sha256"`
+	Size      int       `json:"size"`
+	Config    string    `json:"config,omitempty"`
+}
+
+// Lines returns the configuration lines, without comments and blank lines
+func (b *ConfigBackup) Lines() []string {
+	var lines []string
+	for _, line := range strings.Split(b.Config, "\n") {
+		line = strings.TrimRight(line, " \t\r")
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// BackupStore keeps the most recent backups of each switch, oldest first
+type BackupStore struct {
+	mu          sync.RWMutex
+	backups     map[int][]*ConfigBackup
+	lastAttempt map[int]time.Time
+	nextID      int
+}
+
+func NewBackupStore() *BackupStore {
+	return &BackupStore{
+		backups:     make(map[int][]*ConfigBackup),
+		lastAttempt: make(map[int]time.Time),
+		nextID:      1,
+	}
+}
+
+// claimScheduled reports whether the scheduled backup of a switch is due
+// and, if so, records the attempt so a failing switch is not retried on
+// every sync
+func (bs *BackupStore) claimScheduled(switchID int) bool {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	if time.Since(bs.lastAttempt[switchID]) < backupInterval {
+		return false
+	}
+	bs.lastAttempt[switchID] = time.Now()
+	return true
+}
+
+// add stores config as a new backup unless it is identical to the latest
+// one, in which case that backup is marked as checked. It returns a copy
+// of the stored backup.
+func (bs *BackupStore) add(switchID int, config, trigger string) *ConfigBackup {
+	sum := sha256.Sum256([]byte(config))
+	hash := hex.EncodeToString(sum[:])
+	now := time.Now()
+
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+
+	bs.lastAttempt[switchID] = now
+	history := bs.backups[switchID]
+	if n := len(history); n > 0 && history[n-1].SHA256 == hash {
+		history[n-1].CheckedAt = now
+		latest := *history[n-1]
+		return &latest
+	}
+
+	backup := &ConfigBackup{
+		ID:        bs.nextID,
+		SwitchID:  switchID,
+		TakenAt:   now,
+		CheckedAt: now,
+		Trigger:   trigger,
+		SHA256:    hash,
+		Size:      len(config),
+		Config:    config,
+	}
+	bs.nextID++
+
+	history = append(history, backup)
+	if len(history) > maxBackupsPerSwitch {
+		history = history[len(history)-maxBackupsPerSwitch:]
+	}
+	bs.backups[switchID] = history
+	stored := *backup
+	return &stored
+}
+
+// latest returns a copy of the newest backup of a switch, or nil
+func (bs *BackupStore) latest(switchID int) *ConfigBackup {
+	bs.mu.RLock()
+	defer bs.mu.RUnlock()
+	history := bs.backups[switchID]
+	if len(history) == 0 {
+		return nil
+	}
+	latest := *history[len(history)-1]
+	return &latest
+}
+
+// get returns a copy of one backup of a switch, or nil
+func (bs *BackupStore) get(switchID, backupID int) *ConfigBackup {
+	bs.mu.RLock()
+	defer bs.mu.RUnlock()
+	for _, b := range bs.backups[switchID] {
+		if b.ID == backupID {
+			found := *b
+			return &found
+		}
+	}
+	return nil
+}
+
+func (bs *BackupStore) forgetSwitch(id int) {
+	bs.mu.Lock()
+	delete(bs.backups, id)
+	delete(bs.lastAttempt, id)
+	bs.mu.Unlock()
+}
+
+// backupConfig reads the running configuration of sw and stores it
+func (s *Server) backupConfig(ctx context.Context, sw *Switch, trigger string) (*ConfigBackup, error) {
+	if err := s.ensureAuthenticated(ctx, sw); err != nil {
+		return nil, fmt.Errorf("authentication failed: %w", err)
+	}
+
+	execution, err := s.runCLI(ctx, sw, []string{"show running-config"})
+	if err != nil {
+		return nil, err
+	}
+	if len(execution.Commands) != 1 || !execution.Succeeded() {
+		return nil, fmt.Errorf("show running-config failed")
+	}
+
+	return s.backups.add(sw.ID, execution.Commands[0].Output, trigger), nil
+}
+
+// listBackups returns the backup history of a switch, newest first,
+// without the configuration text
+func (s *Server) listBackups(c *gin.Context) {
+	sw, ok := s.lookupSwitch(c)
+	if !ok {
+		return
+	}
+
+	s.backups.mu.RLock()
+	history := s.backups.backups[sw.ID]
+	backups := make([]ConfigBackup, 0, len(history))
+	for i := len(history) - 1; i >= 0; i-- {
+		b := *history[i]
+		b.Config = ""
+		backups = append(backups, b)
+	}
+	s.backups.mu.RUnlock()
+
+	c.JSON(http.StatusOK, gin.H{"backups": backups})
+}
+
+func (s *Server) createBackup(c *gin.Context) {
+	sw, ok := s.lookupSwitch(c)
+	if !ok {
+		return
+	}
+
+	backup, err := s.backupConfig(switchContext(c.Request.Context(), sw, "backup"), sw, "manual")
+	if err != nil {
+		s.audit(c, "config.backup", sw.ID, "failure", gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Backup failed: " + err.Error()})
+		return
+	}
+
+	s.audit(c, "config.backup", sw.ID, "success", gin.H{"backup_id": backup.ID})
+	c.JSON(http.StatusCreated, gin.H{"backup": backup})
+}
+
+// getBackup returns one backup, or the raw configuration with ?format=text
+func (s *Server) getBackup(c *gin.Context) {
+	sw, ok := s.lookupSwitch(c)
+	if !ok {
+		return
+	}
+
+	var backupID int
+	if _, err := fmt.Sscanf(c.Param("backupId"), "%d", &backupID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid backup ID"})
+		return
+	}
+
+	backup := s.backups.get(sw.ID, backupID)
+	if backup == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Backup not found"})
+		return
+	}
+
+	if c.Query("format") == "text" {
+		filename := fmt.Sprintf("switch-%d-backup-%d.cfg", sw.ID, backup.ID)
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+		c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(backup.Config))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"backup": backup})
+}
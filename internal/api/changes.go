@@ -0,0 +1,673 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/JarvisTchibClawBot/OpenExtremeManagement/internal/logging"
+	"github.com/gin-gonic/gin"
+)
+
+// Change plan states
+const (
+	ChangePendingApproval = "pending_approval"
+	ChangeApproved        = "approved"
+	ChangeRejected        = "rejected"
+	ChangeRunning         = "running"
+	ChangeCompleted       = "completed"
+	ChangeFailed          = "failed"
+	ChangeCancelled       = "cancelled"
+	ChangeExpired         = "expired"
+)
+
+// Change step types
+const (
+	StepCLI    = "cli"
+	StepPort   = "port"
+	StepSystem = "system"
+)
+
+const (
+	maxChangeSteps        = 200
+	changeSchedulerPeriod = 15 * time.Second
+)
+
+var portNamePattern = regexp.MustCompile(`^\d+/\d+$`)
+
+// PortEdit changes the admin state and/or description of one port
+type PortEdit struct {
+	Port        string  `json:"port"`
+	AdminStatus string  `json:"admin_status,omitempty"` // up or down
+	Description *string `json:"description,omitempty"`
+}
+
+// ChangeStep is one operation on one switch
+type ChangeStep struct {
+	SwitchID int                      `json:"switch_id"`
+	Type     string                   `json:"type"`
+	CLI      []string                 `json:"cli,omitempty"`
+	Port     *PortEdit                `json:"port,omitempty"`
+	System   *UpdateSystemInfoRequest `json:"system,omitempty"`
+}
+
+// commands returns the exact CLI the step sends
+func (st *ChangeStep) commands() []string {
+	switch st.Type {
+	case StepCLI:
+		return st.CLI
+	case StepPort:
+		commands := []string{"configure terminal", "interface gigabitEthernet " + st.Port.Port}
+		switch st.Port.AdminStatus {
+		case "up":
+			commands = append(commands, "no shutdown")
+		case "down":
+			commands = append(commands, "shutdown")
+		}
+		if st.Port.Description != nil {
+			if *st.Port.Description == "" {
+				commands = append(commands, "no name")
+			} else {
+				commands = append(commands, fmt.Sprintf("name %q", *st.Port.Description))
+			}
+		}
+		return append(commands, "exit", "exit")
+	case StepSystem:
+		return systemInfoCommands(st.System)
+	}
+	return nil
+}
+
+// validate checks the step is complete and well formed
+func (st *ChangeStep) validate() error {
+	switch st.Type {
+	case StepCLI:
+		if len(st.CLI) == 0 || len(st.CLI) > maxCLICommands {
+			return fmt.Errorf("between 1 and %d commands are required", maxCLICommands)
+		}
+	case StepPort:
+		if st.Port == nil || !portNamePattern.MatchString(st.Port.Port) {
+			return fmt.Errorf("port must be given as slot/port")
+		}
+		if st.Port.AdminStatus != "" && st.Port.AdminStatus != "up" && st.Port.AdminStatus != "down" {
+			return fmt.Errorf("admin_status must be up or down")
+		}
+		if st.Port.AdminStatus == "" && st.Port.Description == nil {
+			return fmt.Errorf("nothing to change on port %s", st.Port.Port)
+		}
+	case StepSystem:
+		if st.System == nil || (st.System.SysName == "" && st.System.SysLocation == "" && st.System.SysContact == "") {
+			return fmt.Errorf("system step needs sysName, sysLocation or sysContact")
+		}
+	default:
+		return fmt.Errorf("type must be %s, %s or %s", StepCLI, StepPort, StepSystem)
+	}
+	return nil
+}
+
+// ChangeStepResult is the outcome of one executed step
+type ChangeStepResult struct {
+	Status     string             `json:"status"` // pending, running, success, failure, skipped
+	Error      string             `json:"error,omitempty"`
+	Commands   []CLICommandResult `json:"commands,omitempty"`
+	StartedAt  *time.Time         `json:"started_at,omitempty"`
+	FinishedAt *time.Time         `json:"finished_at,omitempty"`
+
+	Verification *VerificationResult `json:"verification,omitempty"`
+}
+
+// ChangeSaveResult is the outcome of saving the config of a switch the
+// plan changed
+type ChangeSaveResult struct {
+	SwitchID int    `json:"switch_id"`
+	Saved    bool   `json:"saved"`
+	Error    string `json:"error,omitempty"`
+}
+
+// MaintenanceWindow bounds when an approved plan may run
+type MaintenanceWindow struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// ChangePlan is a set of switch operations that needs a second admin's
+// approval before it can run. Steps run in order and stop at the first
+// failure.
+type ChangePlan struct {
+	ID          int                `json:"id"`
+	Title       string             `json:"title"`
+	Description string             `json:"description,omitempty"`
+	Status      string             `json:"status"`
+	Window      *MaintenanceWindow `json:"window,omitempty"`
+	Verify      *VerifySpec        `json:"verify,omitempty"` // Checked after every step
+	AutoSave    bool               `json:"auto_save,omitempty"`
+	Steps       []ChangeStep       `json:"steps"`
+	Results     []ChangeStepResult `json:"results"`
+	Saves       []ChangeSaveResult `json:"saves,omitempty"`
+
+	CreatedBy     string     `json:"created_by"`
+	CreatedAt     time.Time  `json:"created_at"`
+	ReviewedBy    string     `json:"reviewed_by,omitempty"`
+	ReviewedAt    *time.Time `json:"reviewed_at,omitempty"`
+	ReviewComment string     `json:"review_comment,omitempty"`
+	ExecutedBy    string     `json:"executed_by,omitempty"`
+	StartedAt     *time.Time `json:"started_at,omitempty"`
+	FinishedAt    *time.Time `json:"finished_at,omitempty"`
+}
+
+// ChangeStore keeps change plans in memory
+type ChangeStore struct {
+	mu     sync.RWMutex
+	plans  map[int]*ChangePlan
+	nextID int
+}
+
+func NewChangeStore() *ChangeStore {
+	return &ChangeStore{plans: make(map[int]*ChangePlan), nextID: 1}
+}
+
+// snapshot returns a copy that is safe to serialize while the plan runs
+func (cs *ChangeStore) snapshot(plan *ChangePlan) ChangePlan {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	copied := *plan
+	copied.Results = append([]ChangeStepResult(nil), plan.Results...)
+	copied.Saves = append([]ChangeSaveResult(nil), plan.Saves...)
+	return copied
+}
+
+// ChangePreview is the exact CLI a plan sends to one switch
+type ChangePreview struct {
+	SwitchID   int      `json:"switch_id"`
+	SwitchName string   `json:"switch_name"`
+	Steps      []int    `json:"steps"`
+	Commands   []string `json:"commands"`
+}
+
+// preview groups the commands of the plan by switch, in execution order
+func (s *Server) preview(plan *ChangePlan) []ChangePreview {
+	var previews []ChangePreview
+	index := make(map[int]int)
+	for i := range plan.Steps {
+		st := &plan.Steps[i]
+		pos, ok := index[st.SwitchID]
+		if !ok {
+			s.mu.RLock()
+			name := ""
+			if sw, exists := s.switches[st.SwitchID]; exists {
+				name = sw.Name
+			}
+			s.mu.RUnlock()
+			previews = append(previews, ChangePreview{SwitchID: st.SwitchID, SwitchName: name})
+			pos = len(previews) - 1
+			index[st.SwitchID] = pos
+		}
+		previews[pos].Steps = append(previews[pos].Steps, i)
+		previews[pos].Commands = append(previews[pos].Commands, st.commands()...)
+	}
+	return previews
+}
+
+// checkPlanPolicy verifies role may run every command of the plan
+func (s *Server) checkPlanPolicy(plan *ChangePlan, role string) error {
+	for i := range plan.Steps {
+		for _, cmd := range plan.Steps[i].commands() {
+			if err := s.cliPolicy.Check(role, cmd); err != nil {
+				return fmt.Errorf("step %d: %v", i, err)
+			}
+		}
+	}
+	return nil
+}
+
+type ChangePlanRequest struct {
+	Title       string             `json:"title" binding:"required"`
+	Description string             `json:"description"`
+	Window      *MaintenanceWindow `json:"window"`
+	Verify      *VerifySpec        `json:"verify"`
+	AutoSave    bool               `json:"auto_save"` // Save the config of every changed switch once all steps succeed
+	Steps       []ChangeStep       `json:"steps" binding:"required"`
+}
+
+func (s *Server) createChangePlan(c *gin.Context) {
+	var req ChangePlanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	if len(req.Steps) == 0 || len(req.Steps) > maxChangeSteps {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Between 1 and %d steps are required", maxChangeSteps)})
+		return
+	}
+	if req.Window != nil && (!req.Window.End.After(req.Window.Start) || req.Window.End.Before(time.Now())) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Maintenance window must end after it starts and in the future"})
+		return
+	}
+
+	for i := range req.Steps {
+		st := &req.Steps[i]
+		if err := st.validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Step %d: %v", i, err)})
+			return
+		}
+		s.mu.RLock()
+		_, exists := s.switches[st.SwitchID]
+		s.mu.RUnlock()
+		if !exists {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Step %d: switch %d not found", i, st.SwitchID)})
+			return
+		}
+	}
+
+	plan := &ChangePlan{
+		Title:       req.Title,
+		Description: req.Description,
+		Status:      ChangePendingApproval,
+		Window:      req.Window,
+		Verify:      req.Verify,
+		AutoSave:    req.AutoSave,
+		Steps:       req.Steps,
+		Results:     make([]ChangeStepResult, len(req.Steps)),
+		CreatedBy:   c.GetString("username"),
+		CreatedAt:   time.Now(),
+	}
+	for i := range plan.Results {
+		plan.Results[i].Status = "pending"
+	}
+
+	// The author can't smuggle commands past their own CLI policy by
+	// having someone else approve them
+	if err := s.checkPlanPolicy(plan, c.GetString("role")); err != nil {
+		s.audit(c, "change.create", 0, "denied", gin.H{"title": plan.Title, "reason": logging.Scrub(err.Error())})
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+
+	s.changes.mu.Lock()
+	plan.ID = s.changes.nextID
+	s.changes.nextID++
+	s.changes.plans[plan.ID] = plan
+	s.changes.mu.Unlock()
+
+	s.audit(c, "change.create", 0, "success", gin.H{"change_id": plan.ID, "title": plan.Title, "steps": len(plan.Steps)})
+	c.JSON(http.StatusCreated, gin.H{"change": s.changes.snapshot(plan), "preview": s.preview(plan)})
+}
+
+func (s *Server) listChangePlans(c *gin.Context) {
+	status := c.Query("status")
+
+	s.changes.mu.RLock()
+	plans := make([]*ChangePlan, 0, len(s.changes.plans))
+	for _, plan := range s.changes.plans {
+		if status == "" || plan.Status == status {
+			plans = append(plans, plan)
+		}
+	}
+	s.changes.mu.RUnlock()
+
+	sort.Slice(plans, func(i, j int) bool { return plans[i].ID > plans[j].ID })
+
+	summaries := make([]ChangePlan, len(plans))
+	for i, plan := range plans {
+		summaries[i] = s.changes.snapshot(plan)
+	}
+	c.JSON(http.StatusOK, gin.H{"changes": summaries})
+}
+
+func (s *Server) lookupChangePlan(c *gin.Context) (*ChangePlan, bool) {
+	var id int
+	if _, err := fmt.Sscanf(c.Param("id"), "%d", &id); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid change ID"})
+		return nil, false
+	}
+
+	s.changes.mu.RLock()
+	plan, exists := s.changes.plans[id]
+	s.changes.mu.RUnlock()
+
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Change not found"})
+		return nil, false
+	}
+	return plan, true
+}
+
+func (s *Server) getChangePlan(c *gin.Context) {
+	plan, ok := s.lookupChangePlan(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"change": s.changes.snapshot(plan), "preview": s.preview(plan)})
+}
+
+func (s *Server) previewChangePlan(c *gin.Context) {
+	plan, ok := s.lookupChangePlan(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"change_id": plan.ID, "preview": s.preview(plan)})
+}
+
+type ChangeReviewRequest struct {
+	Comment string `json:"comment"`
+}
+
+// reviewChangePlan approves or rejects a pending plan. The reviewer must
+// be an admin other than the author.
+func (s *Server) reviewChangePlan(c *gin.Context, approve bool) {
+	plan, ok := s.lookupChangePlan(c)
+	if !ok {
+		return
+	}
+
+	var req ChangeReviewRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+	}
+
+	action := "change.reject"
+	status := ChangeRejected
+	if approve {
+		action = "change.approve"
+		status = ChangeApproved
+	}
+
+	username := c.GetString("username")
+	if username == plan.CreatedBy {
+		s.audit(c, action, 0, "denied", gin.H{"change_id": plan.ID, "reason": "author cannot review own change"})
+		c.JSON(http.StatusForbidden, gin.H{"error": "A change must be reviewed by someone other than its author"})
+		return
+	}
+
+	if approve {
+		if err := s.checkPlanPolicy(plan, c.GetString("role")); err != nil {
+			s.audit(c, action, 0, "denied", gin.H{"change_id": plan.ID, "reason": logging.Scrub(err.Error())})
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	s.changes.mu.Lock()
+	if plan.Status != ChangePendingApproval {
+		current := plan.Status
+		s.changes.mu.Unlock()
+		c.JSON(http.StatusConflict, gin.H{"error": "Change is " + current})
+		return
+	}
+	now := time.Now()
+	plan.Status = status
+	plan.ReviewedBy = username
+	plan.ReviewedAt = &now
+	plan.ReviewComment = req.Comment
+	s.changes.mu.Unlock()
+
+	s.audit(c, action, 0, "success", gin.H{"change_id": plan.ID, "comment": req.Comment})
+	s.events.Publish(EventChangeProgress, 0, gin.H{"change_id": plan.ID, "status": status})
+	c.JSON(http.StatusOK, gin.H{"change": s.changes.snapshot(plan)})
+}
+
+func (s *Server) approveChangePlan(c *gin.Context) { s.reviewChangePlan(c, true) }
+func (s *Server) rejectChangePlan(c *gin.Context)  { s.reviewChangePlan(c, false) }
+
+// executeChangePlan runs an approved plan now. Plans with a maintenance
+// window can only be started inside it; the scheduler starts them at the
+// beginning of the window otherwise.
+func (s *Server) executeChangePlan(c *gin.Context) {
+	plan, ok := s.lookupChangePlan(c)
+	if !ok {
+		return
+	}
+
+	s.changes.mu.Lock()
+	if plan.Status != ChangeApproved {
+		current := plan.Status
+		s.changes.mu.Unlock()
+		c.JSON(http.StatusConflict, gin.H{"error": "Only approved changes can be executed; change is " + current})
+		return
+	}
+	now := time.Now()
+	if plan.Window != nil && (now.Before(plan.Window.Start) || now.After(plan.Window.End)) {
+		s.changes.mu.Unlock()
+		c.JSON(http.StatusConflict, gin.H{"error": "Outside the maintenance window; the change will start automatically when it opens"})
+		return
+	}
+	plan.Status = ChangeRunning
+	plan.ExecutedBy = c.GetString("username")
+	s.changes.mu.Unlock()
+
+	s.audit(c, "change.execute", 0, "success", gin.H{"change_id": plan.ID})
+	go s.runChangePlan(logging.With(detach(c), "change_id", plan.ID), plan)
+
+	c.JSON(http.StatusAccepted, gin.H{"change": s.changes.snapshot(plan)})
+}
+
+func (s *Server) cancelChangePlan(c *gin.Context) {
+	plan, ok := s.lookupChangePlan(c)
+	if !ok {
+		return
+	}
+
+	username := c.GetString("username")
+	if username != plan.CreatedBy && c.GetString("role") != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the author or an admin can cancel a change"})
+		return
+	}
+
+	s.changes.mu.Lock()
+	if plan.Status != ChangePendingApproval && plan.Status != ChangeApproved {
+		current := plan.Status
+		s.changes.mu.Unlock()
+		c.JSON(http.StatusConflict, gin.H{"error": "Change is " + current})
+		return
+	}
+	plan.Status = ChangeCancelled
+	s.changes.mu.Unlock()
+
+	s.audit(c, "change.cancel", 0, "success", gin.H{"change_id": plan.ID})
+	s.events.Publish(EventChangeProgress, 0, gin.H{"change_id": plan.ID, "status": ChangeCancelled})
+	c.JSON(http.StatusOK, gin.H{"change": s.changes.snapshot(plan)})
+}
+
+// runChangePlan executes the steps of a plan already marked running, in
+// order, stopping at the first failure
+func (s *Server) runChangePlan(ctx context.Context, plan *ChangePlan) {
+	logger := logging.FromContext(ctx)
+
+	s.changes.mu.Lock()
+	started := time.Now()
+	plan.StartedAt = &started
+	s.changes.mu.Unlock()
+
+	logger.Info("change started", "title", plan.Title, "steps", len(plan.Steps))
+
+	failed := false
+	for i := range plan.Steps {
+		st := &plan.Steps[i]
+		res := &plan.Results[i]
+
+		if failed {
+			s.changes.mu.Lock()
+			res.Status = "skipped"
+			s.changes.mu.Unlock()
+			continue
+		}
+
+		s.changes.mu.Lock()
+		stepStart := time.Now()
+		res.Status = "running"
+		res.StartedAt = &stepStart
+		s.changes.mu.Unlock()
+
+		execution, verification, err := s.runChangeStep(ctx, st, plan.Verify)
+
+		s.changes.mu.Lock()
+		stepEnd := time.Now()
+		res.FinishedAt = &stepEnd
+		res.Status = "success"
+		res.Verification = verification
+		if execution != nil {
+			res.Commands = execution.Commands
+		}
+		if err != nil {
+			res.Status = "failure"
+			res.Error = err.Error()
+			failed = true
+		}
+		status := res.Status
+		s.changes.mu.Unlock()
+
+		s.events.Publish(EventChangeProgress, st.SwitchID, gin.H{"change_id": plan.ID, "step": i, "step_status": status})
+	}
+
+	// A failed plan leaves the startup config alone, so a reboot undoes
+	// whatever part of it was applied
+	if !failed && plan.AutoSave {
+		if !s.saveChangedSwitches(ctx, plan) {
+			failed = true
+		}
+	}
+
+	s.changes.mu.Lock()
+	finished := time.Now()
+	plan.FinishedAt = &finished
+	plan.Status = ChangeCompleted
+	if failed {
+		plan.Status = ChangeFailed
+	}
+	status := plan.Status
+	s.changes.mu.Unlock()
+
+	outcome := "success"
+	if failed {
+		outcome = "failure"
+	}
+	s.auditLog.record(ctx, AuditEntry{
+		User:    "system",
+		Action:  "change.finish",
+		Result:  outcome,
+		Details: gin.H{"change_id": plan.ID, "status": status},
+	})
+	s.events.Publish(EventChangeProgress, 0, gin.H{"change_id": plan.ID, "status": status})
+	logger.Info("change finished", "status", status)
+}
+
+// saveChangedSwitches saves the config of each switch the plan touched and
+// reports whether all saves succeeded
+func (s *Server) saveChangedSwitches(ctx context.Context, plan *ChangePlan) bool {
+	ok := true
+	seen := make(map[int]bool)
+	for _, st := range plan.Steps {
+		if seen[st.SwitchID] {
+			continue
+		}
+		seen[st.SwitchID] = true
+
+		save := ChangeSaveResult{SwitchID: st.SwitchID}
+		s.mu.RLock()
+		sw, exists := s.switches[st.SwitchID]
+		s.mu.RUnlock()
+		var err error
+		if !exists {
+			err = fmt.Errorf("switch %d no longer exists", st.SwitchID)
+		} else {
+			err = s.saveConfig(switchContext(ctx, sw, "change"), sw)
+		}
+		if err != nil {
+			save.Error = err.Error()
+			ok = false
+		} else {
+			save.Saved = true
+		}
+
+		s.changes.mu.Lock()
+		plan.Saves = append(plan.Saves, save)
+		s.changes.mu.Unlock()
+	}
+	return ok
+}
+
+// runChangeStep sends one step to its switch and verifies it with spec,
+// or with the system step's own spec if it has one. Failed commands and
+// failed verification make the step fail.
+func (s *Server) runChangeStep(ctx context.Context, st *ChangeStep, spec *VerifySpec) (*CLICommandExecution, *VerificationResult, error) {
+	s.mu.RLock()
+	sw, exists := s.switches[st.SwitchID]
+	s.mu.RUnlock()
+	if !exists {
+		return nil, nil, fmt.Errorf("switch %d no longer exists", st.SwitchID)
+	}
+
+	ctx = switchContext(ctx, sw, "change")
+	if err := s.ensureAuthenticated(ctx, sw); err != nil {
+		return nil, nil, fmt.Errorf("authentication failed: %w", err)
+	}
+
+	var expected *UpdateSystemInfoRequest
+	if st.Type == StepSystem {
+		expected = st.System
+		if st.System.Verify != nil {
+			spec = st.System.Verify
+		}
+	}
+
+	execution, verification, err := s.pushWithVerification(ctx, sw, st.commands(), spec, expected)
+	if err != nil {
+		return execution, verification, err
+	}
+
+	if st.Type == StepSystem {
+		s.cacheSystemInfo(sw, st.System, "change")
+	}
+	return execution, verification, nil
+}
+
+// changeScheduler starts approved plans when their maintenance window
+// opens and expires those whose window passed without running
+func (s *Server) changeScheduler() {
+	ticker := time.NewTicker(changeSchedulerPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.startScheduledChanges()
+		case <-s.stopSync:
+			return
+		}
+	}
+}
+
+func (s *Server) startScheduledChanges() {
+	now := time.Now()
+	var due []*ChangePlan
+
+	s.changes.mu.Lock()
+	for _, plan := range s.changes.plans {
+		if plan.Status != ChangeApproved || plan.Window == nil {
+			continue
+		}
+		switch {
+		case now.After(plan.Window.End):
+			plan.Status = ChangeExpired
+			s.events.Publish(EventChangeProgress, 0, gin.H{"change_id": plan.ID, "status": ChangeExpired})
+		case !now.Before(plan.Window.Start):
+			plan.Status = ChangeRunning
+			plan.ExecutedBy = "scheduler"
+			due = append(due, plan)
+		}
+	}
+	s.changes.mu.Unlock()
+
+	for _, plan := range due {
+		ctx := logging.With(logging.WithRequestID(context.Background(), logging.NewRequestID()), "change_id", plan.ID)
+		go s.runChangePlan(ctx, plan)
+	}
+}
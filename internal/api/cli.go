@@ -0,0 +1,278 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/JarvisTchibClawBot/OpenExtremeManagement/internal/logging"
+	"github.com/gin-gonic/gin"
+)
+
+// Maximum number of commands accepted in a single CLI request
+const maxCLICommands = 100
+
+// CLICommandResult is the outcome of one command run through the switch's
+// CLI operation endpoint
+type CLICommandResult struct {
+	Command string `json:"command"`
+	Output  string `json:"output"`
+	Status  string `json:"status"`
+	Success bool   `json:"success"`
+}
+
+// CLICommandExecution is the response of /v0/operation/system/cli
+type CLICommandExecution struct {
+	Commands []CLICommandResult `json:"commands"`
+}
+
+// Succeeded reports whether every command in the batch succeeded
+func (e *CLICommandExecution) Succeeded() bool {
+	for _, r := range e.Commands {
+		if !r.Success {
+			return false
+		}
+	}
+	return true
+}
+
+// runCLI executes commands through the switch's CLI operation endpoint and
+// returns the per-command results. An error is only returned if the batch
+// could not be delivered; failed commands are reported in the results.
+func (s *Server) runCLI(ctx context.Context, sw *Switch, commands []string) (execution *CLICommandExecution, err error) {
+	defer func() {
+		outcome := result(err)
+		if err == nil && !execution.Succeeded() {
+			outcome = "failure"
+		}
+		s.metrics.cliPushTotal.WithLabelValues(strconv.Itoa(sw.ID), outcome).Inc()
+	}()
+
+	logger := logging.FromContext(ctx)
+
+	jsonData, err := json.Marshal(map[string]interface{}{
+		"commands": commands,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal payload: %v", err)
+	}
+
+	logger.Info("pushing CLI commands", "commands", commands)
+
+	client, err := s.switchClient(sw)
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq, err := newSwitchRequest(ctx, sw, "POST", "/v0/operation/system/cli", bytes.NewReader(jsonData))
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		logger.Error("CLI command failed", "status", resp.StatusCode, "body", string(body))
+		return nil, fmt.Errorf("status %d: %s", resp.StatusCode, string(body))
+	}
+
+	execution = &CLICommandExecution{}
+	if err := json.Unmarshal(body, execution); err != nil {
+		return nil, fmt.Errorf("invalid response: %v", err)
+	}
+	for i := range execution.Commands {
+		status := strings.ToUpper(execution.Commands[i].Status)
+		execution.Commands[i].Success = status == "SUCCESS" || status == "OK"
+	}
+	s.trackConfigState(sw, execution)
+
+	if execution.Succeeded() {
+		logger.Info("CLI commands applied")
+	} else {
+		logger.Warn("CLI commands completed with failures")
+	}
+
+	return execution, nil
+}
+
+// CLIRolePolicy restricts which commands a role may run. Deny patterns are
+// checked first; if Allow is non-empty a command must match one of them.
+type CLIRolePolicy struct {
+	Allow []string `json:"allow"`
+	Deny  []string `json:"deny"`
+
+	allow []*regexp.Regexp
+	deny  []*regexp.Regexp
+}
+
+// CLIPolicy maps roles to their command restrictions. Roles without an
+// entry may not use the CLI endpoint.
+type CLIPolicy struct {
+	Roles map[string]*CLIRolePolicy `json:"roles"`
+}
+
+// defaultCLIPolicy lets admins run anything, operators anything except
+// disruptive commands, and viewers only show commands
+func defaultCLIPolicy() *CLIPolicy {
+	disruptive := []string{`^reload\b`, `^reset\b`, `^boot\b`, `^delete\b`, `^remove\b`, `^format\b`, `^copy\b.*\bflash\b`, `^software\b`}
+	return &CLIPolicy{Roles: map[string]*CLIRolePolicy{
+		"admin":    {},
+		"operator": {Deny: disruptive},
+		"viewer":   {Allow: []string{`^show\b`}},
+	}}
+}
+
+// loadCLIPolicy reads the policy from path, or returns the default policy
+// if path is empty
+func loadCLIPolicy(path string) (*CLIPolicy, error) {
+	policy := defaultCLIPolicy()
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CLI policy: %v", err)
+		}
+		policy = &CLIPolicy{}
+		if err := json.Unmarshal(data, policy); err != nil {
+			return nil, fmt.Errorf("invalid CLI policy: %v", err)
+		}
+	}
+
+	for role, rp := range policy.Roles {
+		if rp == nil {
+			rp = &CLIRolePolicy{}
+			policy.Roles[role] = rp
+		}
+		for _, pattern := range rp.Allow {
+			re, err := regexp.Compile("(?i)" + pattern)
+			if err != nil {
+				return nil, fmt.Errorf("invalid allow pattern %q for role %s: %v", pattern, role, err)
+			}
+			rp.allow = append(rp.allow, re)
+		}
+		for _, pattern := range rp.Deny {
+			re, err := regexp.Compile("(?i)" + pattern)
+			if err != nil {
+				return nil, fmt.Errorf("invalid deny pattern %q for role %s: %v", pattern, role, err)
+			}
+			rp.deny = append(rp.deny, re)
+		}
+	}
+
+	return policy, nil
+}
+
+// Check returns an error describing why role may not run command
+func (p *CLIPolicy) Check(role, command string) error {
+	rp, ok := p.Roles[role]
+	if !ok {
+		return fmt.Errorf("role %q may not run CLI commands", role)
+	}
+
+	command = strings.TrimSpace(command)
+	// Patterns anchor on the start of the whole string, so a second line
+	// would slip past them
+	if strings.ContainsAny(command, "\r\n") {
+		return fmt.Errorf("command %q spans several lines, send each as its own command", command)
+	}
+	for _, re := range rp.deny {
+		if re.MatchString(command) {
+			return fmt.Errorf("command %q is denied for role %s", command, role)
+		}
+	}
+
+	if len(rp.allow) == 0 {
+		return nil
+	}
+	for _, re := range rp.allow {
+		if re.MatchString(command) {
+			return nil
+		}
+	}
+	return fmt.Errorf("command %q is not allowed for role %s", command, role)
+}
+
+type CLIRequest struct {
+	Commands []string `json:"commands" binding:"required"`
+}
+
+func (s *Server) executeCLI(c *gin.Context) {
+	idStr := c.Param("id")
+	var id int
+	if _, err := fmt.Sscanf(idStr, "%d", &id); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid switch ID"})
+		return
+	}
+
+	var req CLIRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	if len(req.Commands) == 0 || len(req.Commands) > maxCLICommands {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Between 1 and %d commands are required", maxCLICommands)})
+		return
+	}
+
+	s.mu.RLock()
+	sw, exists := s.switches[id]
+	s.mu.RUnlock()
+
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Switch not found"})
+		return
+	}
+
+	scrubbed := make([]string, len(req.Commands))
+	for i, cmd := range req.Commands {
+		scrubbed[i] = logging.Scrub(cmd)
+	}
+
+	role := c.GetString("role")
+	for _, cmd := range req.Commands {
+		if err := s.cliPolicy.Check(role, cmd); err != nil {
+			s.audit(c, "cli.execute", sw.ID, "denied", gin.H{"commands": scrubbed, "reason": logging.Scrub(err.Error())})
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	ctx := switchContext(c.Request.Context(), sw, "cli")
+
+	if err := s.ensureAuthenticated(ctx, sw); err != nil {
+		s.audit(c, "cli.execute", sw.ID, "failure", gin.H{"commands": scrubbed, "error": err.Error()})
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication failed: " + err.Error()})
+		return
+	}
+
+	execution, err := s.runCLI(ctx, sw, req.Commands)
+	if err != nil {
+		s.audit(c, "cli.execute", sw.ID, "failure", gin.H{"commands": scrubbed, "error": err.Error()})
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to execute commands: " + err.Error()})
+		return
+	}
+
+	outcome := "success"
+	if !execution.Succeeded() {
+		outcome = "failure"
+	}
+	s.audit(c, "cli.execute", sw.ID, outcome, gin.H{"commands": scrubbed})
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":  execution.Succeeded(),
+		"commands": execution.Commands,
+	})
+}
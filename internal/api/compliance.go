@@ -0,0 +1,573 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/JarvisTchibClawBot/OpenExtremeManagement/internal/logging"
+	"github.com/gin-gonic/gin"
+)
+
+// Compliance rule types
+const (
+	RuleRequired  = "required"
+	RuleForbidden = "forbidden"
+)
+
+// ComplianceRule is checked against the latest configuration backup of
+// each switch it applies to. Pattern is an exact line unless Regex is set.
+// With Section set, the rule is evaluated inside every block whose header
+// line matches the Section expression, up to the block's "exit".
+type ComplianceRule struct {
+	ID          int            `json:"id"`
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Type        string         `json:"type"`
+	Pattern     string         `json:"pattern"`
+	Regex       bool           `json:"regex"`
+	Section     string         `json:"section,omitempty"`
+	Remediation []string       `json:"remediation,omitempty"` // Overrides the generated fix
+	Switches    SwitchSelector `json:"switches"`              // Empty selects every switch
+	CreatedBy   string         `json:"created_by"`
+	CreatedAt   time.Time      `json:"created_at"`
+
+	re        *regexp.Regexp
+	sectionRe *regexp.Regexp
+}
+
+// compile validates the rule and prepares its expressions
+func (r *ComplianceRule) compile() error {
+	if r.Type != RuleRequired && r.Type != RuleForbidden {
+		return fmt.Errorf("type must be %s or %s", RuleRequired, RuleForbidden)
+	}
+	if strings.TrimSpace(r.Pattern) == "" {
+		return fmt.Errorf("pattern is required")
+	}
+	if r.Regex {
+		re, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid pattern: %v", err)
+		}
+		r.re = re
+	}
+	if r.Section != "" {
+		re, err := regexp.Compile(r.Section)
+		if err != nil {
+			return fmt.Errorf("invalid section: %v", err)
+		}
+		r.sectionRe = re
+	}
+	return nil
+}
+
+func (r *ComplianceRule) matches(line string) bool {
+	line = strings.TrimSpace(line)
+	if r.re != nil {
+		return r.re.MatchString(line)
+	}
+	return line == strings.TrimSpace(r.Pattern)
+}
+
+// configSection is a block of configuration lines; the whole config has
+// an empty header
+type configSection struct {
+	header string
+	lines  []string
+}
+
+// sections returns the blocks of lines the rule applies to
+func (r *ComplianceRule) sections(lines []string) []configSection {
+	if r.sectionRe == nil {
+		return []configSection{{lines: lines}}
+	}
+
+	var sections []configSection
+	for i := 0; i < len(lines); i++ {
+		header := strings.TrimSpace(lines[i])
+		if !r.sectionRe.MatchString(header) {
+			continue
+		}
+		section := configSection{header: header}
+		for i++; i < len(lines); i++ {
+			line := strings.TrimSpace(lines[i])
+			if line == "exit" || line == "end" {
+				break
+			}
+			section.lines = append(section.lines, lines[i])
+		}
+		sections = append(sections, section)
+	}
+	return sections
+}
+
+// ComplianceViolation describes one way a switch breaks a rule
+type ComplianceViolation struct {
+	RuleID      int      `json:"rule_id"`
+	RuleName    string   `json:"rule_name"`
+	Type        string   `json:"type"`
+	Section     string   `json:"section,omitempty"`
+	Lines       []string `json:"lines,omitempty"`   // Forbidden lines found
+	Missing     string   `json:"missing,omitempty"` // Required pattern not found
+	Remediation []string `json:"remediation,omitempty"`
+}
+
+// evaluate checks the rule against config lines
+func (r *ComplianceRule) evaluate(lines []string) []ComplianceViolation {
+	sections := r.sections(lines)
+	if len(sections) == 0 {
+		if r.Type == RuleForbidden {
+			return nil
+		}
+		return []ComplianceViolation{{
+			RuleID:   r.ID,
+			RuleName: r.Name,
+			Type:     r.Type,
+			Section:  r.Section,
+			Missing:  "section " + r.Section,
+		}}
+	}
+
+	var violations []ComplianceViolation
+	for _, section := range sections {
+		var found []string
+		for _, line := range section.lines {
+			if r.matches(line) {
+				found = append(found, strings.TrimSpace(line))
+			}
+		}
+
+		v := ComplianceViolation{RuleID: r.ID, RuleName: r.Name, Type: r.Type, Section: section.header}
+		switch {
+		case r.Type == RuleRequired && len(found) == 0:
+			v.Missing = r.Pattern
+		case r.Type == RuleForbidden && len(found) > 0:
+			v.Lines = found
+		default:
+			continue
+		}
+		v.Remediation = r.remediation(v)
+		violations = append(violations, v)
+	}
+	return violations
+}
+
+// remediation returns the commands fixing v, or nil if the rule can't be
+// fixed automatically (a required regex without explicit remediation)
+func (r *ComplianceRule) remediation(v ComplianceViolation) []string {
+	var commands []string
+	switch {
+	case len(r.Remediation) > 0:
+		commands = r.Remediation
+	case r.Type == RuleForbidden:
+		for _, line := range v.Lines {
+			commands = append(commands, "no "+line)
+		}
+	case !r.Regex:
+		commands = []string{strings.TrimSpace(r.Pattern)}
+	default:
+		return nil
+	}
+
+	if v.Section == "" {
+		return commands
+	}
+	wrapped := append([]string{v.Section}, commands...)
+	return append(wrapped, "exit")
+}
+
+// remediationCommands merges the fixes of a switch's violations, each
+// command once per section, and names the rules that can't be fixed
+func remediationCommands(violations []ComplianceViolation) (fix, unremediable []string) {
+	seen := make(map[string]bool)
+	for _, v := range violations {
+		if v.Remediation == nil {
+			unremediable = append(unremediable, v.RuleName)
+			continue
+		}
+		commands := v.Remediation
+		if v.Section != "" {
+			// Drop the header and exit remediation wrapped them in
+			commands = commands[1 : len(commands)-1]
+		}
+
+		var body []string
+		for _, cmd := range commands {
+			key := v.Section + "\x00" + cmd
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			body = append(body, cmd)
+		}
+		switch {
+		case len(body) == 0:
+		case v.Section == "":
+			fix = append(fix, body...)
+		default:
+			fix = append(append(append(fix, v.Section), body...), "exit")
+		}
+	}
+	return fix, unremediable
+}
+
+// ComplianceStore holds the compliance rules
+type ComplianceStore struct {
+	mu     sync.RWMutex
+	rules  map[int]*ComplianceRule
+	nextID int
+}
+
+func NewComplianceStore() *ComplianceStore {
+	return &ComplianceStore{rules: make(map[int]*ComplianceRule), nextID: 1}
+}
+
+// list returns the rules ordered by ID
+func (cs *ComplianceStore) list() []*ComplianceRule {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	rules := make([]*ComplianceRule, 0, len(cs.rules))
+	for _, r := range cs.rules {
+		rules = append(rules, r)
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].ID < rules[j].ID })
+	return rules
+}
+
+// SwitchCompliance is the compliance state of one switch
+type SwitchCompliance struct {
+	SwitchID      int                   `json:"switch_id"`
+	SwitchName    string                `json:"switch_name"`
+	Status        string                `json:"status"` // pass, fail, unknown (no backup yet)
+	BackupID      int                   `json:"backup_id,omitempty"`
+	BackupTakenAt *time.Time            `json:"backup_taken_at,omitempty"`
+	RulesChecked  int                   `json:"rules_checked"`
+	Violations    []ComplianceViolation `json:"violations,omitempty"`
+}
+
+// evaluateCompliance checks the rules against the latest backup of each
+// switch. If ruleIDs is non-empty only those rules are checked.
+func (s *Server) evaluateCompliance(switches []*Switch, ruleIDs []int) ([]SwitchCompliance, error) {
+	wanted := make(map[int]bool, len(ruleIDs))
+	for _, id := range ruleIDs {
+		wanted[id] = true
+	}
+
+	// Resolve each rule's selector once
+	type scopedRule struct {
+		rule    *ComplianceRule
+		applies map[int]bool
+	}
+	var rules []scopedRule
+	for _, r := range s.compliance.list() {
+		if len(wanted) > 0 && !wanted[r.ID] {
+			continue
+		}
+		selected, err := s.selectSwitches(r.Switches)
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: %v", r.ID, err)
+		}
+		applies := make(map[int]bool, len(selected))
+		for _, sw := range selected {
+			applies[sw.ID] = true
+		}
+		rules = append(rules, scopedRule{rule: r, applies: applies})
+	}
+
+	results := make([]SwitchCompliance, 0, len(switches))
+	for _, sw := range switches {
+		s.mu.RLock()
+		result := SwitchCompliance{SwitchID: sw.ID, SwitchName: sw.Name, Status: "unknown"}
+		s.mu.RUnlock()
+
+		backup := s.backups.latest(sw.ID)
+		if backup == nil {
+			results = append(results, result)
+			continue
+		}
+		result.BackupID = backup.ID
+		result.BackupTakenAt = &backup.TakenAt
+
+		lines := backup.Lines()
+		for _, sr := range rules {
+			if !sr.applies[sw.ID] {
+				continue
+			}
+			result.RulesChecked++
+			result.Violations = append(result.Violations, sr.rule.evaluate(lines)...)
+		}
+
+		result.Status = "pass"
+		if len(result.Violations) > 0 {
+			result.Status = "fail"
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// getCompliance reports per-switch compliance, optionally filtered by
+// switch_id and status
+func (s *Server) getCompliance(c *gin.Context) {
+	var sel SwitchSelector
+	if idStr := c.Query("switch_id"); idStr != "" {
+		var id int
+		if _, err := fmt.Sscanf(idStr, "%d", &id); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid switch ID"})
+			return
+		}
+		sel.IDs = []int{id}
+	}
+
+	switches, err := s.selectSwitches(sel)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	results, err := s.evaluateCompliance(switches, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	status := c.Query("status")
+	summary := map[string]int{"pass": 0, "fail": 0, "unknown": 0}
+	filtered := make([]SwitchCompliance, 0, len(results))
+	for _, r := range results {
+		summary[r.Status]++
+		if status == "" || r.Status == status {
+			filtered = append(filtered, r)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"summary": summary, "switches": filtered})
+}
+
+func (s *Server) listComplianceRules(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"rules": s.compliance.list()})
+}
+
+type ComplianceRuleRequest struct {
+	Name        string         `json:"name" binding:"required"`
+	Description string         `json:"description"`
+	Type        string         `json:"type" binding:"required"`
+	Pattern     string         `json:"pattern" binding:"required"`
+	Regex       bool           `json:"regex"`
+	Section     string         `json:"section"`
+	Remediation []string       `json:"remediation"`
+	Switches    SwitchSelector `json:"switches"`
+}
+
+// bindComplianceRule reads and validates a rule from the request body
+func bindComplianceRule(c *gin.Context) (*ComplianceRule, bool) {
+	var req ComplianceRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return nil, false
+	}
+
+	rule := &ComplianceRule{
+		Name:        req.Name,
+		Description: req.Description,
+		Type:        req.Type,
+		Pattern:     req.Pattern,
+		Regex:       req.Regex,
+		Section:     req.Section,
+		Remediation: req.Remediation,
+		Switches:    req.Switches,
+	}
+	if err := rule.compile(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid rule: " + err.Error()})
+		return nil, false
+	}
+	return rule, true
+}
+
+func (s *Server) createComplianceRule(c *gin.Context) {
+	rule, ok := bindComplianceRule(c)
+	if !ok {
+		return
+	}
+	rule.CreatedBy = c.GetString("username")
+	rule.CreatedAt = time.Now()
+
+	s.compliance.mu.Lock()
+	rule.ID = s.compliance.nextID
+	s.compliance.nextID++
+	s.compliance.rules[rule.ID] = rule
+	s.compliance.mu.Unlock()
+
+	s.audit(c, "compliance.rule_create", 0, "success", gin.H{"rule_id": rule.ID, "name": rule.Name})
+	c.JSON(http.StatusCreated, gin.H{"rule": rule})
+}
+
+func (s *Server) lookupComplianceRule(c *gin.Context) (*ComplianceRule, bool) {
+	var id int
+	if _, err := fmt.Sscanf(c.Param("id"), "%d", &id); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid rule ID"})
+		return nil, false
+	}
+
+	s.compliance.mu.RLock()
+	rule, exists := s.compliance.rules[id]
+	s.compliance.mu.RUnlock()
+
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Rule not found"})
+		return nil, false
+	}
+	return rule, true
+}
+
+func (s *Server) updateComplianceRule(c *gin.Context) {
+	existing, ok := s.lookupComplianceRule(c)
+	if !ok {
+		return
+	}
+	rule, ok := bindComplianceRule(c)
+	if !ok {
+		return
+	}
+	rule.ID = existing.ID
+	rule.CreatedBy = existing.CreatedBy
+	rule.CreatedAt = existing.CreatedAt
+
+	// Replace rather than mutate so evaluations in progress keep a
+	// consistent rule
+	s.compliance.mu.Lock()
+	s.compliance.rules[rule.ID] = rule
+	s.compliance.mu.Unlock()
+
+	s.audit(c, "compliance.rule_update", 0, "success", gin.H{"rule_id": rule.ID, "name": rule.Name})
+	c.JSON(http.StatusOK, gin.H{"rule": rule})
+}
+
+func (s *Server) deleteComplianceRule(c *gin.Context) {
+	rule, ok := s.lookupComplianceRule(c)
+	if !ok {
+		return
+	}
+
+	s.compliance.mu.Lock()
+	delete(s.compliance.rules, rule.ID)
+	s.compliance.mu.Unlock()
+
+	s.audit(c, "compliance.rule_delete", 0, "success", gin.H{"rule_id": rule.ID, "name": rule.Name})
+	c.JSON(http.StatusOK, gin.H{"message": "Rule deleted"})
+}
+
+type RemediationRequest struct {
+	Switches    SwitchSelector `json:"switches"`
+	RuleIDs     []int          `json:"rule_ids"`
+	Push        bool           `json:"push"`
+	AutoSave    bool           `json:"auto_save"` // With push, save the config once remediated
+	Concurrency int            `json:"concurrency"`
+}
+
+// SwitchRemediation is the fix generated for one non-compliant switch
+type SwitchRemediation struct {
+	SwitchID     int      `json:"switch_id"`
+	SwitchName   string   `json:"switch_name"`
+	Commands     []string `json:"commands,omitempty"`
+	Unremediable []string `json:"unremediable,omitempty"` // Rules that need manual fixing
+}
+
+// remediateCompliance generates the commands fixing every violation of the
+// selected switches and, with push set, applies them as a job. Each switch
+// is backed up again after a successful push so the report reflects it.
+func (s *Server) remediateCompliance(c *gin.Context) {
+	var req RemediationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	switches, err := s.selectSwitches(req.Switches)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	results, err := s.evaluateCompliance(switches, req.RuleIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	byID := make(map[int]*Switch, len(switches))
+	for _, sw := range switches {
+		byID[sw.ID] = sw
+	}
+
+	var remediations []SwitchRemediation
+	var targets []*Switch
+	commands := make(map[int][]string)
+	for _, r := range results {
+		if r.Status != "fail" {
+			continue
+		}
+		rem := SwitchRemediation{SwitchID: r.SwitchID, SwitchName: r.SwitchName}
+		var fix []string
+		fix, rem.Unremediable = remediationCommands(r.Violations)
+		if len(fix) > 0 {
+			rem.Commands = append(append([]string{"configure terminal"}, fix...), "exit")
+			commands[r.SwitchID] = rem.Commands
+			targets = append(targets, byID[r.SwitchID])
+		}
+		remediations = append(remediations, rem)
+	}
+
+	if !req.Push {
+		c.JSON(http.StatusOK, gin.H{"results": remediations})
+		return
+	}
+	if len(targets) == 0 {
+		c.JSON(http.StatusOK, gin.H{"results": remediations, "message": "Nothing to push"})
+		return
+	}
+
+	role := c.GetString("role")
+	ids := make([]int, len(targets))
+	for i, sw := range targets {
+		ids[i] = sw.ID
+		if len(commands[sw.ID]) > maxCLICommands {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Remediation for switch %d exceeds %d commands", sw.ID, maxCLICommands)})
+			return
+		}
+		for _, cmd := range commands[sw.ID] {
+			if err := s.cliPolicy.Check(role, cmd); err != nil {
+				s.audit(c, "compliance.remediate", sw.ID, "denied", gin.H{"reason": logging.Scrub(err.Error())})
+				c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+				return
+			}
+		}
+	}
+
+	concurrency := jobConcurrency(req.Concurrency)
+
+	job := &Job{
+		Type:        "remediation",
+		Status:      JobPending,
+		CreatedBy:   c.GetString("username"),
+		CreatedAt:   time.Now(),
+		AutoSave:    req.AutoSave,
+		Concurrency: concurrency,
+		Progress:    JobProgress{Total: len(targets)},
+		afterSwitch: func(ctx context.Context, sw *Switch) {
+			if _, err := s.backupConfig(ctx, sw, "post-change"); err != nil {
+				logging.FromContext(ctx).Warn("config backup after remediation failed", "error", err)
+			}
+		},
+	}
+
+	s.startJob(c, job, targets, commands)
+	s.audit(c, "compliance.remediate", 0, "success", gin.H{"job_id": job.ID, "rule_ids": req.RuleIDs, "switch_ids": ids})
+
+	c.JSON(http.StatusAccepted, gin.H{"results": remediations, "job": s.jobs.snapshot(job, true)})
+}
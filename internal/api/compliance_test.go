@@ -0,0 +1,148 @@
+package api
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestComplianceRuleEvaluate(t *testing.T) {
+	config := []string{
+		"snmp-server name core1",
+		"ntp server 10.0.0.1",
+		"telnet-access enable",
+		"interface gigabitEthernet 1/1",
+		"  name uplink",
+		"  no shutdown",
+		"exit",
+		"interface gigabitEthernet 1/2",
+		"  shutdown",
+		"exit",
+	}
+
+	tests := []struct {
+		name string
+		rule ComplianceRule
+		want []ComplianceViolation
+	}{
+		{
+			name: "required line present",
+			rule: ComplianceRule{Type: RuleRequired, Pattern: "ntp server 10.0.0.1"},
+			want: nil,
+		},
+		{
+			name: "required line missing",
+			rule: ComplianceRule{Type: RuleRequired, Pattern: "ntp server 10.0.0.2"},
+			want: []ComplianceViolation{{
+				Type: RuleRequired, Missing: "ntp server 10.0.0.2",
+				Remediation: []string{"ntp server 10.0.0.2"},
+			}},
+		},
+		{
+			name: "forbidden line present",
+			rule: ComplianceRule{Type: RuleForbidden, Pattern: "telnet-access enable"},
+			want: []ComplianceViolation{{
+				Type: RuleForbidden, Lines: []string{"telnet-access enable"},
+				Remediation: []string{"no telnet-access enable"},
+			}},
+		},
+		{
+			name: "required regex has no generated fix",
+			rule: ComplianceRule{Type: RuleRequired, Pattern: `^logging host `, Regex: true},
+			want: []ComplianceViolation{{Type: RuleRequired, Missing: `^logging host `}},
+		},
+		{
+			name: "explicit remediation wins",
+			rule: ComplianceRule{Type: RuleRequired, Pattern: `^logging host `, Regex: true, Remediation: []string{"logging host 10.0.0.5"}},
+			want: []ComplianceViolation{{
+				Type: RuleRequired, Missing: `^logging host `,
+				Remediation: []string{"logging host 10.0.0.5"},
+			}},
+		},
+		{
+			name: "required per section",
+			rule: ComplianceRule{Type: RuleRequired, Pattern: "no shutdown", Section: `^interface gigabitEthernet`},
+			want: []ComplianceViolation{{
+				Type: RuleRequired, Section: "interface gigabitEthernet 1/2", Missing: "no shutdown",
+				Remediation: []string{"interface gigabitEthernet 1/2", "no shutdown", "exit"},
+			}},
+		},
+		{
+			name: "required section absent",
+			rule: ComplianceRule{Type: RuleRequired, Pattern: "enable", Section: `^router isis`},
+			want: []ComplianceViolation{{Type: RuleRequired, Section: `^router isis`, Missing: "section ^router isis"}},
+		},
+		{
+			name: "forbidden section absent",
+			rule: ComplianceRule{Type: RuleForbidden, Pattern: "enable", Section: `^router isis`},
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := tt.rule
+			if err := rule.compile(); err != nil {
+				t.Fatalf("compile: %v", err)
+			}
+			if got := rule.evaluate(config); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("evaluate() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestComplianceRuleCompile(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    ComplianceRule
+		wantErr bool
+	}{
+		{"valid", ComplianceRule{Type: RuleRequired, Pattern: "ntp server 10.0.0.1"}, false},
+		{"unknown type", ComplianceRule{Type: "recommended", Pattern: "ntp server 10.0.0.1"}, true},
+		{"empty pattern", ComplianceRule{Type: RuleForbidden, Pattern: "  "}, true},
+		{"invalid regex", ComplianceRule{Type: RuleForbidden, Pattern: "(", Regex: true}, true},
+		{"invalid section", ComplianceRule{Type: RuleForbidden, Pattern: "x", Section: "["}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.rule.compile(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: compile() error = %v, want error %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestRemediationCommands(t *testing.T) {
+	config := []string{
+		"telnet-access enable",
+		"interface gigabitEthernet 1/1",
+		"  shutdown",
+		"exit",
+		"interface gigabitEthernet 1/2",
+		"  shutdown",
+		"exit",
+	}
+	rules := []ComplianceRule{
+		{Name: "ports up", Type: RuleRequired, Pattern: "no shutdown", Section: `^interface gigabitEthernet`},
+		{Name: "ports not shut", Type: RuleForbidden, Pattern: "shutdown", Section: `^interface gigabitEthernet`, Remediation: []string{"no shutdown"}},
+		{Name: "no telnet", Type: RuleForbidden, Pattern: "telnet-access enable"},
+		{Name: "syslog", Type: RuleRequired, Pattern: `^logging host `, Regex: true},
+	}
+	var violations []ComplianceViolation
+	for _, rule := range rules {
+		if err := rule.compile(); err != nil {
+			t.Fatalf("compile %s: %v", rule.Name, err)
+		}
+		violations = append(violations, rule.evaluate(config)...)
+	}
+
+	fix, unremediable := remediationCommands(violations)
+	wantFix := []string{
+		"interface gigabitEthernet 1/1", "no shutdown", "exit",
+		"interface gigabitEthernet 1/2", "no shutdown", "exit",
+		"no telnet-access enable",
+	}
+	if !reflect.DeepEqual(fix, wantFix) {
+		t.Errorf("fix = %q, want %q", fix, wantFix)
+	}
+	if want := []string{"syslog"}; !reflect.DeepEqual(unremediable, want) {
+		t.Errorf("unremediable = %q, want %q", unremediable, want)
+	}
+}
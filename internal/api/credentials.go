@@ -0,0 +1,147 @@
+package api
+
+import (
+	"net/http"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var credentialNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// Credential is a named switch login. Switches that reference it pick up
+// changes to it, so a password rotation is one update.
+type Credential struct {
+	Name      string    `json:"name"`
+	Username  string    `json:"username"`
+	Password  string    `json:"-"`
+	UpdatedBy string    `json:"updated_by"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// CredentialStore keeps credentials in memory
+type CredentialStore struct {
+	mu    sync.RWMutex
+	creds map[string]*Credential
+}
+
+func NewCredentialStore() *CredentialStore {
+	return &CredentialStore{creds: make(map[string]*Credential)}
+}
+
+// snapshot returns copies of all credentials by name
+func (cs *CredentialStore) snapshot() map[string]Credential {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	creds := make(map[string]Credential, len(cs.creds))
+	for name, cred := range cs.creds {
+		creds[name] = *cred
+	}
+	return creds
+}
+
+func (s *Server) listCredentials(c *gin.Context) {
+	creds := s.credentials.snapshot()
+	list := make([]Credential, 0, len(creds))
+	for _, cred := range creds {
+		list = append(list, cred)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+
+	// Reference counts help before rotating or deleting a credential
+	used := make(map[string]int)
+	s.mu.RLock()
+	for _, sw := range s.switches {
+		if sw.CredentialRef != "" {
+			used[sw.CredentialRef]++
+		}
+	}
+	s.mu.RUnlock()
+
+	c.JSON(http.StatusOK, gin.H{"credentials": list, "switches": used})
+}
+
+type CredentialRequest struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// setCredential creates or replaces a credential and applies it to every
+// switch that references it
+func (s *Server) setCredential(c *gin.Context) {
+	name := c.Param("name")
+	if !credentialNamePattern.MatchString(name) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Credential names may only contain letters, digits, '.', '-' and '_'"})
+		return
+	}
+
+	var req CredentialRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	cred := &Credential{
+		Name:      name,
+		Username:  req.Username,
+		Password:  req.Password,
+		UpdatedBy: c.GetString("username"),
+		UpdatedAt: time.Now(),
+	}
+	s.credentials.mu.Lock()
+	s.credentials.creds[name] = cred
+	s.credentials.mu.Unlock()
+
+	var updated []int
+	s.mu.Lock()
+	for _, sw := range s.switches {
+		if sw.CredentialRef != name {
+			continue
+		}
+		sw.Username = cred.Username
+		sw.Password = cred.Password
+		sw.AuthToken = ""
+		sw.TokenExpiry = time.Time{}
+		updated = append(updated, sw.ID)
+	}
+	s.mu.Unlock()
+	sort.Ints(updated)
+
+	s.audit(c, "credential.set", 0, "success", gin.H{"name": name, "username": cred.Username, "switch_ids": updated})
+	c.JSON(http.StatusOK, gin.H{"credential": cred, "switches_updated": len(updated)})
+}
+
+func (s *Server) deleteCredential(c *gin.Context) {
+	name := c.Param("name")
+
+	s.mu.RLock()
+	inUse := 0
+	for _, sw := range s.switches {
+		if sw.CredentialRef == name {
+			inUse++
+		}
+	}
+	s.mu.RUnlock()
+
+	s.credentials.mu.Lock()
+	_, exists := s.credentials.creds[name]
+	if exists && inUse == 0 {
+		delete(s.credentials.creds, name)
+	}
+	s.credentials.mu.Unlock()
+
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Credential not found"})
+		return
+	}
+	if inUse > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Credential is used by switches", "switches": inUse})
+		return
+	}
+
+	s.audit(c, "credential.delete", 0, "success", gin.H{"name": name})
+	c.JSON(http.StatusOK, gin.H{"message": "Credential deleted"})
+}
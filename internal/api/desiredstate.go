@@ -0,0 +1,668 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/JarvisTchibClawBot/OpenExtremeManagement/internal/logging"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// A failed automatic reconcile is not retried on every sync
+	reconcileRetryInterval = 10 * time.Minute
+	maxReconcileActions    = 50
+)
+
+// Shown instead of an SNMP community string
+const communityMask = "****"
+
+// SNMPCommunity is an SNMP v1/v2c community and its access level
+type SNMPCommunity struct {
+	Name   string `json:"name"`
+	Access string `json:"access"` // ro or rw
+}
+
+// DesiredState is the declared configuration of a switch. Empty strings
+// and nil lists are not managed; an empty list means "none configured".
+type DesiredState struct {
+	Hostname        string          `json:"hostname,omitempty"`
+	Location        string          `json:"location,omitempty"`
+	Contact         string          `json:"contact,omitempty"`
+	NTPServers      []string        `json:"ntp_servers"`
+	DNSServers      []string        `json:"dns_servers"`
+	SNMPCommunities []SNMPCommunity `json:"snmp_communities"`
+	SyslogHosts     []string        `json:"syslog_hosts"`
+	AutoReconcile   bool            `json:"auto_reconcile"`
+	UpdatedBy       string          `json:"updated_by"`
+	UpdatedAt       time.Time       `json:"updated_at"`
+}
+
+// DriftItem is one setting whose observed value differs from the desired
+type DriftItem struct {
+	Field    string      `json:"field"`
+	Desired  interface{} `json:"desired"`
+	Observed interface{} `json:"observed"`
+	Commands []string    `json:"commands"` // As shown; secrets are masked
+
+	apply []string // Commands actually sent
+}
+
+// DriftReport is the result of comparing a switch with its desired state
+type DriftReport struct {
+	CheckedAt time.Time   `json:"checked_at"`
+	InSync    bool        `json:"in_sync"`
+	BackupID  int         `json:"backup_id,omitempty"` // Backup list settings were read from
+	Items     []DriftItem `json:"items,omitempty"`
+	Error     string      `json:"error,omitempty"` // Why list settings could not be compared
+}
+
+// commands returns the CLI that brings the switch to its desired state
+func (r *DriftReport) commands() []string {
+	var commands []string
+	for _, item := range r.Items {
+		commands = append(commands, item.apply...)
+	}
+	if len(commands) == 0 {
+		return nil
+	}
+	return append(append([]string{"configure terminal"}, commands...), "exit")
+}
+
+// ReconcileAction records an attempt to remove drift
+type ReconcileAction struct {
+	Timestamp time.Time `json:"timestamp"`
+	Trigger   string    `json:"trigger"` // auto or manual
+	User      string    `json:"user,omitempty"`
+	Fields    []string  `json:"fields"`
+	Commands  []string  `json:"commands"`
+	Result    string    `json:"result"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// DesiredStateStore holds desired states, the latest drift of each switch
+// and its reconcile history
+type DesiredStateStore struct {
+	mu      sync.RWMutex
+	states  map[int]*DesiredState
+	drift   map[int]*DriftReport
+	actions map[int][]ReconcileAction
+}
+
+func NewDesiredStateStore() *DesiredStateStore {
+	return &DesiredStateStore{
+		states:  make(map[int]*DesiredState),
+		drift:   make(map[int]*DriftReport),
+		actions: make(map[int][]ReconcileAction),
+	}
+}
+
+func (ds *DesiredStateStore) get(switchID int) *DesiredState {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+	return ds.states[switchID]
+}
+
+func (ds *DesiredStateStore) recordAction(switchID int, action ReconcileAction) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	actions := append(ds.actions[switchID], action)
+	if len(actions) > maxReconcileActions {
+		actions = actions[len(actions)-maxReconcileActions:]
+	}
+	ds.actions[switchID] = actions
+}
+
+// lastAutoFailure returns when automatic reconcile last failed, if it did
+// so after the last success
+func (ds *DesiredStateStore) lastAutoFailure(switchID int) time.Time {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+	actions := ds.actions[switchID]
+	for i := len(actions) - 1; i >= 0; i-- {
+		if actions[i].Trigger != "auto" {
+			continue
+		}
+		if actions[i].Result == "failure" {
+			return actions[i].Timestamp
+		}
+		break
+	}
+	return time.Time{}
+}
+
+func (ds *DesiredStateStore) forgetSwitch(id int) {
+	ds.mu.Lock()
+	delete(ds.states, id)
+	delete(ds.drift, id)
+	delete(ds.actions, id)
+	ds.mu.Unlock()
+}
+
+// observedConfig holds the list settings parsed from a running config
+type observedConfig struct {
+	ntp       []string
+	dns       []string
+	snmp      []SNMPCommunity
+	syslog    map[string]string // address -> host index
+	syslogIPs []string
+}
+
+func parseObservedConfig(lines []string) *observedConfig {
+	obs := &observedConfig{syslog: make(map[string]string)}
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) >= 3 && fields[0] == "ntp" && fields[1] == "server":
+			obs.ntp = append(obs.ntp, fields[2])
+		case len(fields) >= 3 && fields[0] == "ip" && fields[1] == "name-server":
+			obs.dns = append(obs.dns, fields[2])
+		case len(fields) >= 3 && fields[0] == "snmp-server" && fields[1] == "community":
+			access := "ro"
+			if len(fields) >= 4 {
+				access = fields[3]
+			}
+			obs.snmp = append(obs.snmp, SNMPCommunity{Name: fields[2], Access: access})
+		case len(fields) >= 5 && fields[0] == "syslog" && fields[1] == "host" && fields[3] == "address":
+			obs.syslog[fields[4]] = fields[2]
+			obs.syslogIPs = append(obs.syslogIPs, fields[4])
+		}
+	}
+	return obs
+}
+
+// diffSets returns the items of want missing from have and the items of
+// have not in want
+func diffSets(want, have []string) (missing, extra []string) {
+	wantSet := make(map[string]bool, len(want))
+	for _, w := range want {
+		wantSet[w] = true
+	}
+	haveSet := make(map[string]bool, len(have))
+	for _, h := range have {
+		haveSet[h] = true
+		if !wantSet[h] {
+			extra = append(extra, h)
+		}
+	}
+	for _, w := range want {
+		if !haveSet[w] {
+			missing = append(missing, w)
+		}
+	}
+	return missing, extra
+}
+
+// listSettingDrift compares a managed list setting and builds the
+// commands adding and removing entries
+func listSettingDrift(field string, want, have []string, add, remove func(string) string) *DriftItem {
+	if want == nil {
+		return nil
+	}
+	missing, extra := diffSets(want, have)
+	if len(missing) == 0 && len(extra) == 0 {
+		return nil
+	}
+	if have == nil {
+		have = []string{}
+	}
+	item := &DriftItem{Field: field, Desired: want, Observed: have}
+	for _, e := range extra {
+		item.apply = append(item.apply, remove(e))
+	}
+	for _, m := range missing {
+		item.apply = append(item.apply, add(m))
+	}
+	item.Commands = item.apply
+	return item
+}
+
+// computeDrift compares desired with the observed system info and, for
+// list settings, the latest configuration backup
+func computeDrift(desired *DesiredState, info *SystemInfo, backup *ConfigBackup) *DriftReport {
+	report := &DriftReport{CheckedAt: time.Now()}
+
+	scalar := func(field, want, have, command string) {
+		if want != "" && want != have {
+			commands := []string{fmt.Sprintf("%s %s", command, want)}
+			report.Items = append(report.Items, DriftItem{
+				Field:    field,
+				Desired:  want,
+				Observed: have,
+				Commands: commands,
+				apply:    commands,
+			})
+		}
+	}
+	scalar("hostname", desired.Hostname, info.SysName, "hostname")
+	scalar("location", desired.Location, info.SysLocation, "snmp-server location")
+	scalar("contact", desired.Contact, info.SysContact, "snmp-server contact")
+
+	managesLists := desired.NTPServers != nil || desired.DNSServers != nil ||
+		desired.SNMPCommunities != nil || desired.SyslogHosts != nil
+	if managesLists && backup == nil {
+		report.Error = "no configuration backup to compare NTP, DNS, SNMP and syslog settings with"
+	}
+	if managesLists && backup != nil {
+		report.BackupID = backup.ID
+		obs := parseObservedConfig(backup.Lines())
+
+		if item := listSettingDrift("ntp_servers", desired.NTPServers, obs.ntp,
+			func(v string) string { return "ntp server " + v },
+			func(v string) string { return "no ntp server " + v }); item != nil {
+			report.Items = append(report.Items, *item)
+		}
+		if item := listSettingDrift("dns_servers", desired.DNSServers, obs.dns,
+			func(v string) string { return "ip name-server " + v },
+			func(v string) string { return "no ip name-server " + v }); item != nil {
+			report.Items = append(report.Items, *item)
+		}
+
+		if desired.SNMPCommunities != nil {
+			communityKey := func(c SNMPCommunity) string { return c.Name + " " + c.Access }
+			var want, have []string
+			for _, c := range desired.SNMPCommunities {
+				want = append(want, communityKey(c))
+			}
+			for _, c := range obs.snmp {
+				have = append(have, communityKey(c))
+			}
+			if item := listSettingDrift("snmp_communities", want, have,
+				func(v string) string { return "snmp-server community " + v },
+				func(v string) string { return "no snmp-server community " + strings.Fields(v)[0] }); item != nil {
+				// Never echo community strings back in drift reports
+				item.Desired = len(want)
+				item.Observed = len(have)
+				item.Commands = make([]string, len(item.apply))
+				for i, cmd := range item.apply {
+					item.Commands[i] = maskCommunity(cmd)
+				}
+				report.Items = append(report.Items, *item)
+			}
+		}
+
+		if desired.SyslogHosts != nil {
+			used := make(map[string]bool)
+			for _, idx := range obs.syslog {
+				used[idx] = true
+			}
+			nextIndex := func() string {
+				for i := 1; ; i++ {
+					idx := fmt.Sprint(i)
+					if !used[idx] {
+						used[idx] = true
+						return idx
+					}
+				}
+			}
+			if item := listSettingDrift("syslog_hosts", desired.SyslogHosts, obs.syslogIPs,
+				func(v string) string { return fmt.Sprintf("syslog host %s address %s", nextIndex(), v) },
+				func(v string) string {
+					// The removed index can be reused for a new host
+					idx := obs.syslog[v]
+					delete(used, idx)
+					return "no syslog host " + idx
+				}); item != nil {
+				report.Items = append(report.Items, *item)
+			}
+		}
+	}
+
+	report.InSync = len(report.Items) == 0 && report.Error == ""
+	return report
+}
+
+// checkDesiredState compares sw with its desired state after a sync and
+// reconciles automatically if the desired state asks for it
+func (s *Server) checkDesiredState(ctx context.Context, sw *Switch) {
+	desired := s.desiredState.get(sw.ID)
+	if desired == nil {
+		return
+	}
+
+	report := s.updateDrift(sw, desired)
+	if report.InSync || !desired.AutoReconcile || len(report.commands()) == 0 {
+		return
+	}
+	if failed := s.desiredState.lastAutoFailure(sw.ID); time.Since(failed) < reconcileRetryInterval {
+		return
+	}
+
+	action := s.reconcile(ctx, sw, report, "auto", "")
+	s.auditLog.record(ctx, AuditEntry{
+		User:     "system",
+		Action:   "desired_state.reconcile",
+		SwitchID: sw.ID,
+		Result:   action.Result,
+		Details:  gin.H{"trigger": "auto", "fields": action.Fields, "error": action.Error},
+	})
+}
+
+// updateDrift recomputes and stores the drift of sw, publishing an event
+// when the switch goes in or out of sync
+func (s *Server) updateDrift(sw *Switch, desired *DesiredState) *DriftReport {
+	s.mu.RLock()
+	info := SystemInfo{}
+	if sw.SystemInfo != nil {
+		info = *sw.SystemInfo
+	}
+	s.mu.RUnlock()
+
+	report := computeDrift(desired, &info, s.backups.latest(sw.ID))
+
+	s.desiredState.mu.Lock()
+	previous := s.desiredState.drift[sw.ID]
+	s.desiredState.drift[sw.ID] = report
+	s.desiredState.mu.Unlock()
+
+	if previous == nil || previous.InSync != report.InSync || !sameDriftFields(previous, report) {
+		fields := make([]string, len(report.Items))
+		for i, item := range report.Items {
+			fields[i] = item.Field
+		}
+		s.events.Publish(EventSwitchDrift, sw.ID, gin.H{"in_sync": report.InSync, "fields": fields})
+	}
+	return report
+}
+
+func sameDriftFields(a, b *DriftReport) bool {
+	fields := func(r *DriftReport) []string {
+		var f []string
+		for _, item := range r.Items {
+			f = append(f, item.Field)
+		}
+		return f
+	}
+	return reflect.DeepEqual(fields(a), fields(b))
+}
+
+// forRole returns the desired state as role may see it: community strings
+// are masked for everyone but admins
+func (d *DesiredState) forRole(role string) *DesiredState {
+	if role == "admin" || d.SNMPCommunities == nil {
+		return d
+	}
+	masked := *d
+	masked.SNMPCommunities = make([]SNMPCommunity, len(d.SNMPCommunities))
+	for i, community := range d.SNMPCommunities {
+		masked.SNMPCommunities[i] = SNMPCommunity{Name: communityMask, Access: community.Access}
+	}
+	return &masked
+}
+
+// maskCommunity hides the community string of an SNMP community command
+func maskCommunity(cmd string) string {
+	fields := strings.Fields(cmd)
+	if len(fields) > 0 && fields[0] == "no" {
+		fields = fields[1:]
+	}
+	if len(fields) < 3 || fields[0] != "snmp-server" || fields[1] != "community" {
+		return cmd
+	}
+	fields[2] = communityMask
+	masked := strings.Join(fields, " ")
+	if strings.HasPrefix(strings.TrimSpace(cmd), "no ") {
+		masked = "no " + masked
+	}
+	return masked
+}
+
+// reconcile pushes the commands removing the drift in report, then
+// refreshes the observed state so the drift report reflects the result
+func (s *Server) reconcile(ctx context.Context, sw *Switch, report *DriftReport, trigger, user string) ReconcileAction {
+	ctx = switchContext(ctx, sw, "reconcile")
+	logger := logging.FromContext(ctx)
+
+	action := ReconcileAction{
+		Timestamp: time.Now(),
+		Trigger:   trigger,
+		User:      user,
+		Commands:  report.commands(),
+		Result:    "success",
+	}
+	for _, item := range report.Items {
+		action.Fields = append(action.Fields, item.Field)
+	}
+
+	err := s.ensureAuthenticated(ctx, sw)
+	var execution *CLICommandExecution
+	if err == nil {
+		execution, err = s.runCLI(ctx, sw, action.Commands)
+	}
+	if err == nil && !execution.Succeeded() {
+		for _, r := range execution.Commands {
+			if !r.Success {
+				err = fmt.Errorf("command %q failed: %s", r.Command, r.Output)
+				break
+			}
+		}
+	}
+
+	// Community strings must not end up in the stored history
+	for i, cmd := range action.Commands {
+		action.Commands[i] = logging.Scrub(maskCommunity(cmd))
+	}
+
+	if err != nil {
+		action.Result = "failure"
+		action.Error = err.Error()
+		logger.Warn("reconcile failed", "fields", action.Fields, "error", err)
+	} else {
+		logger.Info("reconciled desired state", "fields", action.Fields)
+		if info, err := s.fetchSystemInfo(ctx, sw); err == nil {
+			s.mu.Lock()
+			sw.SystemInfo = info
+			if info.SysName != "" {
+				sw.Name = info.SysName
+			}
+			s.mu.Unlock()
+		}
+		if _, err := s.backupConfig(ctx, sw, "post-change"); err != nil {
+			logger.Warn("config backup after reconcile failed", "error", err)
+		}
+		if desired := s.desiredState.get(sw.ID); desired != nil {
+			s.updateDrift(sw, desired)
+		}
+		s.events.Publish(EventSwitchConfig, sw.ID, gin.H{"reconciled": action.Fields, "changed_by": trigger})
+	}
+
+	s.desiredState.recordAction(sw.ID, action)
+	return action
+}
+
+// getDesiredState returns the desired state of a switch with its current
+// drift and reconcile history
+func (s *Server) getDesiredState(c *gin.Context) {
+	sw, ok := s.lookupSwitch(c)
+	if !ok {
+		return
+	}
+
+	s.desiredState.mu.RLock()
+	desired := s.desiredState.states[sw.ID]
+	drift := s.desiredState.drift[sw.ID]
+	actions := append([]ReconcileAction(nil), s.desiredState.actions[sw.ID]...)
+	s.desiredState.mu.RUnlock()
+
+	if desired == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No desired state for this switch"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"desired_state": desired.forRole(c.GetString("role")), "drift": drift, "actions": actions})
+}
+
+// setDesiredState stores the desired state and checks it against a fresh
+// configuration backup in the background
+func (s *Server) setDesiredState(c *gin.Context) {
+	sw, ok := s.lookupSwitch(c)
+	if !ok {
+		return
+	}
+
+	var desired DesiredState
+	if err := c.ShouldBindJSON(&desired); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+	for _, community := range desired.SNMPCommunities {
+		if community.Name == "" || strings.ContainsAny(community.Name, " \t") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "SNMP community names must be non-empty and contain no spaces"})
+			return
+		}
+		if community.Name == communityMask {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "SNMP community names are masked when read back, give the real names"})
+			return
+		}
+		if community.Access != "ro" && community.Access != "rw" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "SNMP community access must be ro or rw"})
+			return
+		}
+	}
+	for _, list := range [][]string{desired.NTPServers, desired.DNSServers, desired.SyslogHosts} {
+		for _, v := range list {
+			if v == "" || strings.ContainsAny(v, " \t") {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid server address %q", v)})
+				return
+			}
+		}
+	}
+	desired.UpdatedBy = c.GetString("username")
+	desired.UpdatedAt = time.Now()
+
+	s.desiredState.mu.Lock()
+	s.desiredState.states[sw.ID] = &desired
+	s.desiredState.mu.Unlock()
+
+	managed := []string{}
+	for field, set := range map[string]bool{
+		"hostname":         desired.Hostname != "",
+		"location":         desired.Location != "",
+		"contact":          desired.Contact != "",
+		"ntp_servers":      desired.NTPServers != nil,
+		"dns_servers":      desired.DNSServers != nil,
+		"snmp_communities": desired.SNMPCommunities != nil,
+		"syslog_hosts":     desired.SyslogHosts != nil,
+	} {
+		if set {
+			managed = append(managed, field)
+		}
+	}
+	sort.Strings(managed)
+	s.audit(c, "desired_state.update", sw.ID, "success", gin.H{"fields": managed, "auto_reconcile": desired.AutoReconcile})
+
+	ctx := switchContext(detach(c), sw, "desired_state")
+	go func() {
+		if _, err := s.backupConfig(ctx, sw, "manual"); err != nil {
+			logging.FromContext(ctx).Warn("config backup for drift check failed", "error", err)
+		}
+		s.checkDesiredState(ctx, sw)
+	}()
+
+	c.JSON(http.StatusOK, gin.H{"desired_state": desired.forRole(c.GetString("role"))})
+}
+
+func (s *Server) deleteDesiredState(c *gin.Context) {
+	sw, ok := s.lookupSwitch(c)
+	if !ok {
+		return
+	}
+
+	s.desiredState.mu.Lock()
+	delete(s.desiredState.states, sw.ID)
+	delete(s.desiredState.drift, sw.ID)
+	s.desiredState.mu.Unlock()
+
+	s.audit(c, "desired_state.delete", sw.ID, "success", nil)
+	c.JSON(http.StatusOK, gin.H{"message": "Desired state removed"})
+}
+
+// reconcileEndpoint removes the current drift of a switch on request,
+// subject to the caller's CLI policy
+func (s *Server) reconcileEndpoint(c *gin.Context) {
+	sw, ok := s.lookupSwitch(c)
+	if !ok {
+		return
+	}
+
+	desired := s.desiredState.get(sw.ID)
+	if desired == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No desired state for this switch"})
+		return
+	}
+
+	report := s.updateDrift(sw, desired)
+	commands := report.commands()
+	if len(commands) == 0 {
+		c.JSON(http.StatusOK, gin.H{"message": "Switch is in sync", "drift": report})
+		return
+	}
+
+	role := c.GetString("role")
+	for _, cmd := range commands {
+		if err := s.cliPolicy.Check(role, cmd); err != nil {
+			s.audit(c, "desired_state.reconcile", sw.ID, "denied", gin.H{"reason": logging.Scrub(err.Error())})
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	action := s.reconcile(c.Request.Context(), sw, report, "manual", c.GetString("username"))
+	s.audit(c, "desired_state.reconcile", sw.ID, action.Result, gin.H{"trigger": "manual", "fields": action.Fields, "error": action.Error})
+
+	status := http.StatusOK
+	if action.Result != "success" {
+		status = http.StatusBadGateway
+	}
+
+	s.desiredState.mu.RLock()
+	drift := s.desiredState.drift[sw.ID]
+	s.desiredState.mu.RUnlock()
+	c.JSON(status, gin.H{"action": action, "drift": drift})
+}
+
+// SwitchDrift summarizes the drift of one switch for the fleet view
+type SwitchDrift struct {
+	SwitchID      int          `json:"switch_id"`
+	SwitchName    string       `json:"switch_name"`
+	AutoReconcile bool         `json:"auto_reconcile"`
+	Drift         *DriftReport `json:"drift"`
+}
+
+// listDrift reports every switch with a desired state, optionally only
+// those out of sync (?drifted=true)
+func (s *Server) listDrift(c *gin.Context) {
+	onlyDrifted := c.Query("drifted") == "true"
+
+	s.mu.RLock()
+	names := make(map[int]string, len(s.switches))
+	for id, sw := range s.switches {
+		names[id] = sw.Name
+	}
+	s.mu.RUnlock()
+
+	s.desiredState.mu.RLock()
+	results := make([]SwitchDrift, 0, len(s.desiredState.states))
+	for id, desired := range s.desiredState.states {
+		drift := s.desiredState.drift[id]
+		if onlyDrifted && (drift == nil || drift.InSync) {
+			continue
+		}
+		results = append(results, SwitchDrift{
+			SwitchID:      id,
+			SwitchName:    names[id],
+			AutoReconcile: desired.AutoReconcile,
+			Drift:         drift,
+		})
+	}
+	s.desiredState.mu.RUnlock()
+
+	sort.Slice(results, func(i, j int) bool { return results[i].SwitchID < results[j].SwitchID })
+	c.JSON(http.StatusOK, gin.H{"switches": results})
+}
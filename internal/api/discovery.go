@@ -0,0 +1,674 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/JarvisTchibClawBot/OpenExtremeManagement/internal/logging"
+	"github.com/gin-gonic/gin"
+)
+
+// Discovery scan states
+const (
+	DiscoveryRunning   = "running"
+	DiscoveryCompleted = "completed"
+	DiscoveryCancelled = "cancelled"
+)
+
+// Discovered switch states
+const (
+	DiscoveredNew         = "new"         // Can be onboarded
+	DiscoveredExisting    = "existing"    // Already in the inventory
+	DiscoveredAdded       = "added"       // Onboarded by this scan
+	DiscoveredAuthFailed  = "auth_failed" // REST API answered but rejected the credential
+	DiscoveredUnsupported = "unsupported" // Not a Fabric Engine switch
+	DiscoveredDuplicate   = "duplicate"   // Same chassis found at another address
+)
+
+const (
+	maxDiscoveryAddresses   = 1 << 16
+	defaultDiscoveryRate    = 50 // Probes started per second
+	maxDiscoveryRate        = 1000
+	defaultDiscoveryWorkers = 32
+	maxDiscoveryWorkers     = 256
+	defaultProbeTimeout     = 3 * time.Second
+	maxProbeTimeout         = 30 * time.Second
+)
+
+// Ports probed when the request gives none, per scheme
+var (
+	defaultDiscoveryPorts     = []int{443}
+	defaultDiscoveryHTTPPorts = []int{80}
+)
+
+// DiscoveredSwitch is a Fabric Engine REST API found by a scan
+type DiscoveredSwitch struct {
+	IPAddress       string `json:"ip_address"`
+	Port            int    `json:"port"`
+	UseHTTPS        bool   `json:"use_https"`
+	Status          string `json:"status"`
+	SwitchID        int    `json:"switch_id,omitempty"` // Existing or added switch
+	SysName         string `json:"sys_name,omitempty"`
+	ModelName       string `json:"model_name,omitempty"`
+	NosType         string `json:"nos_type,omitempty"`
+	FirmwareVersion string `json:"firmware_version,omitempty"`
+	ChassisID       string `json:"chassis_id,omitempty"`
+	CertFingerprint string `json:"cert_fingerprint,omitempty"` // Certificate seen during the probe
+	Error           string `json:"error,omitempty"`
+
+	cert *CertificateInfo
+}
+
+// DiscoveryScan probes every address of a CIDR range on a set of ports
+type DiscoveryScan struct {
+	ID          int                 `json:"id"`
+	CIDR        string              `json:"cidr"`
+	Ports       []int               `json:"ports"`
+	UseHTTPS    bool                `json:"use_https"`
+	Credential  string              `json:"credential"`
+	Site        string              `json:"site,omitempty"`
+	Tags        []string            `json:"tags,omitempty"`
+	AutoAdd     bool                `json:"auto_add"`
+	Rate        int                 `json:"rate"`
+	Concurrency int                 `json:"concurrency"`
+	Status      string              `json:"status"`
+	Total       int                 `json:"total"`  // Addresses in the range
+	Probed      int                 `json:"probed"` // Addresses done
+	Found       []*DiscoveredSwitch `json:"found"`
+
+	CreatedBy  string     `json:"created_by"`
+	CreatedAt  time.Time  `json:"created_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+
+	cancel  context.CancelFunc
+	timeout time.Duration
+}
+
+// DiscoveryStore keeps scans in memory
+type DiscoveryStore struct {
+	mu     sync.RWMutex
+	scans  map[int]*DiscoveryScan
+	nextID int
+}
+
+func NewDiscoveryStore() *DiscoveryStore {
+	return &DiscoveryStore{scans: make(map[int]*DiscoveryScan), nextID: 1}
+}
+
+// snapshot returns a copy that is safe to serialize while the scan runs
+func (ds *DiscoveryStore) snapshot(scan *DiscoveryScan) DiscoveryScan {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+	copied := *scan
+	copied.Found = make([]*DiscoveredSwitch, len(scan.Found))
+	for i, found := range scan.Found {
+		foundCopy := *found
+		copied.Found[i] = &foundCopy
+	}
+	return copied
+}
+
+// discoveryAddresses lists the host addresses of prefix, leaving out the
+// network and broadcast addresses of IPv4 subnets larger than /31
+func discoveryAddresses(cidr string) ([]netip.Addr, error) {
+	prefix, err := netip.ParsePrefix(cidr)
+	if err != nil {
+		// A single address is a range of one
+		addr, addrErr := netip.ParseAddr(cidr)
+		if addrErr != nil {
+			return nil, fmt.Errorf("invalid CIDR %q", cidr)
+		}
+		return []netip.Addr{addr}, nil
+	}
+	prefix = prefix.Masked()
+	if hostBits := prefix.Addr().BitLen() - prefix.Bits(); hostBits > 16 {
+		return nil, fmt.Errorf("range too large, at most %d addresses per scan", maxDiscoveryAddresses)
+	}
+
+	var addrs []netip.Addr
+	for addr := prefix.Addr(); addr.IsValid() && prefix.Contains(addr); addr = addr.Next() {
+		addrs = append(addrs, addr)
+	}
+	if prefix.Addr().Is4() && prefix.Bits() < 31 {
+		addrs = addrs[1 : len(addrs)-1]
+	}
+	return addrs, nil
+}
+
+// probeSwitch looks for the Fabric Engine REST API on one address and
+// port, logging in with cred. It returns nil if nothing answers like a
+// switch. Certificates are not verified here; the one seen is reported
+// and pinned when the switch is onboarded.
+func probeSwitch(ctx context.Context, addr string, port int, useHTTPS bool, cred Credential, timeout time.Duration) *DiscoveredSwitch {
+	client := &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+			DisableKeepAlives: true,
+		},
+	}
+	probe := &Switch{IPAddress: addr, Port: port, UseHTTPS: useHTTPS}
+
+	body, _ := json.Marshal(map[string]interface{}{"username": cred.Username, "password": cred.Password, "ttl": 60})
+	req, err := http.NewRequestWithContext(ctx, "POST", switchURL(probe, "/auth/token"), bytes.NewReader(body))
+	if err != nil {
+		return nil
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil
+	}
+	defer resp.Body.Close()
+
+	found := &DiscoveredSwitch{IPAddress: addr, Port: port, UseHTTPS: useHTTPS}
+	if resp.TLS != nil && len(resp.TLS.PeerCertificates) > 0 {
+		found.cert = newCertificateInfo(resp.TLS.PeerCertificates[0])
+		found.CertFingerprint = found.cert.Fingerprint
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized, http.StatusForbidden:
+		found.Status = DiscoveredAuthFailed
+		found.Error = fmt.Sprintf("auth failed: status %d", resp.StatusCode)
+		return found
+	default:
+		// Some other web server
+		return nil
+	}
+
+	var auth struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&auth); err != nil || auth.Token == "" {
+		return nil
+	}
+
+	req, err = http.NewRequestWithContext(ctx, "GET", switchURL(probe, "/v0/state/system"), nil)
+	if err != nil {
+		return nil
+	}
+	req.Header.Set("X-Auth-Token", auth.Token)
+	stateResp, err := client.Do(req)
+	if err != nil {
+		found.Status = DiscoveredUnsupported
+		found.Error = "system state unavailable: " + err.Error()
+		return found
+	}
+	defer stateResp.Body.Close()
+
+	var state struct {
+		SysName   string `json:"sysName"`
+		NosType   string `json:"nosType"`
+		ChassisId string `json:"chassisId"`
+		Cards     []struct {
+			ModelName       string `json:"modelName"`
+			FirmwareVersion string `json:"firmwareVersion"`
+		} `json:"cards"`
+	}
+	if stateResp.StatusCode != http.StatusOK || json.NewDecoder(stateResp.Body).Decode(&state) != nil {
+		found.Status = DiscoveredUnsupported
+		found.Error = fmt.Sprintf("system state unavailable: status %d", stateResp.StatusCode)
+		return found
+	}
+
+	found.SysName = state.SysName
+	found.NosType = state.NosType
+	found.ChassisID = state.ChassisId
+	if len(state.Cards) > 0 {
+		found.ModelName = state.Cards[0].ModelName
+		found.FirmwareVersion = state.Cards[0].FirmwareVersion
+	}
+	found.Status = DiscoveredNew
+	if state.NosType != "FABRIC_ENGINE" {
+		found.Status = DiscoveredUnsupported
+		found.Error = "unsupported NOS type " + strconv.Quote(state.NosType)
+	}
+	return found
+}
+
+type DiscoveryRequest struct {
+	CIDR           string   `json:"cidr" binding:"required"`
+	Credential     string   `json:"credential" binding:"required"` // Stored credential to log in with
+	Ports          []int    `json:"ports"`                         // Default 443, or 80 over plain HTTP
+	UseHTTPS       *bool    `json:"use_https"`                     // Default true; false sends the credential in clear text
+	Site           string   `json:"site"`
+	Tags           []string `json:"tags"`
+	AutoAdd        bool     `json:"auto_add"`    // Onboard new switches as they are found
+	Rate           int      `json:"rate"`        // Probes started per second
+	Concurrency    int      `json:"concurrency"` // Probes in flight
+	TimeoutSeconds int      `json:"timeout_seconds"`
+}
+
+// createDiscovery validates the range and starts the scan in the
+// background
+func (s *Server) createDiscovery(c *gin.Context) {
+	var req DiscoveryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	addrs, err := discoveryAddresses(req.CIDR)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(addrs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Range has no host addresses"})
+		return
+	}
+	cred, ok := s.credentials.snapshot()[req.Credential]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Credential not found"})
+		return
+	}
+	if err := validateTags(req.Tags); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Every host in the range is sent the credential, so plain HTTP is
+	// only used when asked for
+	useHTTPS := req.UseHTTPS == nil || *req.UseHTTPS
+	ports := req.Ports
+	if len(ports) == 0 {
+		ports = defaultDiscoveryPorts
+		if !useHTTPS {
+			ports = defaultDiscoveryHTTPPorts
+		}
+	}
+	for _, port := range ports {
+		if port < 1 || port > 65535 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Ports must be between 1 and 65535"})
+			return
+		}
+	}
+
+	scan := &DiscoveryScan{
+		CIDR:        req.CIDR,
+		Ports:       ports,
+		UseHTTPS:    useHTTPS,
+		Credential:  cred.Name,
+		Site:        req.Site,
+		Tags:        req.Tags,
+		AutoAdd:     req.AutoAdd,
+		Rate:        req.Rate,
+		Concurrency: req.Concurrency,
+		Status:      DiscoveryRunning,
+		Total:       len(addrs),
+		Found:       []*DiscoveredSwitch{},
+		CreatedBy:   c.GetString("username"),
+		CreatedAt:   time.Now(),
+		timeout:     time.Duration(req.TimeoutSeconds) * time.Second,
+	}
+	if scan.Rate <= 0 {
+		scan.Rate = defaultDiscoveryRate
+	}
+	if scan.Rate > maxDiscoveryRate {
+		scan.Rate = maxDiscoveryRate
+	}
+	if scan.Concurrency <= 0 {
+		scan.Concurrency = defaultDiscoveryWorkers
+	}
+	if scan.Concurrency > maxDiscoveryWorkers {
+		scan.Concurrency = maxDiscoveryWorkers
+	}
+	if scan.timeout <= 0 {
+		scan.timeout = defaultProbeTimeout
+	}
+	if scan.timeout > maxProbeTimeout {
+		scan.timeout = maxProbeTimeout
+	}
+
+	s.discovery.mu.Lock()
+	scan.ID = s.discovery.nextID
+	s.discovery.nextID++
+	s.discovery.scans[scan.ID] = scan
+	ctx, cancel := context.WithCancel(detach(c))
+	scan.cancel = cancel
+	s.discovery.mu.Unlock()
+
+	go s.runDiscovery(logging.With(ctx, "discovery_id", scan.ID), scan, addrs, cred)
+
+	s.audit(c, "discovery.start", 0, "success", gin.H{"discovery_id": scan.ID, "cidr": scan.CIDR, "ports": ports, "use_https": useHTTPS, "credential": cred.Name, "auto_add": scan.AutoAdd})
+	c.JSON(http.StatusAccepted, gin.H{"discovery": s.discovery.snapshot(scan)})
+}
+
+// runDiscovery probes the addresses at the scan's rate until done or
+// cancelled
+func (s *Server) runDiscovery(ctx context.Context, scan *DiscoveryScan, addrs []netip.Addr, cred Credential) {
+	logger := logging.FromContext(ctx)
+	logger.Info("discovery started", "cidr", scan.CIDR, "addresses", len(addrs))
+
+	work := make(chan netip.Addr)
+	var wg sync.WaitGroup
+	for i := 0; i < scan.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for addr := range work {
+				s.probeAddress(ctx, scan, addr.String(), cred)
+			}
+		}()
+	}
+
+	ticker := time.NewTicker(time.Second / time.Duration(scan.Rate))
+feed:
+	for _, addr := range addrs {
+		select {
+		case <-ctx.Done():
+			break feed
+		case <-ticker.C:
+		}
+		select {
+		case <-ctx.Done():
+			break feed
+		case work <- addr:
+		}
+	}
+	ticker.Stop()
+	close(work)
+	wg.Wait()
+
+	status := DiscoveryCompleted
+	if ctx.Err() != nil {
+		status = DiscoveryCancelled
+	}
+	counts := make(map[string]int)
+	s.discovery.mu.Lock()
+	scan.Status = status
+	now := time.Now()
+	scan.FinishedAt = &now
+	for _, found := range scan.Found {
+		counts[found.Status]++
+	}
+	s.discovery.mu.Unlock()
+	scan.cancel()
+
+	s.auditLog.record(ctx, AuditEntry{
+		User:    "system",
+		Action:  "discovery.finish",
+		Result:  "success",
+		Details: gin.H{"discovery_id": scan.ID, "status": status, "found": counts},
+	})
+	s.events.Publish(EventDiscoveryProgress, 0, gin.H{"discovery_id": scan.ID, "status": status})
+	logger.Info("discovery finished", "status", status, "found", counts)
+}
+
+// probeAddress tries each port of addr and records what answered
+func (s *Server) probeAddress(ctx context.Context, scan *DiscoveryScan, addr string, cred Credential) {
+	defer func() {
+		s.discovery.mu.Lock()
+		scan.Probed++
+		s.discovery.mu.Unlock()
+	}()
+
+	for _, port := range scan.Ports {
+		// Managed switches are not probed again
+		if id, ok := s.switchAt(addr, port); ok {
+			s.recordDiscovered(ctx, scan, &DiscoveredSwitch{IPAddress: addr, Port: port, Status: DiscoveredExisting, SwitchID: id})
+			continue
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		if found := probeSwitch(ctx, addr, port, scan.UseHTTPS, cred, scan.timeout); found != nil {
+			s.recordDiscovered(ctx, scan, found)
+		}
+	}
+}
+
+// switchAt returns the switch managed at addr and port
+func (s *Server) switchAt(addr string, port int) (int, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, sw := range s.switches {
+		if sw.IPAddress == addr && sw.Port == port {
+			return sw.ID, true
+		}
+	}
+	return 0, false
+}
+
+// recordDiscovered adds a probe result to the scan, matching it against
+// the inventory by chassis ID and onboarding it if the scan says so
+func (s *Server) recordDiscovered(ctx context.Context, scan *DiscoveryScan, found *DiscoveredSwitch) {
+	if found.Status == DiscoveredNew && found.ChassisID != "" {
+		s.mu.RLock()
+		for _, sw := range s.switches {
+			if sw.SystemInfo != nil && sw.SystemInfo.ChassisId == found.ChassisID {
+				found.Status = DiscoveredExisting
+				found.SwitchID = sw.ID
+				break
+			}
+		}
+		s.mu.RUnlock()
+	}
+
+	s.discovery.mu.Lock()
+	if found.Status == DiscoveredNew && found.ChassisID != "" {
+		// A switch often answers on several of its addresses
+		for _, other := range scan.Found {
+			if other.ChassisID == found.ChassisID && (other.Status == DiscoveredNew || other.Status == DiscoveredAdded) {
+				found.Status = DiscoveredDuplicate
+				found.Error = fmt.Sprintf("same chassis as %s:%d", other.IPAddress, other.Port)
+				break
+			}
+		}
+	}
+	scan.Found = append(scan.Found, found)
+	s.discovery.mu.Unlock()
+
+	if found.Status == DiscoveredNew {
+		logging.FromContext(ctx).Info("switch discovered", "switch_ip", found.IPAddress, "switch_port", found.Port, "model", found.ModelName)
+		if scan.AutoAdd {
+			s.onboardDiscovered(ctx, scan, found)
+		}
+	}
+	s.events.Publish(EventDiscoveryProgress, found.SwitchID, gin.H{"discovery_id": scan.ID, "ip_address": found.IPAddress, "port": found.Port, "status": found.Status})
+}
+
+// onboardDiscovered adds a discovered switch to the inventory, logging in
+// with the scan's credential and pinning the certificate seen by the probe
+func (s *Server) onboardDiscovered(ctx context.Context, scan *DiscoveryScan, found *DiscoveredSwitch) bool {
+	cred, ok := s.credentials.snapshot()[scan.Credential]
+	if !ok {
+		s.discovery.mu.Lock()
+		found.Error = "credential " + strconv.Quote(scan.Credential) + " no longer exists"
+		s.discovery.mu.Unlock()
+		return false
+	}
+
+	s.mu.Lock()
+	for _, existing := range s.switches {
+		if existing.IPAddress == found.IPAddress && existing.Port == found.Port {
+			s.mu.Unlock()
+			s.discovery.mu.Lock()
+			found.Status = DiscoveredExisting
+			found.SwitchID = existing.ID
+			s.discovery.mu.Unlock()
+			return false
+		}
+	}
+	sw := &Switch{
+		ID:            s.nextID,
+		Name:          fmt.Sprintf("%s:%d", found.IPAddress, found.Port), // Temporary name until sync
+		IPAddress:     found.IPAddress,
+		Port:          found.Port,
+		UseHTTPS:      found.UseHTTPS,
+		Username:      cred.Username,
+		Password:      cred.Password,
+		CredentialRef: cred.Name,
+		Status:        "connecting",
+		TLSMode:       TLSModeTOFU,
+		Site:          scan.Site,
+		Tags:          append([]string(nil), scan.Tags...),
+	}
+	if found.UseHTTPS && found.cert != nil {
+		sw.Certificate = found.cert
+		sw.CertFingerprint = found.cert.Fingerprint
+	}
+	s.switches[sw.ID] = sw
+	s.nextID++
+	s.mu.Unlock()
+
+	s.discovery.mu.Lock()
+	found.Status = DiscoveredAdded
+	found.SwitchID = sw.ID
+	found.Error = ""
+	s.discovery.mu.Unlock()
+
+	s.auditLog.record(ctx, AuditEntry{
+		User:     "system",
+		Action:   "switch.discovered",
+		SwitchID: sw.ID,
+		Result:   "success",
+		Details:  gin.H{"discovery_id": scan.ID, "ip_address": sw.IPAddress, "port": sw.Port, "chassis_id": found.ChassisID},
+	})
+	s.events.Publish(EventSwitchAdded, sw.ID, gin.H{"ip_address": sw.IPAddress, "port": sw.Port})
+	go s.syncSwitch(context.WithoutCancel(ctx), sw)
+	return true
+}
+
+func (s *Server) listDiscoveries(c *gin.Context) {
+	s.discovery.mu.RLock()
+	scans := make([]*DiscoveryScan, 0, len(s.discovery.scans))
+	for _, scan := range s.discovery.scans {
+		scans = append(scans, scan)
+	}
+	s.discovery.mu.RUnlock()
+
+	sort.Slice(scans, func(i, j int) bool { return scans[i].ID > scans[j].ID })
+
+	snapshots := make([]DiscoveryScan, len(scans))
+	for i, scan := range scans {
+		snapshots[i] = s.discovery.snapshot(scan)
+	}
+	c.JSON(http.StatusOK, gin.H{"discoveries": snapshots})
+}
+
+func (s *Server) lookupDiscovery(c *gin.Context) (*DiscoveryScan, bool) {
+	var id int
+	if _, err := fmt.Sscanf(c.Param("id"), "%d", &id); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid discovery ID"})
+		return nil, false
+	}
+
+	s.discovery.mu.RLock()
+	scan, exists := s.discovery.scans[id]
+	s.discovery.mu.RUnlock()
+
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Discovery not found"})
+		return nil, false
+	}
+	return scan, true
+}
+
+func (s *Server) getDiscovery(c *gin.Context) {
+	scan, ok := s.lookupDiscovery(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"discovery": s.discovery.snapshot(scan)})
+}
+
+// cancelDiscovery stops a running scan; probes in flight are abandoned
+func (s *Server) cancelDiscovery(c *gin.Context) {
+	scan, ok := s.lookupDiscovery(c)
+	if !ok {
+		return
+	}
+
+	s.discovery.mu.RLock()
+	status := scan.Status
+	s.discovery.mu.RUnlock()
+	if status != DiscoveryRunning {
+		c.JSON(http.StatusConflict, gin.H{"error": "Discovery is already " + status})
+		return
+	}
+	scan.cancel()
+
+	s.audit(c, "discovery.cancel", 0, "success", gin.H{"discovery_id": scan.ID})
+	c.JSON(http.StatusAccepted, gin.H{"message": "Discovery cancellation requested"})
+}
+
+type OnboardRequest struct {
+	Addresses []string `json:"addresses"` // ip:port of the switches to add; empty adds every new one
+}
+
+// onboardCandidates picks the new switches among found, only those at
+// addresses ("ip:port") when any are given, and lists the addresses that
+// match nothing
+func onboardCandidates(found []*DiscoveredSwitch, addresses []string) (candidates []*DiscoveredSwitch, missing []string) {
+	wanted := make(map[string]bool, len(addresses))
+	for _, addr := range addresses {
+		wanted[addr] = true
+	}
+	matched := make(map[string]bool, len(addresses))
+	for _, f := range found {
+		key := f.IPAddress + ":" + strconv.Itoa(f.Port)
+		if len(wanted) > 0 && !wanted[key] {
+			continue
+		}
+		matched[key] = true
+		if f.Status == DiscoveredNew {
+			candidates = append(candidates, f)
+		}
+	}
+
+	for addr := range wanted {
+		if !matched[addr] {
+			missing = append(missing, addr)
+		}
+	}
+	sort.Strings(missing)
+	return candidates, missing
+}
+
+// onboardDiscoveries adds proposed switches of a scan to the inventory
+func (s *Server) onboardDiscoveries(c *gin.Context) {
+	scan, ok := s.lookupDiscovery(c)
+	if !ok {
+		return
+	}
+
+	var req OnboardRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+	}
+
+	s.discovery.mu.RLock()
+	candidates, missing := onboardCandidates(scan.Found, req.Addresses)
+	s.discovery.mu.RUnlock()
+
+	if len(missing) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Not found by this discovery", "addresses": missing})
+		return
+	}
+
+	ctx := detach(c)
+	var added []int
+	for _, found := range candidates {
+		if s.onboardDiscovered(ctx, scan, found) {
+			added = append(added, found.SwitchID)
+		}
+	}
+
+	s.audit(c, "discovery.onboard", 0, "success", gin.H{"discovery_id": scan.ID, "switch_ids": added})
+	c.JSON(http.StatusOK, gin.H{"added": len(added), "discovery": s.discovery.snapshot(scan)})
+}
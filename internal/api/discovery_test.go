@@ -0,0 +1,44 @@
+package api
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestOnboardCandidates(t *testing.T) {
+	found := []*DiscoveredSwitch{
+		{IPAddress: "10.0.0.1", Port: 443, Status: DiscoveredNew},
+		{IPAddress: "10.0.0.2", Port: 443, Status: DiscoveredExisting},
+		{IPAddress: "10.0.0.3", Port: 443, Status: DiscoveredNew},
+		{IPAddress: "10.0.0.4", Port: 443, Status: DiscoveredNew},
+	}
+
+	tests := []struct {
+		name        string
+		addresses   []string
+		want        []string // ip:port of the candidates
+		wantMissing []string
+	}{
+		{"every new switch", nil, []string{"10.0.0.1:443", "10.0.0.3:443", "10.0.0.4:443"}, nil},
+		{"only the first requested", []string{"10.0.0.1:443"}, []string{"10.0.0.1:443"}, nil},
+		{"only a later one requested", []string{"10.0.0.3:443"}, []string{"10.0.0.3:443"}, nil},
+		{"existing switch is matched but not added", []string{"10.0.0.2:443"}, nil, nil},
+		{"unknown address", []string{"10.0.0.1:443", "10.0.0.9:443"}, []string{"10.0.0.1:443"}, []string{"10.0.0.9:443"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			candidates, missing := onboardCandidates(found, tt.addresses)
+			var got []string
+			for _, c := range candidates {
+				got = append(got, fmt.Sprintf("%s:%d", c.IPAddress, c.Port))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("candidates = %q, want %q", got, tt.want)
+			}
+			if !reflect.DeepEqual(missing, tt.wantMissing) {
+				t.Errorf("missing = %q, want %q", missing, tt.wantMissing)
+			}
+		})
+	}
+}
@@ -0,0 +1,352 @@
+package api
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/JarvisTchibClawBot/OpenExtremeManagement/internal/logging"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// MAC and ARP tables are large and change slowly, so they are read
+	// on every sync only once this much time has passed
+	endpointInterval   = 15 * time.Minute
+	maxEndpointHistory = 20
+	endpointRetention  = 30 * 24 * time.Hour
+)
+
+// EndpointLocation is a switch port where an endpoint was seen, and when
+type EndpointLocation struct {
+	SwitchID   int       `json:"switch_id"`
+	SwitchName string    `json:"switch_name"`
+	Port       string    `json:"port"`
+	VLAN       int       `json:"vlan"`
+	FirstSeen  time.Time `json:"first_seen"`
+	LastSeen   time.Time `json:"last_seen"`
+}
+
+// Endpoint is a MAC address and everywhere it has been seen, oldest
+// location first
+type Endpoint struct {
+	MAC      string             `json:"mac"`
+	LastSeen time.Time          `json:"last_seen"`
+	History  []EndpointLocation `json:"history"`
+}
+
+// location is where the endpoint was seen most recently
+func (e *Endpoint) location() *EndpointLocation {
+	return &e.History[len(e.History)-1]
+}
+
+// arpBinding is the MAC an IP address last resolved to
+type arpBinding struct {
+	mac      string
+	lastSeen time.Time
+}
+
+// EndpointStore indexes the MAC and ARP tables of all switches
+type EndpointStore struct {
+	mu       sync.RWMutex
+	byMAC    map[string]*Endpoint
+	byIP     map[string]arpBinding
+	lastPoll map[int]time.Time
+}
+
+func NewEndpointStore() *EndpointStore {
+	return &EndpointStore{
+		byMAC:    make(map[string]*Endpoint),
+		byIP:     make(map[string]arpBinding),
+		lastPoll: make(map[int]time.Time),
+	}
+}
+
+// claimScheduled reports whether the switch's tables are due and, if so,
+// records the attempt
+func (es *EndpointStore) claimScheduled(switchID int) bool {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+	if time.Since(es.lastPoll[switchID]) < endpointInterval {
+		return false
+	}
+	es.lastPoll[switchID] = time.Now()
+	return true
+}
+
+// forgetSwitch drops the poll schedule of a deleted switch. Locations
+// keep the switch name, so history on it stays searchable.
+func (es *EndpointStore) forgetSwitch(switchID int) {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+	delete(es.lastPoll, switchID)
+}
+
+// macDigits lowercases mac and strips its separators, so any notation,
+// or part of one, can be compared
+func macDigits(mac string) string {
+	return strings.NewReplacer(":", "", "-", "", ".", "").Replace(strings.ToLower(strings.TrimSpace(mac)))
+}
+
+// normalizeMAC accepts colon, dash, dot or unseparated notation and
+// returns lower-case colon notation
+func normalizeMAC(mac string) (string, bool) {
+	hex := macDigits(mac)
+	if len(hex) != 12 {
+		return "", false
+	}
+	hw, err := net.ParseMAC(hex[0:2] + ":" + hex[2:4] + ":" + hex[4:6] + ":" + hex[6:8] + ":" + hex[8:10] + ":" + hex[10:12])
+	if err != nil {
+		return "", false
+	}
+	return hw.String(), true
+}
+
+// FDBEntry is a MAC address learned on a port
+type FDBEntry struct {
+	MAC  string
+	VLAN int
+	Port string
+}
+
+// ARPEntry is an IP address resolved to a MAC address
+type ARPEntry struct {
+	IP  string
+	MAC string
+}
+
+// fetchEndpointTables reads the forwarding database and ARP table of the
+// switch
+func (s *Server) fetchEndpointTables(ctx context.Context, sw *Switch) ([]FDBEntry, []ARPEntry, error) {
+	var fdb []struct {
+		MacAddress string `json:"macAddress"`
+		VlanId     int    `json:"vlanId"`
+		PortName   string `json:"portName"`
+		Type       string `json:"type"`
+	}
+	if err := s.getSwitchState(ctx, sw, "/v0/state/fdb", &fdb); err != nil {
+		return nil, nil, err
+	}
+
+	var arp []struct {
+		IpAddress  string `json:"ipAddress"`
+		MacAddress string `json:"macAddress"`
+	}
+	if err := s.getSwitchState(ctx, sw, "/v0/state/arp", &arp); err != nil {
+		return nil, nil, err
+	}
+
+	var macs []FDBEntry
+	for _, e := range fdb {
+		mac, ok := normalizeMAC(e.MacAddress)
+		// The switch's own addresses are not endpoints
+		if !ok || e.Type == "SELF" || e.PortName == "" {
+			continue
+		}
+		macs = append(macs, FDBEntry{MAC: mac, VLAN: e.VlanId, Port: e.PortName})
+	}
+	var ips []ARPEntry
+	for _, e := range arp {
+		mac, ok := normalizeMAC(e.MacAddress)
+		if !ok || net.ParseIP(e.IpAddress) == nil {
+			continue
+		}
+		ips = append(ips, ARPEntry{IP: e.IpAddress, MAC: mac})
+	}
+	return macs, ips, nil
+}
+
+// uplinkPorts returns, per switch, the ports with a managed switch
+// behind them. Every MAC of the far side is learned there too, so those
+// sightings do not say where an endpoint is plugged in. Caller holds s.mu.
+func (s *Server) uplinkPorts() map[int]map[string]bool {
+	chassis := make(map[string]bool)
+	for _, sw := range s.switches {
+		if sw.SystemInfo != nil && sw.SystemInfo.ChassisId != "" {
+			chassis[strings.ToLower(sw.SystemInfo.ChassisId)] = true
+		}
+	}
+	uplinks := make(map[int]map[string]bool)
+	for _, sw := range s.switches {
+		uplinks[sw.ID] = make(map[string]bool)
+		for _, n := range sw.Neighbors {
+			if chassis[strings.ToLower(n.ChassisID)] {
+				uplinks[sw.ID][n.LocalPort] = true
+			}
+		}
+	}
+	return uplinks
+}
+
+// record merges the tables of one switch. A MAC seen on a different
+// switch or port than before starts a new history entry, which is a move.
+func (es *EndpointStore) record(sw *Switch, switchName string, fdb []FDBEntry, arp []ARPEntry, uplinks map[int]map[string]bool) (learned, moved int) {
+	now := time.Now()
+
+	es.mu.Lock()
+	defer es.mu.Unlock()
+
+	// A trunked server or a phone with a data VLAN is learned once per
+	// VLAN on the same port; one row per MAC and poll is enough
+	seen := make(map[string]bool)
+	for _, e := range fdb {
+		if uplinks[sw.ID][e.Port] || seen[e.MAC] {
+			continue
+		}
+		seen[e.MAC] = true
+		ep, ok := es.byMAC[e.MAC]
+		if !ok {
+			ep = &Endpoint{MAC: e.MAC}
+			es.byMAC[e.MAC] = ep
+			learned++
+		}
+		ep.LastSeen = now
+
+		if n := len(ep.History); n > 0 {
+			loc := ep.location()
+			if loc.SwitchID == sw.ID && loc.Port == e.Port {
+				loc.LastSeen = now
+				loc.SwitchName = switchName
+				loc.VLAN = e.VLAN
+				continue
+			}
+			if uplinks[loc.SwitchID][loc.Port] {
+				// Learned before the uplink was known; not a real location
+				ep.History = ep.History[:n-1]
+			} else {
+				moved++
+			}
+		}
+		ep.History = append(ep.History, EndpointLocation{
+			SwitchID:   sw.ID,
+			SwitchName: switchName,
+			Port:       e.Port,
+			VLAN:       e.VLAN,
+			FirstSeen:  now,
+			LastSeen:   now,
+		})
+		if len(ep.History) > maxEndpointHistory {
+			ep.History = ep.History[len(ep.History)-maxEndpointHistory:]
+		}
+	}
+
+	// An address handed to another device simply maps to the new MAC
+	for _, e := range arp {
+		es.byIP[e.IP] = arpBinding{mac: e.MAC, lastSeen: now}
+	}
+
+	// Endpoints and addresses gone for long are dropped
+	for mac, ep := range es.byMAC {
+		if now.Sub(ep.LastSeen) > endpointRetention {
+			delete(es.byMAC, mac)
+		}
+	}
+	for ip, binding := range es.byIP {
+		if now.Sub(binding.lastSeen) > endpointRetention {
+			delete(es.byIP, ip)
+		}
+	}
+	return learned, moved
+}
+
+// ipsOf returns the IP addresses that resolve to mac. Caller holds es.mu.
+func (es *EndpointStore) ipsOf(mac string) []string {
+	ips := []string{}
+	for ip, binding := range es.byIP {
+		if binding.mac == mac {
+			ips = append(ips, ip)
+		}
+	}
+	sort.Strings(ips)
+	return ips
+}
+
+// collectEndpoints reads and records the MAC and ARP tables of sw
+func (s *Server) collectEndpoints(ctx context.Context, sw *Switch) error {
+	fdb, arp, err := s.fetchEndpointTables(ctx, sw)
+	if err != nil {
+		return err
+	}
+
+	s.mu.RLock()
+	uplinks := s.uplinkPorts()
+	name := sw.Name
+	s.mu.RUnlock()
+
+	learned, moved := s.endpoints.record(sw, name, fdb, arp, uplinks)
+	logging.FromContext(ctx).Info("endpoint tables collected", "macs", len(fdb), "arp", len(arp), "learned", learned, "moved", moved)
+	return nil
+}
+
+// collectEndpointsEndpoint reads the tables of one switch now instead of
+// at the next scheduled poll
+func (s *Server) collectEndpointsEndpoint(c *gin.Context) {
+	sw, ok := s.lookupSwitch(c)
+	if !ok {
+		return
+	}
+
+	ctx := switchContext(c.Request.Context(), sw, "endpoints")
+	if err := s.ensureAuthenticated(ctx, sw); err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Switch authentication failed: " + err.Error()})
+		return
+	}
+	if err := s.collectEndpoints(ctx, sw); err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to collect endpoint tables: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Endpoint tables collected"})
+}
+
+// searchEndpoint finds where a MAC (?mac) or IP (?ip) address was last
+// seen, with the history of its moves
+func (s *Server) searchEndpoint(c *gin.Context) {
+	macQuery, ipQuery := c.Query("mac"), strings.TrimSpace(c.Query("ip"))
+	if (macQuery == "") == (ipQuery == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Give either mac or ip"})
+		return
+	}
+
+	s.endpoints.mu.RLock()
+	defer s.endpoints.mu.RUnlock()
+
+	var mac string
+	if macQuery != "" {
+		normalized, ok := normalizeMAC(macQuery)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid MAC address"})
+			return
+		}
+		mac = normalized
+	} else {
+		if net.ParseIP(ipQuery) == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid IP address"})
+			return
+		}
+		mac = s.endpoints.byIP[ipQuery].mac
+	}
+
+	ep, ok := s.endpoints.byMAC[mac]
+	if !ok || len(ep.History) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Endpoint not found"})
+		return
+	}
+
+	// Newest first, as the helpdesk reads it
+	history := make([]EndpointLocation, len(ep.History))
+	for i, loc := range ep.History {
+		history[len(history)-1-i] = loc
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"mac":       ep.MAC,
+		"ips":       s.endpoints.ipsOf(ep.MAC),
+		"location":  history[0],
+		"last_seen": ep.LastSeen,
+		"moves":     len(history) - 1,
+		"history":   history,
+	})
+}
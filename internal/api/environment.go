@@ -0,0 +1,239 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Switch status when it is reachable but its environment has a critical
+// issue, such as a failed power supply
+const StatusDegraded = "degraded"
+
+// Environment component states
+const (
+	ComponentOK         = "ok"
+	ComponentFailed     = "failed"
+	ComponentNotPresent = "not_present" // Empty slot, not a fault
+)
+
+// Environment issue severities
+const (
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+// Health score penalties. A failed fan is covered by the others, a failed
+// power supply leaves the switch without redundancy.
+const (
+	penaltyFan         = 15
+	penaltyPSU         = 40
+	penaltyTempWarning = 10
+	penaltyTempCrit    = 40
+)
+
+// Fan is one fan tray or fan of a unit
+type Fan struct {
+	Unit   int    `json:"unit"` // Slot or stack unit
+	ID     int    `json:"id"`
+	Status string `json:"status"`
+	RPM    int    `json:"rpm,omitempty"`
+}
+
+// PowerSupply is one power supply bay of a unit
+type PowerSupply struct {
+	Unit   int    `json:"unit"`
+	ID     int    `json:"id"`
+	Status string `json:"status"`
+	Model  string `json:"model,omitempty"`
+}
+
+// TemperatureSensor is a temperature reading with the switch's own limits
+type TemperatureSensor struct {
+	Unit     int     `json:"unit"`
+	Name     string  `json:"name"`
+	Celsius  float64 `json:"celsius"`
+	Warning  float64 `json:"warning,omitempty"`  // °C, 0 if the switch reports none
+	Critical float64 `json:"critical,omitempty"` // °C, 0 if the switch reports none
+	Status   string  `json:"status"`             // ok, warning or critical
+}
+
+// EnvironmentIssue is a component that needs attention
+type EnvironmentIssue struct {
+	Component string `json:"component"` // fan, power_supply or temperature
+	Unit      int    `json:"unit"`
+	Name      string `json:"name"`
+	Severity  string `json:"severity"`
+	Message   string `json:"message"`
+}
+
+// EnvironmentInfo is the chassis health of a switch. Score runs from 100,
+// nothing wrong, down to 0.
+type EnvironmentInfo struct {
+	Fans          []Fan               `json:"fans"`
+	PowerSupplies []PowerSupply       `json:"power_supplies"`
+	Sensors       []TemperatureSensor `json:"sensors"`
+	Score         int                 `json:"score"`
+	Issues        []EnvironmentIssue  `json:"issues"`
+}
+
+// Degraded reports whether an issue is critical enough to flag the switch
+func (e *EnvironmentInfo) Degraded() bool {
+	for _, issue := range e.Issues {
+		if issue.Severity == SeverityCritical {
+			return true
+		}
+	}
+	return false
+}
+
+// assess derives the issues and health score from the components
+func (e *EnvironmentInfo) assess() {
+	e.Issues = []EnvironmentIssue{}
+	score := 100
+
+	for _, f := range e.Fans {
+		if f.Status == ComponentFailed {
+			score -= penaltyFan
+			e.Issues = append(e.Issues, EnvironmentIssue{
+				Component: "fan", Unit: f.Unit, Name: fmt.Sprintf("Fan %d", f.ID),
+				Severity: SeverityWarning, Message: "fan failed",
+			})
+		}
+	}
+	for _, p := range e.PowerSupplies {
+		if p.Status == ComponentFailed {
+			score -= penaltyPSU
+			e.Issues = append(e.Issues, EnvironmentIssue{
+				Component: "power_supply", Unit: p.Unit, Name: fmt.Sprintf("PSU %d", p.ID),
+				Severity: SeverityCritical, Message: "power supply failed",
+			})
+		}
+	}
+	for i := range e.Sensors {
+		t := &e.Sensors[i]
+		t.Status = ComponentOK
+		var limit float64
+		switch {
+		case t.Critical > 0 && t.Celsius >= t.Critical:
+			t.Status, limit = SeverityCritical, t.Critical
+			score -= penaltyTempCrit
+		case t.Warning > 0 && t.Celsius >= t.Warning:
+			t.Status, limit = SeverityWarning, t.Warning
+			score -= penaltyTempWarning
+		default:
+			continue
+		}
+		e.Issues = append(e.Issues, EnvironmentIssue{
+			Component: "temperature", Unit: t.Unit, Name: t.Name,
+			Severity: t.Status, Message: fmt.Sprintf("%.1f °C, limit %.1f °C", t.Celsius, limit),
+		})
+	}
+
+	if score < 0 {
+		score = 0
+	}
+	e.Score = score
+}
+
+// fetchEnvironment reads fan, power supply and temperature state from the
+// switch
+func (s *Server) fetchEnvironment(ctx context.Context, sw *Switch) (*EnvironmentInfo, error) {
+	var state struct {
+		Fans []struct {
+			SlotNumber int    `json:"slotNumber"`
+			FanId      int    `json:"fanId"`
+			OperStatus string `json:"operStatus"`
+			SpeedRpm   int    `json:"speedRpm"`
+		} `json:"fans"`
+		PowerSupplies []struct {
+			SlotNumber int    `json:"slotNumber"`
+			PsuId      int    `json:"psuId"`
+			OperStatus string `json:"operStatus"`
+			ModelName  string `json:"modelName"`
+		} `json:"powerSupplies"`
+		TemperatureSensors []struct {
+			SlotNumber        int     `json:"slotNumber"`
+			Name              string  `json:"name"`
+			Temperature       float64 `json:"temperature"`
+			WarningThreshold  float64 `json:"warningThreshold"`
+			CriticalThreshold float64 `json:"criticalThreshold"`
+		} `json:"temperatureSensors"`
+	}
+
+	if err := s.getSwitchState(ctx, sw, "/v0/state/environment", &state); err != nil {
+		return nil, err
+	}
+
+	// The switch reports UP, DOWN and NOT_PRESENT
+	component := func(operStatus string) string {
+		switch operStatus {
+		case "UP":
+			return ComponentOK
+		case "NOT_PRESENT":
+			return ComponentNotPresent
+		}
+		return ComponentFailed
+	}
+
+	env := &EnvironmentInfo{
+		Fans:          make([]Fan, len(state.Fans)),
+		PowerSupplies: make([]PowerSupply, len(state.PowerSupplies)),
+		Sensors:       make([]TemperatureSensor, len(state.TemperatureSensors)),
+	}
+	for i, f := range state.Fans {
+		env.Fans[i] = Fan{Unit: f.SlotNumber, ID: f.FanId, Status: component(f.OperStatus), RPM: f.SpeedRpm}
+	}
+	for i, p := range state.PowerSupplies {
+		env.PowerSupplies[i] = PowerSupply{Unit: p.SlotNumber, ID: p.PsuId, Status: component(p.OperStatus), Model: p.ModelName}
+	}
+	for i, t := range state.TemperatureSensors {
+		env.Sensors[i] = TemperatureSensor{
+			Unit:     t.SlotNumber,
+			Name:     t.Name,
+			Celsius:  t.Temperature,
+			Warning:  t.WarningThreshold,
+			Critical: t.CriticalThreshold,
+		}
+	}
+	sort.Slice(env.Fans, func(i, j int) bool {
+		a, b := env.Fans[i], env.Fans[j]
+		return a.Unit < b.Unit || a.Unit == b.Unit && a.ID < b.ID
+	})
+	sort.Slice(env.PowerSupplies, func(i, j int) bool {
+		a, b := env.PowerSupplies[i], env.PowerSupplies[j]
+		return a.Unit < b.Unit || a.Unit == b.Unit && a.ID < b.ID
+	})
+	env.assess()
+	return env, nil
+}
+
+// getEnvironment returns the fans, power supplies and temperature sensors
+// of a switch with its health score
+func (s *Server) getEnvironment(c *gin.Context) {
+	sw, ok := s.lookupSwitch(c)
+	if !ok {
+		return
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if sw.Environment == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Environment not collected yet"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"switch_id":      sw.ID,
+		"status":         sw.Status,
+		"score":          sw.Environment.Score,
+		"issues":         sw.Environment.Issues,
+		"fans":           sw.Environment.Fans,
+		"power_supplies": sw.Environment.PowerSupplies,
+		"sensors":        sw.Environment.Sensors,
+		"collected_at":   sw.LastSync,
+	})
+}
@@ -0,0 +1,223 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+// Event types published on the event stream
+const (
+	EventSwitchStatus      = "switch.status"
+	EventSwitchSynced      = "switch.synced"
+	EventSwitchConfig      = "switch.config_changed"
+	EventSwitchAdded       = "switch.added"
+	EventSwitchDeleted     = "switch.deleted"
+	EventSwitchDrift       = "switch.drift"
+	EventJobProgress       = "job.progress"
+	EventChangeProgress    = "change.progress"
+	EventUpgradeProgress   = "upgrade.progress"
+	EventDiscoveryProgress = "discovery.progress"
+	EventTransceiverAlert  = "transceiver.alert"
+)
+
+// Redis channel used to fan out events between backend instances
+const eventsChannel = "oem:events"
+
+// Event is a single notification on the event stream
+type Event struct {
+	Type      string      `json:"type"`
+	SwitchID  int         `json:"switch_id,omitempty"`
+	Timestamp time.Time   `json:"timestamp"`
+	Data      interface{} `json:"data,omitempty"`
+	Origin    string      `json:"origin"`
+}
+
+// EventHub delivers events to local subscribers and, when Redis is
+// configured, to the other backend instances
+type EventHub struct {
+	instanceID  string
+	redis       *redis.Client
+	redisDown   atomic.Bool
+	mu          sync.RWMutex
+	subscribers map[chan Event]struct{}
+}
+
+func NewEventHub(redisURL string) *EventHub {
+	b := make([]byte, 8)
+	rand.Read(b)
+
+	hub := &EventHub{
+		instanceID:  hex.EncodeToString(b),
+		subscribers: make(map[chan Event]struct{}),
+	}
+
+	if redisURL == "" {
+		return hub
+	}
+
+	opts, err := redis.ParseURL(redisURL)
+	if err != nil {
+		slog.Warn("invalid REDIS_URL, events stay local to this instance", "error", err)
+		return hub
+	}
+	hub.redis = redis.NewClient(opts)
+	go hub.relay()
+
+	return hub
+}
+
+// Subscribe registers a new listener. The returned function must be called
+// to release it.
+func (h *EventHub) Subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, 64)
+
+	h.mu.Lock()
+	h.subscribers[ch] = struct{}{}
+	h.mu.Unlock()
+
+	return ch, func() {
+		h.mu.Lock()
+		delete(h.subscribers, ch)
+		h.mu.Unlock()
+	}
+}
+
+// Publish sends an event to local subscribers and to Redis
+func (h *EventHub) Publish(eventType string, switchID int, data interface{}) {
+	event := Event{
+		Type:      eventType,
+		SwitchID:  switchID,
+		Timestamp: time.Now(),
+		Data:      data,
+		Origin:    h.instanceID,
+	}
+
+	h.broadcast(event)
+
+	if h.redis != nil {
+		payload, err := json.Marshal(event)
+		if err != nil {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		// Only log transitions so an unavailable Redis doesn't flood the log
+		if err := h.redis.Publish(ctx, eventsChannel, payload).Err(); err != nil {
+			if !h.redisDown.Swap(true) {
+				slog.Warn("failed to publish event to Redis, events stay local until it recovers", "error", err)
+			}
+		} else if h.redisDown.Swap(false) {
+			slog.Info("publishing events to Redis again")
+		}
+	}
+}
+
+// broadcast delivers to local subscribers. Slow subscribers drop events
+// rather than blocking the publisher.
+func (h *EventHub) broadcast(event Event) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for ch := range h.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// relay forwards events published by other instances to local subscribers.
+// go-redis reconnects the subscription on its own if Redis goes away.
+func (h *EventHub) relay() {
+	pubsub := h.redis.Subscribe(context.Background(), eventsChannel)
+	defer pubsub.Close()
+
+	for msg := range pubsub.Channel() {
+		var event Event
+		if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil {
+			continue
+		}
+		// Our own events were already delivered locally
+		if event.Origin == h.instanceID {
+			continue
+		}
+		h.broadcast(event)
+	}
+}
+
+// setSwitchStatus updates the switch status and publishes the transition
+func (s *Server) setSwitchStatus(sw *Switch, status string) {
+	s.mu.Lock()
+	previous := sw.Status
+	sw.Status = status
+	s.mu.Unlock()
+
+	if previous != status {
+		s.events.Publish(EventSwitchStatus, sw.ID, gin.H{
+			"previous": previous,
+			"status":   status,
+		})
+	}
+}
+
+// streamEvents serves the event stream as Server-Sent Events. Clients can
+// narrow it down with ?switch_id= and a comma-separated ?types= list.
+func (s *Server) streamEvents(c *gin.Context) {
+	var switchID int
+	if idStr := c.Query("switch_id"); idStr != "" {
+		if _, err := fmt.Sscanf(idStr, "%d", &switchID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid switch ID"})
+			return
+		}
+	}
+
+	types := make(map[string]bool)
+	if t := c.Query("types"); t != "" {
+		for _, eventType := range strings.Split(t, ",") {
+			types[strings.TrimSpace(eventType)] = true
+		}
+	}
+
+	events, unsubscribe := s.events.Subscribe()
+	defer unsubscribe()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // Disable nginx buffering
+
+	heartbeat := time.NewTicker(30 * time.Second)
+	defer heartbeat.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case event := <-events:
+			if switchID != 0 && event.SwitchID != switchID {
+				return true
+			}
+			if len(types) > 0 && !types[event.Type] {
+				return true
+			}
+			c.SSEvent(event.Type, event)
+			return true
+		case <-heartbeat.C:
+			c.SSEvent("ping", gin.H{"timestamp": time.Now()})
+			return true
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+}
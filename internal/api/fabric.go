@@ -0,0 +1,356 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// NosFabricEngine is the NosType of switches running Fabric Engine, the
+// only ones with SPBM state to collect
+const NosFabricEngine = "FABRIC_ENGINE"
+
+// ISISAdjacency is an IS-IS adjacency of a switch
+type ISISAdjacency struct {
+	Port             string `json:"port"`
+	NeighborSystemID string `json:"neighbor_system_id"`
+	NeighborHostName string `json:"neighbor_host_name,omitempty"`
+	State            string `json:"state"`
+}
+
+// ISIDAssignment is an I-SID configured on a switch and the customer VLAN
+// it carries, 0 for transparent services
+type ISIDAssignment struct {
+	ISID int    `json:"isid"`
+	Type string `json:"type"`
+	VLAN int    `json:"vlan,omitempty"`
+	Name string `json:"name,omitempty"`
+}
+
+// FabricInfo is the SPBM state of a Fabric Engine switch
+type FabricInfo struct {
+	Nickname    string           `json:"nickname"`
+	SystemID    string           `json:"system_id"` // Nodal B-MAC
+	BVLANs      []int            `json:"bvlans"`
+	Adjacencies []ISISAdjacency  `json:"adjacencies"`
+	ISIDs       []ISIDAssignment `json:"isids"`
+	CollectedAt time.Time        `json:"collected_at"`
+}
+
+// fetchFabric reads the SPBM nodal parameters, IS-IS adjacencies and
+// I-SIDs from the switch
+func (s *Server) fetchFabric(ctx context.Context, sw *Switch) (*FabricInfo, error) {
+	var spbm struct {
+		Nickname string `json:"nickname"`
+		SystemId string `json:"systemId"`
+		BVLANs   []int  `json:"bvlans"`
+	}
+	if err := s.getSwitchState(ctx, sw, "/v0/state/spbm", &spbm); err != nil {
+		return nil, err
+	}
+
+	var adjacencies []struct {
+		Interface        string `json:"interface"`
+		NeighborSystemId string `json:"neighborSystemId"`
+		NeighborHostName string `json:"neighborHostName"`
+		State            string `json:"state"`
+	}
+	if err := s.getSwitchState(ctx, sw, "/v0/state/isis/adjacencies", &adjacencies); err != nil {
+		return nil, err
+	}
+
+	var isids []struct {
+		Isid  int    `json:"isid"`
+		Type  string `json:"type"`
+		Cvlan int    `json:"cvlan"`
+		Name  string `json:"name"`
+	}
+	if err := s.getSwitchState(ctx, sw, "/v0/state/isids", &isids); err != nil {
+		return nil, err
+	}
+
+	info := &FabricInfo{
+		Nickname:    spbm.Nickname,
+		SystemID:    strings.ToLower(spbm.SystemId),
+		BVLANs:      spbm.BVLANs,
+		Adjacencies: make([]ISISAdjacency, len(adjacencies)),
+		ISIDs:       make([]ISIDAssignment, len(isids)),
+		CollectedAt: time.Now(),
+	}
+	for i, a := range adjacencies {
+		info.Adjacencies[i] = ISISAdjacency{
+			// Adjacencies name their interface "Port1/2"
+			Port:             strings.TrimPrefix(a.Interface, "Port"),
+			NeighborSystemID: strings.ToLower(a.NeighborSystemId),
+			NeighborHostName: a.NeighborHostName,
+			State:            a.State,
+		}
+	}
+	for i, a := range isids {
+		info.ISIDs[i] = ISIDAssignment{ISID: a.Isid, Type: a.Type, VLAN: a.Cvlan, Name: a.Name}
+	}
+	sort.Slice(info.ISIDs, func(i, j int) bool { return info.ISIDs[i].ISID < info.ISIDs[j].ISID })
+	return info, nil
+}
+
+// getSwitchFabric returns the SPBM state collected at the last sync
+func (s *Server) getSwitchFabric(c *gin.Context) {
+	sw, ok := s.lookupSwitch(c)
+	if !ok {
+		return
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if sw.SystemInfo != nil && sw.SystemInfo.NosType != NosFabricEngine {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Switch does not run Fabric Engine"})
+		return
+	}
+	if sw.Fabric == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Fabric state not collected yet"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"switch_id": sw.ID, "fabric": sw.Fabric})
+}
+
+// Fabric issue types
+const (
+	FabricMissingAdjacency = "missing_adjacency"  // Link between fabric switches without an IS-IS adjacency
+	FabricAdjacencyDown    = "adjacency_down"     // Adjacency not in the UP state
+	FabricISIDMismatch     = "isid_vlan_mismatch" // I-SID mapped to different VLANs
+	FabricNicknameConflict = "nickname_conflict"  // Nickname used by more than one switch
+	FabricBVLANMismatch    = "bvlan_mismatch"     // B-VLANs differ from the rest of the fabric
+)
+
+// FabricIssue is a problem found in the fabric-wide view
+type FabricIssue struct {
+	Type     string `json:"type"`
+	SwitchID int    `json:"switch_id,omitempty"`
+	PeerID   int    `json:"peer_switch_id,omitempty"`
+	Port     string `json:"port,omitempty"`
+	ISID     int    `json:"isid,omitempty"`
+	Nickname string `json:"nickname,omitempty"`
+	Details  string `json:"details"`
+}
+
+// FabricNode is one Fabric Engine switch in the fabric-wide view
+type FabricNode struct {
+	SwitchID    int    `json:"switch_id"`
+	SwitchName  string `json:"switch_name"`
+	Site        string `json:"site,omitempty"`
+	Nickname    string `json:"nickname"`
+	SystemID    string `json:"system_id"`
+	BVLANs      []int  `json:"bvlans"`
+	Adjacencies int    `json:"adjacencies"` // In the UP state
+	ISIDs       int    `json:"isids"`
+}
+
+// ISIDMember is a switch on which an I-SID is configured
+type ISIDMember struct {
+	SwitchID   int    `json:"switch_id"`
+	SwitchName string `json:"switch_name"`
+	VLAN       int    `json:"vlan,omitempty"`
+	Type       string `json:"type"`
+}
+
+// FabricService is an I-SID and the switches it is configured on
+type FabricService struct {
+	ISID     int          `json:"isid"`
+	Name     string       `json:"name,omitempty"`
+	VLANs    []int        `json:"vlans"` // C-VLANs; transparent members have none
+	Mismatch bool         `json:"mismatch"`
+	Switches []ISIDMember `json:"switches"`
+}
+
+// FabricView is the fabric-wide view served by /fabric
+type FabricView struct {
+	Nodes    []FabricNode    `json:"nodes"`
+	Services []FabricService `json:"services"`
+	Issues   []FabricIssue   `json:"issues"`
+}
+
+// buildFabricView correlates the SPBM state of all Fabric Engine switches.
+// LLDP links between two of them are expected to carry an adjacency.
+func (s *Server) buildFabricView() FabricView {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var fabric []*Switch
+	for _, sw := range s.switches {
+		if sw.Fabric != nil && sw.SystemInfo != nil && sw.SystemInfo.NosType == NosFabricEngine {
+			fabric = append(fabric, sw)
+		}
+	}
+	sort.Slice(fabric, func(i, j int) bool { return fabric[i].ID < fabric[j].ID })
+
+	view := FabricView{Nodes: []FabricNode{}, Services: []FabricService{}, Issues: []FabricIssue{}}
+	byChassis := make(map[string]*Switch)
+	bySystemID := make(map[string]*Switch)
+	nicknames := make(map[string][]int)
+	bvlanCount := make(map[string]int)
+	services := make(map[int]*FabricService)
+
+	for _, sw := range fabric {
+		f := sw.Fabric
+		byChassis[strings.ToLower(sw.SystemInfo.ChassisId)] = sw
+		bySystemID[f.SystemID] = sw
+		nicknames[f.Nickname] = append(nicknames[f.Nickname], sw.ID)
+		bvlanCount[fmt.Sprint(f.BVLANs)]++
+
+		up := 0
+		for _, a := range f.Adjacencies {
+			if a.State == "UP" {
+				up++
+			}
+		}
+		view.Nodes = append(view.Nodes, FabricNode{
+			SwitchID:    sw.ID,
+			SwitchName:  sw.Name,
+			Site:        sw.Site,
+			Nickname:    f.Nickname,
+			SystemID:    f.SystemID,
+			BVLANs:      f.BVLANs,
+			Adjacencies: up,
+			ISIDs:       len(f.ISIDs),
+		})
+
+		for _, a := range f.ISIDs {
+			svc, ok := services[a.ISID]
+			if !ok {
+				svc = &FabricService{ISID: a.ISID, Name: a.Name, VLANs: []int{}}
+				services[a.ISID] = svc
+			}
+			svc.Switches = append(svc.Switches, ISIDMember{SwitchID: sw.ID, SwitchName: sw.Name, VLAN: a.VLAN, Type: a.Type})
+		}
+	}
+
+	// The most common B-VLAN set is taken as the fabric's
+	var fabricBVLANs string
+	for bvlans, n := range bvlanCount {
+		if n > bvlanCount[fabricBVLANs] || (n == bvlanCount[fabricBVLANs] && bvlans < fabricBVLANs) {
+			fabricBVLANs = bvlans
+		}
+	}
+
+	for _, sw := range fabric {
+		f := sw.Fabric
+		if ids := nicknames[f.Nickname]; len(ids) > 1 && ids[0] == sw.ID {
+			view.Issues = append(view.Issues, FabricIssue{
+				Type:     FabricNicknameConflict,
+				SwitchID: sw.ID,
+				Nickname: f.Nickname,
+				Details:  fmt.Sprintf("nickname %s is used by switches %v", f.Nickname, ids),
+			})
+		}
+		if bvlans := fmt.Sprint(f.BVLANs); bvlans != fabricBVLANs {
+			view.Issues = append(view.Issues, FabricIssue{
+				Type:     FabricBVLANMismatch,
+				SwitchID: sw.ID,
+				Details:  fmt.Sprintf("B-VLANs %s, the fabric uses %s", bvlans, fabricBVLANs),
+			})
+		}
+
+		adjacent := make(map[string]ISISAdjacency, len(f.Adjacencies))
+		for _, a := range f.Adjacencies {
+			adjacent[a.Port] = a
+			if a.State != "UP" {
+				issue := FabricIssue{
+					Type:     FabricAdjacencyDown,
+					SwitchID: sw.ID,
+					Port:     a.Port,
+					Details:  fmt.Sprintf("adjacency to %s is %s", a.NeighborSystemID, a.State),
+				}
+				if peer, ok := bySystemID[a.NeighborSystemID]; ok {
+					issue.PeerID = peer.ID
+				}
+				view.Issues = append(view.Issues, issue)
+			}
+		}
+
+		for _, n := range sw.Neighbors {
+			peer, ok := byChassis[strings.ToLower(n.ChassisID)]
+			if !ok || n.Stale {
+				continue
+			}
+			if a, ok := adjacent[n.LocalPort]; ok && a.NeighborSystemID == peer.Fabric.SystemID {
+				continue
+			}
+			view.Issues = append(view.Issues, FabricIssue{
+				Type:     FabricMissingAdjacency,
+				SwitchID: sw.ID,
+				PeerID:   peer.ID,
+				Port:     n.LocalPort,
+				Details:  fmt.Sprintf("LLDP sees %s port %s but there is no IS-IS adjacency", peer.Name, n.PortID),
+			})
+		}
+	}
+
+	for _, svc := range services {
+		vlans := make(map[int]bool)
+		for _, m := range svc.Switches {
+			// Transparent and T-UNI members carry VLAN 0, which mixes
+			// with C-VLAN members of the same service
+			if m.VLAN != 0 {
+				vlans[m.VLAN] = true
+			}
+		}
+		for vlan := range vlans {
+			svc.VLANs = append(svc.VLANs, vlan)
+		}
+		sort.Ints(svc.VLANs)
+		if len(svc.VLANs) > 1 {
+			svc.Mismatch = true
+			view.Issues = append(view.Issues, FabricIssue{
+				Type:    FabricISIDMismatch,
+				ISID:    svc.ISID,
+				Details: fmt.Sprintf("I-SID %d is mapped to VLANs %v", svc.ISID, svc.VLANs),
+			})
+		}
+		view.Services = append(view.Services, *svc)
+	}
+	sort.Slice(view.Services, func(i, j int) bool { return view.Services[i].ISID < view.Services[j].ISID })
+	sort.SliceStable(view.Issues, func(i, j int) bool {
+		a, b := view.Issues[i], view.Issues[j]
+		if a.SwitchID != b.SwitchID {
+			return a.SwitchID < b.SwitchID
+		}
+		if a.Type != b.Type {
+			return a.Type < b.Type
+		}
+		if a.ISID != b.ISID {
+			return a.ISID < b.ISID
+		}
+		return a.Port < b.Port
+	})
+
+	return view
+}
+
+// getFabric serves the fabric-wide SPBM view. ?isid limits the services
+// to one I-SID.
+func (s *Server) getFabric(c *gin.Context) {
+	view := s.buildFabricView()
+
+	if isidStr := c.Query("isid"); isidStr != "" {
+		var isid int
+		if _, err := fmt.Sscanf(isidStr, "%d", &isid); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid isid"})
+			return
+		}
+		services := []FabricService{}
+		for _, svc := range view.Services {
+			if svc.ISID == isid {
+				services = append(services, svc)
+			}
+		}
+		view.Services = services
+	}
+
+	c.JSON(http.StatusOK, view)
+}
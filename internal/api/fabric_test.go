@@ -0,0 +1,67 @@
+package api
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBuildFabricView(t *testing.T) {
+	node := func(id int, nickname string, isids ...ISIDAssignment) *Switch {
+		return &Switch{
+			ID:         id,
+			Name:       nickname,
+			SystemInfo: &SystemInfo{NosType: NosFabricEngine, ChassisId: nickname},
+			Fabric: &FabricInfo{
+				Nickname: nickname,
+				SystemID: "00bb.0000.000" + nickname[len(nickname)-1:],
+				BVLANs:   []int{4051, 4052},
+				ISIDs:    isids,
+			},
+		}
+	}
+	s := &Server{switches: map[int]*Switch{
+		1: node(1, "0.00.01",
+			ISIDAssignment{ISID: 10010, Type: "CVLAN", VLAN: 10},
+			ISIDAssignment{ISID: 10020, Type: "CVLAN", VLAN: 20},
+			ISIDAssignment{ISID: 10030, Type: "CVLAN", VLAN: 30},
+		),
+		2: node(2, "0.00.02",
+			ISIDAssignment{ISID: 10010, Type: "ELAN_TRANSPARENT"},
+			ISIDAssignment{ISID: 10020, Type: "CVLAN", VLAN: 21},
+			ISIDAssignment{ISID: 10030, Type: "CVLAN", VLAN: 31},
+		),
+		3: node(3, "0.00.03",
+			ISIDAssignment{ISID: 10010, Type: "CVLAN", VLAN: 10},
+		),
+	}}
+
+	var first []FabricIssue
+	for i := 0; i < 20; i++ {
+		view := s.buildFabricView()
+		if i == 0 {
+			first = view.Issues
+			continue
+		}
+		if !reflect.DeepEqual(view.Issues, first) {
+			t.Fatalf("issues differ between requests:\n%+v\n%+v", first, view.Issues)
+		}
+	}
+
+	var mismatched []int
+	for _, issue := range first {
+		if issue.Type == FabricISIDMismatch {
+			mismatched = append(mismatched, issue.ISID)
+		}
+	}
+	// 10010 is C-VLAN 10 on two switches and transparent on the third
+	if want := []int{10020, 10030}; !reflect.DeepEqual(mismatched, want) {
+		t.Errorf("mismatched I-SIDs = %v, want %v", mismatched, want)
+	}
+
+	view := s.buildFabricView()
+	for _, svc := range view.Services {
+		if svc.ISID == 10010 && (svc.Mismatch || !reflect.DeepEqual(svc.VLANs, []int{10})) {
+			t.Errorf("I-SID 10010 = %+v, want VLANs [10] without mismatch", svc)
+		}
+	}
+}
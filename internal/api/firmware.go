@@ -0,0 +1,316 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Firmware compliance states
+const (
+	FirmwareCompliant    = "compliant"
+	FirmwareNonCompliant = "noncompliant"
+	FirmwareNoTarget     = "no_target"
+	FirmwareUnknown      = "unknown" // No system info yet, or an unparsable version
+)
+
+// Severities of a switch running older firmware than its target, by the
+// first version component that is behind
+var firmwareSeverityRank = map[string]int{
+	"low":      1, // Patch or build
+	"medium":   2, // One minor release
+	"high":     3, // Two or more minor releases
+	"critical": 4, // Major release
+}
+
+var (
+	targetVersionPattern = regexp.MustCompile(`^\d+(\.\d+)*(\.x)?$`)
+	firmwareVersionStart = regexp.MustCompile(`^\d+(\.\d+)*`)
+)
+
+// FirmwareTarget is the minimum firmware for a model family. Components
+// given as x, and components the version leaves out, match anything, so
+// 9.3.x accepts every 9.3 build.
+type FirmwareTarget struct {
+	Family    string    `json:"family"` // Prefix of the model name, e.g. 5520
+	Version   string    `json:"version"`
+	UpdatedBy string    `json:"updated_by"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// FirmwareStore keeps the target version of each model family
+type FirmwareStore struct {
+	mu      sync.RWMutex
+	targets map[string]*FirmwareTarget
+}
+
+func NewFirmwareStore() *FirmwareStore {
+	return &FirmwareStore{targets: make(map[string]*FirmwareTarget)}
+}
+
+// list returns the targets ordered by family
+func (fs *FirmwareStore) list() []FirmwareTarget {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	targets := make([]FirmwareTarget, 0, len(fs.targets))
+	for _, t := range fs.targets {
+		targets = append(targets, *t)
+	}
+	sort.Slice(targets, func(i, j int) bool { return targets[i].Family < targets[j].Family })
+	return targets
+}
+
+// targetFor returns the target of the longest family matching model
+func targetFor(targets []FirmwareTarget, model string) *FirmwareTarget {
+	var best *FirmwareTarget
+	model = strings.ToLower(model)
+	for i := range targets {
+		t := &targets[i]
+		if strings.HasPrefix(model, strings.ToLower(t.Family)) && (best == nil || len(t.Family) > len(best.Family)) {
+			best = t
+		}
+	}
+	return best
+}
+
+// parseFirmwareVersion returns the numeric components at the start of a
+// version such as 9.3.0.0 or 8.10.1.0_B012
+func parseFirmwareVersion(version string) ([]int, bool) {
+	match := firmwareVersionStart.FindString(strings.TrimSpace(version))
+	if match == "" {
+		return nil, false
+	}
+	var parts []int
+	for _, p := range strings.Split(match, ".") {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return nil, false
+		}
+		parts = append(parts, n)
+	}
+	return parts, true
+}
+
+// firmwareBehind compares version with target and returns the severity if
+// version is older, or "" if it meets the target
+func firmwareBehind(version, target string) (string, bool) {
+	have, ok := parseFirmwareVersion(version)
+	if !ok {
+		return "", false
+	}
+	for i, p := range strings.Split(target, ".") {
+		if p == "x" {
+			break
+		}
+		want, _ := strconv.Atoi(p)
+		got := 0
+		if i < len(have) {
+			got = have[i]
+		}
+		if got > want {
+			return "", true
+		}
+		if got < want {
+			switch {
+			case i == 0:
+				return "critical", true
+			case i == 1 && want-got >= 2:
+				return "high", true
+			case i == 1:
+				return "medium", true
+			default:
+				return "low", true
+			}
+		}
+	}
+	return "", true
+}
+
+// SwitchFirmware is the firmware compliance of one switch
+type SwitchFirmware struct {
+	SwitchID      int    `json:"switch_id"`
+	SwitchName    string `json:"switch_name"`
+	Model         string `json:"model,omitempty"`
+	Version       string `json:"version,omitempty"`
+	Family        string `json:"family,omitempty"`
+	TargetVersion string `json:"target_version,omitempty"`
+	Status        string `json:"status"`
+	Severity      string `json:"severity,omitempty"`
+}
+
+// firmwareStatus evaluates sw against targets; callers hold s.mu
+func firmwareStatus(sw *Switch, targets []FirmwareTarget) SwitchFirmware {
+	result := SwitchFirmware{SwitchID: sw.ID, SwitchName: sw.Name, Status: FirmwareUnknown}
+	if sw.SystemInfo == nil || sw.SystemInfo.ModelName == "" {
+		return result
+	}
+	result.Model = sw.SystemInfo.ModelName
+	result.Version = sw.SystemInfo.FirmwareVersion
+
+	target := targetFor(targets, result.Model)
+	if target == nil {
+		result.Status = FirmwareNoTarget
+		return result
+	}
+	result.Family = target.Family
+	result.TargetVersion = target.Version
+
+	severity, ok := firmwareBehind(result.Version, target.Version)
+	switch {
+	case !ok:
+		result.Status = FirmwareUnknown
+	case severity == "":
+		result.Status = FirmwareCompliant
+	default:
+		result.Status = FirmwareNonCompliant
+		result.Severity = severity
+	}
+	return result
+}
+
+// firmwareFilter matches switches by ?firmware (a status) and
+// ?firmware_severity (a minimum severity)
+type firmwareFilter struct {
+	status      string
+	minSeverity int
+}
+
+func parseFirmwareFilter(status, severity string) (*firmwareFilter, error) {
+	if status == "" && severity == "" {
+		return nil, nil
+	}
+	switch status {
+	case "", FirmwareCompliant, FirmwareNonCompliant, FirmwareNoTarget, FirmwareUnknown:
+	default:
+		return nil, fmt.Errorf("firmware status must be %s, %s, %s or %s", FirmwareCompliant, FirmwareNonCompliant, FirmwareNoTarget, FirmwareUnknown)
+	}
+	f := &firmwareFilter{status: status}
+	if severity != "" {
+		rank, ok := firmwareSeverityRank[severity]
+		if !ok {
+			return nil, fmt.Errorf("severity must be low, medium, high or critical")
+		}
+		f.minSeverity = rank
+	}
+	return f, nil
+}
+
+func (f *firmwareFilter) matches(fw SwitchFirmware) bool {
+	if f.status != "" && fw.Status != f.status {
+		return false
+	}
+	return firmwareSeverityRank[fw.Severity] >= f.minSeverity
+}
+
+// getFirmwareCompliance reports every switch against its target, most
+// severe first. ?status, ?severity (minimum) and ?model narrow the list;
+// the summary always covers the whole fleet.
+func (s *Server) getFirmwareCompliance(c *gin.Context) {
+	filter, err := parseFirmwareFilter(c.Query("status"), c.Query("severity"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	model := c.Query("model")
+
+	targets := s.firmware.list()
+
+	s.mu.RLock()
+	results := make([]SwitchFirmware, 0, len(s.switches))
+	for _, sw := range s.switches {
+		results = append(results, firmwareStatus(sw, targets))
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(results, func(i, j int) bool {
+		ri, rj := firmwareSeverityRank[results[i].Severity], firmwareSeverityRank[results[j].Severity]
+		if ri != rj {
+			return ri > rj
+		}
+		return results[i].SwitchID < results[j].SwitchID
+	})
+
+	summary := map[string]int{FirmwareCompliant: 0, FirmwareNonCompliant: 0, FirmwareNoTarget: 0, FirmwareUnknown: 0}
+	bySeverity := map[string]int{"low": 0, "medium": 0, "high": 0, "critical": 0}
+	filtered := make([]SwitchFirmware, 0, len(results))
+	for _, r := range results {
+		summary[r.Status]++
+		if r.Severity != "" {
+			bySeverity[r.Severity]++
+		}
+		if filter != nil && !filter.matches(r) {
+			continue
+		}
+		if model != "" && !strings.Contains(r.Model, model) {
+			continue
+		}
+		filtered = append(filtered, r)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"summary": summary, "severity": bySeverity, "switches": filtered})
+}
+
+func (s *Server) listFirmwareTargets(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"targets": s.firmware.list()})
+}
+
+type FirmwareTargetRequest struct {
+	Version string `json:"version" binding:"required"`
+}
+
+func (s *Server) setFirmwareTarget(c *gin.Context) {
+	family := strings.TrimSpace(c.Param("family"))
+	if family == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Model family is required"})
+		return
+	}
+
+	var req FirmwareTargetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+	version := strings.ToLower(strings.TrimSpace(req.Version))
+	if !targetVersionPattern.MatchString(version) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Version must look like 9.3.0.0 or 9.3.x"})
+		return
+	}
+
+	target := &FirmwareTarget{
+		Family:    family,
+		Version:   version,
+		UpdatedBy: c.GetString("username"),
+		UpdatedAt: time.Now(),
+	}
+	s.firmware.mu.Lock()
+	s.firmware.targets[family] = target
+	s.firmware.mu.Unlock()
+
+	s.audit(c, "firmware.target.set", 0, "success", gin.H{"family": family, "version": version})
+	c.JSON(http.StatusOK, gin.H{"target": target})
+}
+
+func (s *Server) deleteFirmwareTarget(c *gin.Context) {
+	family := c.Param("family")
+
+	s.firmware.mu.Lock()
+	_, exists := s.firmware.targets[family]
+	delete(s.firmware.targets, family)
+	s.firmware.mu.Unlock()
+
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No target for this model family"})
+		return
+	}
+
+	s.audit(c, "firmware.target.delete", 0, "success", gin.H{"family": family})
+	c.JSON(http.StatusOK, gin.H{"message": "Firmware target deleted"})
+}
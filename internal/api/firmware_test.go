@@ -0,0 +1,59 @@
+package api
+
+import "testing"
+
+func TestFirmwareBehind(t *testing.T) {
+	tests := []struct {
+		version, target string
+		want            string
+		wantOK          bool
+	}{
+		{"9.3.0.0", "9.3.0.0", "", true},
+		{"9.3.1.0", "9.3.0.0", "", true},
+		{"10.0.0.0", "9.3.0.0", "", true},
+		{"8.10.1.0_B012", "9.3.0.0", "critical", true},
+		{"9.1.0.0", "9.3.0.0", "high", true},
+		{"9.2.0.0", "9.3.0.0", "medium", true},
+		{"9.3.0.0", "9.3.1.0", "low", true},
+		{"9.3.0.0", "9.3.0.1", "low", true},
+		{"9.3", "9.3.0.1", "low", true}, // Missing components count as zero
+		{"9.0.0.0", "9.x", "", true},
+		{"8.10.0.0", "9.x", "critical", true},
+		{"9.2.5.0", "9.3.x", "medium", true},
+		{"unknown", "9.3.0.0", "", false},
+		{"", "9.3.0.0", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := firmwareBehind(tt.version, tt.target)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("firmwareBehind(%q, %q) = %q, %v, want %q, %v", tt.version, tt.target, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestTargetFor(t *testing.T) {
+	targets := []FirmwareTarget{
+		{Family: "5520", Version: "9.3.0.0"},
+		{Family: "5520-48", Version: "9.3.1.0"},
+		{Family: "7520", Version: "9.2.0.0"},
+	}
+	tests := []struct {
+		model string
+		want  string // Family, "" for none
+	}{
+		{"5520-24T", "5520"},
+		{"5520-48W", "5520-48"},
+		{"7520-48Y", "7520"},
+		{"5420F-24T", ""},
+	}
+	for _, tt := range tests {
+		got := targetFor(targets, tt.model)
+		family := ""
+		if got != nil {
+			family = got.Family
+		}
+		if family != tt.want {
+			t.Errorf("targetFor(%q) = %q, want %q", tt.model, family, tt.want)
+		}
+	}
+}
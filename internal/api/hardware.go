@@ -0,0 +1,149 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/JarvisTchibClawBot/OpenExtremeManagement/internal/logging"
+	"github.com/gin-gonic/gin"
+)
+
+// Card is one unit of a switch: the only card of a fixed switch, a stack
+// member or a chassis slot
+type Card struct {
+	SlotNumber      int    `json:"slotNumber"`
+	ModelName       string `json:"modelName"`
+	PartNumber      string `json:"partNumber"`
+	SerialNumber    string `json:"serialNumber"`
+	HardwareRev     string `json:"hardwareRev"`
+	BaseMacAddress  string `json:"baseMacAddress"`
+	FirmwareVersion string `json:"firmwareVersion"`
+	NumPorts        int    `json:"numPorts"`
+	AdminStatus     string `json:"adminStatus"`
+	OperationStatus string `json:"operationStatus"`
+}
+
+// cardSerials maps slot numbers to serial numbers
+func cardSerials(cards []Card) map[int]string {
+	serials := make(map[int]string, len(cards))
+	for _, card := range cards {
+		serials[card.SlotNumber] = card.SerialNumber
+	}
+	return serials
+}
+
+// recordHardwareChanges audits cards that were added, removed or swapped
+// since the previous sync, so replacements show up in the audit log
+func (s *Server) recordHardwareChanges(ctx context.Context, sw *Switch, before, after []Card) {
+	if before == nil {
+		return
+	}
+	old, cur := cardSerials(before), cardSerials(after)
+
+	var changes []gin.H
+	for slot, serial := range cur {
+		if prev, ok := old[slot]; !ok {
+			changes = append(changes, gin.H{"slot": slot, "added": serial})
+		} else if prev != serial {
+			changes = append(changes, gin.H{"slot": slot, "removed": prev, "added": serial})
+		}
+	}
+	for slot, serial := range old {
+		if _, ok := cur[slot]; !ok {
+			changes = append(changes, gin.H{"slot": slot, "removed": serial})
+		}
+	}
+	if len(changes) == 0 {
+		return
+	}
+
+	logging.FromContext(ctx).Info("hardware changed", "changes", len(changes))
+	s.auditLog.record(ctx, AuditEntry{
+		User:     "system",
+		Action:   "hardware.change",
+		SwitchID: sw.ID,
+		Result:   "success",
+		Details:  gin.H{"cards": changes},
+	})
+}
+
+// getHardware returns the cards collected at the last sync
+func (s *Server) getHardware(c *gin.Context) {
+	sw, ok := s.lookupSwitch(c)
+	if !ok {
+		return
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if sw.SystemInfo == nil || sw.SystemInfo.Cards == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Hardware inventory not collected yet"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"switch_id":    sw.ID,
+		"chassis_id":   sw.SystemInfo.ChassisId,
+		"model":        sw.SystemInfo.ModelName,
+		"cards":        sw.SystemInfo.Cards,
+		"collected_at": sw.LastSync,
+	})
+}
+
+// HardwareMatch is a card found by the fleet-wide search
+type HardwareMatch struct {
+	SwitchID   int    `json:"switch_id"`
+	SwitchName string `json:"switch_name"`
+	IPAddress  string `json:"ip_address"`
+	Site       string `json:"site,omitempty"`
+	Card       Card   `json:"card"`
+}
+
+// searchHardware lists the cards of every switch. ?serial, ?part_number
+// and ?mac match case-insensitive substrings, ?mac in any notation; ?model
+// a model name substring.
+func (s *Server) searchHardware(c *gin.Context) {
+	filters := []struct {
+		value string
+		field func(Card) string
+	}{
+		{strings.ToLower(c.Query("serial")), func(card Card) string { return strings.ToLower(card.SerialNumber) }},
+		{strings.ToLower(c.Query("part_number")), func(card Card) string { return strings.ToLower(card.PartNumber) }},
+		{macDigits(c.Query("mac")), func(card Card) string { return macDigits(card.BaseMacAddress) }},
+		{strings.ToLower(c.Query("model")), func(card Card) string { return strings.ToLower(card.ModelName) }},
+	}
+
+	s.mu.RLock()
+	matches := []HardwareMatch{}
+	for _, sw := range s.switches {
+		if sw.SystemInfo == nil {
+			continue
+		}
+	cards:
+		for _, card := range sw.SystemInfo.Cards {
+			for _, f := range filters {
+				if f.value != "" && !strings.Contains(f.field(card), f.value) {
+					continue cards
+				}
+			}
+			matches = append(matches, HardwareMatch{
+				SwitchID:   sw.ID,
+				SwitchName: sw.Name,
+				IPAddress:  sw.IPAddress,
+				Site:       sw.Site,
+				Card:       card,
+			})
+		}
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].SwitchID != matches[j].SwitchID {
+			return matches[i].SwitchID < matches[j].SwitchID
+		}
+		return matches[i].Card.SlotNumber < matches[j].Card.SlotNumber
+	})
+	c.JSON(http.StatusOK, gin.H{"cards": matches})
+}
@@ -0,0 +1,49 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestSearchHardwareMAC(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := &Server{switches: map[int]*Switch{
+		1: {ID: 1, Name: "core1", SystemInfo: &SystemInfo{Cards: []Card{
+			{SlotNumber: 1, SerialNumber: "SN1", BaseMacAddress: "00:11:22:33:44:55"},
+			{SlotNumber: 2, SerialNumber: "SN2", BaseMacAddress: "00:11:22:33:44:66"},
+		}}},
+	}}
+
+	tests := []struct {
+		mac  string
+		want int
+	}{
+		{"00:11:22:33:44:55", 1},
+		{"00-11-22-33-44-55", 1},
+		{"0011.2233.4455", 1},
+		{"001122334455", 1},
+		{"00:11:22:33:44", 2}, // Prefix of both
+		{"2233.44", 2},
+		{"00:11:22:33:44:77", 0},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest("GET", "/hardware?mac="+url.QueryEscape(tt.mac), nil)
+		s.searchHardware(c)
+
+		var resp struct {
+			Cards []HardwareMatch `json:"cards"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("mac %q: %v", tt.mac, err)
+		}
+		if len(resp.Cards) != tt.want {
+			t.Errorf("mac %q matched %d cards, want %d", tt.mac, len(resp.Cards), tt.want)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package api
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ComponentHealth is the result of a single readiness check
+type ComponentHealth struct {
+	Status    string `json:"status"` // up, down, skipped
+	LatencyMs int64  `json:"latency_ms"`
+	Detail    string `json:"detail,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+const healthCheckTimeout = 2 * time.Second
+
+// liveness only reports whether the process is serving requests, so the
+// container is not restarted because a dependency is down
+func (s *Server) liveness(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "alive",
+		"service": "OpenExtremeManagement",
+	})
+}
+
+// readiness checks every dependency and returns 503 if any is down, so
+// load balancers stop routing to this instance
+func (s *Server) readiness(c *gin.Context) {
+	checks := map[string]func(context.Context) ComponentHealth{
+		"database":  s.checkDatabase,
+		"redis":     s.checkRedis,
+		"sync_loop": s.checkSyncLoop,
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), healthCheckTimeout)
+	defer cancel()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	components := make(map[string]ComponentHealth, len(checks))
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check func(context.Context) ComponentHealth) {
+			defer wg.Done()
+			start := time.Now()
+			result := check(ctx)
+			result.LatencyMs = time.Since(start).Milliseconds()
+			mu.Lock()
+			components[name] = result
+			mu.Unlock()
+		}(name, check)
+	}
+	wg.Wait()
+
+	status := "ready"
+	code := http.StatusOK
+	for _, component := range components {
+		if component.Status == "down" {
+			status = "not_ready"
+			code = http.StatusServiceUnavailable
+		}
+	}
+
+	c.JSON(code, gin.H{
+		"status":     status,
+		"service":    "OpenExtremeManagement",
+		"components": components,
+		"checked_at": time.Now(),
+	})
+}
+
+// checkDatabase verifies the configured database accepts connections
+func (s *Server) checkDatabase(ctx context.Context) ComponentHealth {
+	if s.config.DatabaseURL == "" {
+		return ComponentHealth{Status: "skipped", Detail: "DATABASE_URL not set"}
+	}
+	return dialCheck(ctx, s.config.DatabaseURL, "5432")
+}
+
+func (s *Server) checkRedis(ctx context.Context) ComponentHealth {
+	if s.events.redis == nil {
+		return ComponentHealth{Status: "skipped", Detail: "REDIS_URL not set"}
+	}
+	if err := s.events.redis.Ping(ctx).Err(); err != nil {
+		return ComponentHealth{Status: "down", Error: err.Error()}
+	}
+	return ComponentHealth{Status: "up"}
+}
+
+// checkSyncLoop fails if the background sync has not ticked for three
+// intervals, which means it is stuck or has exited
+func (s *Server) checkSyncLoop(ctx context.Context) ComponentHealth {
+	last := time.Unix(0, s.syncHeartbeat.Load())
+	age := time.Since(last)
+
+	result := ComponentHealth{Status: "up", Detail: "last tick " + age.Round(time.Second).String() + " ago"}
+	if age > 3*syncInterval {
+		result.Status = "down"
+		result.Error = "sync loop has not run since " + last.Format(time.RFC3339)
+	}
+	return result
+}
+
+// dialCheck opens a TCP connection to the host in a connection URL
+func dialCheck(ctx context.Context, rawURL, defaultPort string) ComponentHealth {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ComponentHealth{Status: "down", Error: "invalid URL: " + err.Error()}
+	}
+
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), defaultPort)
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", host)
+	if err != nil {
+		return ComponentHealth{Status: "down", Error: err.Error()}
+	}
+	conn.Close()
+
+	return ComponentHealth{Status: "up"}
+}
@@ -0,0 +1,254 @@
+package api
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	// Image file names end up in CLI commands, so only plain names pass
+	imageNamePattern    = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+	imageVersionPattern = regexp.MustCompile(`^\d+(\.\d+){1,3}$`)
+	imageVersionInName  = regexp.MustCompile(`\d+(\.\d+){3,}`)
+)
+
+// versionFromFileName returns the last four components of the dotted
+// number in an image name, so 5520.9.3.1.0.tgz gives 9.3.1.0
+func versionFromFileName(name string) string {
+	parts := strings.Split(imageVersionInName.FindString(name), ".")
+	if len(parts) < 4 {
+		return ""
+	}
+	return strings.Join(parts[len(parts)-4:], ".")
+}
+
+// FirmwareImage is a firmware file in the OEM image repository. Switches
+// download it from the public download URL, which carries a per-image
+// token instead of a user session.
+type FirmwareImage struct {
+	ID         int       `json:"id"`
+	FileName   string    `json:"file_name"`
+	Version    string    `json:"version"`
+	Family     string    `json:"family,omitempty"` // Model name prefix the image is built for
+	Size       int64     `json:"size"`
+	SHA256     string    `json:"sha256"`
+	UploadedBy string    `json:"uploaded_by"`
+	UploadedAt time.Time `json:"uploaded_at"`
+
+	path  string
+	token string
+}
+
+// ImageStore indexes the images stored in dir
+type ImageStore struct {
+	mu     sync.RWMutex
+	dir    string
+	images map[int]*FirmwareImage
+	nextID int
+}
+
+func NewImageStore(dir string) *ImageStore {
+	return &ImageStore{dir: dir, images: make(map[int]*FirmwareImage), nextID: 1}
+}
+
+func (is *ImageStore) get(id int) (*FirmwareImage, bool) {
+	is.mu.RLock()
+	defer is.mu.RUnlock()
+	img, ok := is.images[id]
+	return img, ok
+}
+
+// downloadURL is where a switch fetches img, relative to base
+func (img *FirmwareImage) downloadURL(base string) string {
+	return fmt.Sprintf("%s/api/v1/firmware/images/%d/download?token=%s", strings.TrimRight(base, "/"), img.ID, img.token)
+}
+
+// publicBaseURL returns the configured public URL, or the address the
+// request was sent to
+func (s *Server) publicBaseURL(c *gin.Context) string {
+	if s.config.PublicURL != "" {
+		return s.config.PublicURL
+	}
+	scheme := "http"
+	if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + c.Request.Host
+}
+
+func (s *Server) listImages(c *gin.Context) {
+	s.images.mu.RLock()
+	images := make([]*FirmwareImage, 0, len(s.images.images))
+	for _, img := range s.images.images {
+		images = append(images, img)
+	}
+	s.images.mu.RUnlock()
+
+	sort.Slice(images, func(i, j int) bool { return images[i].ID < images[j].ID })
+	c.JSON(http.StatusOK, gin.H{"images": images})
+}
+
+func (s *Server) lookupImage(c *gin.Context) (*FirmwareImage, bool) {
+	var id int
+	if _, err := fmt.Sscanf(c.Param("id"), "%d", &id); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid image ID"})
+		return nil, false
+	}
+	img, ok := s.images.get(id)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Image not found"})
+		return nil, false
+	}
+	return img, true
+}
+
+func (s *Server) getImage(c *gin.Context) {
+	img, ok := s.lookupImage(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"image": img})
+}
+
+// uploadImage stores the multipart "file" field. The version is taken from
+// the "version" field or else from the file name, e.g. 5520.9.3.1.0.tgz.
+func (s *Server) uploadImage(c *gin.Context) {
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A file is required"})
+		return
+	}
+	defer file.Close()
+
+	name := filepath.Base(header.Filename)
+	if !imageNamePattern.MatchString(name) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File names may only contain letters, digits, '.', '-' and '_'"})
+		return
+	}
+	version := strings.TrimSpace(c.PostForm("version"))
+	if version == "" {
+		version = versionFromFileName(name)
+	}
+	if !imageVersionPattern.MatchString(version) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Version must be given, e.g. 9.3.1.0"})
+		return
+	}
+
+	if err := os.MkdirAll(s.images.dir, 0o750); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Image repository unavailable: " + err.Error()})
+		return
+	}
+	tmp, err := os.CreateTemp(s.images.dir, "upload-*")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Image repository unavailable: " + err.Error()})
+		return
+	}
+	defer os.Remove(tmp.Name())
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hash), file)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store image: " + err.Error()})
+		return
+	}
+	sum := hex.EncodeToString(hash.Sum(nil))
+
+	tokenBytes := make([]byte, 16)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store image: " + err.Error()})
+		return
+	}
+
+	img := &FirmwareImage{
+		FileName:   name,
+		Version:    version,
+		Family:     strings.TrimSpace(c.PostForm("family")),
+		Size:       size,
+		SHA256:     sum,
+		UploadedBy: c.GetString("username"),
+		UploadedAt: time.Now(),
+		path:       filepath.Join(s.images.dir, sum[:16]+"-"+name),
+		token:      hex.EncodeToString(tokenBytes),
+	}
+
+	s.images.mu.Lock()
+	for _, existing := range s.images.images {
+		if existing.SHA256 == sum && existing.FileName == name {
+			s.images.mu.Unlock()
+			c.JSON(http.StatusOK, gin.H{"image": existing})
+			return
+		}
+	}
+	if err := os.Rename(tmp.Name(), img.path); err != nil {
+		s.images.mu.Unlock()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store image: " + err.Error()})
+		return
+	}
+	img.ID = s.images.nextID
+	s.images.nextID++
+	s.images.images[img.ID] = img
+	s.images.mu.Unlock()
+
+	s.audit(c, "firmware.image.upload", 0, "success", gin.H{"image_id": img.ID, "file_name": name, "version": version, "sha256": sum})
+	c.JSON(http.StatusCreated, gin.H{"image": img})
+}
+
+func (s *Server) deleteImage(c *gin.Context) {
+	img, ok := s.lookupImage(c)
+	if !ok {
+		return
+	}
+
+	// createUpgrade checks the image under the same lock
+	s.upgrades.mu.Lock()
+	if s.upgrades.imageInUse(img.ID) {
+		s.upgrades.mu.Unlock()
+		c.JSON(http.StatusConflict, gin.H{"error": "Image is used by an upgrade in progress"})
+		return
+	}
+	s.images.mu.Lock()
+	delete(s.images.images, img.ID)
+	s.images.mu.Unlock()
+	s.upgrades.mu.Unlock()
+
+	if err := os.Remove(img.path); err != nil && !os.IsNotExist(err) {
+		s.audit(c, "firmware.image.delete", 0, "failure", gin.H{"image_id": img.ID, "error": err.Error()})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete image file: " + err.Error()})
+		return
+	}
+
+	s.audit(c, "firmware.image.delete", 0, "success", gin.H{"image_id": img.ID, "file_name": img.FileName})
+	c.JSON(http.StatusOK, gin.H{"message": "Image deleted"})
+}
+
+// downloadImage serves an image to a switch that presents its token
+func (s *Server) downloadImage(c *gin.Context) {
+	var id int
+	if _, err := fmt.Sscanf(c.Param("id"), "%d", &id); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid image ID"})
+		return
+	}
+	img, ok := s.images.get(id)
+	if !ok || subtle.ConstantTimeCompare([]byte(c.Query("token")), []byte(img.token)) != 1 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Image not found"})
+		return
+	}
+	c.FileAttachment(img.path, img.FileName)
+}
@@ -0,0 +1,473 @@
+package api
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/yaml.v3"
+)
+
+const maxInventorySize = 5 << 20
+
+var tagPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.:/-]*$`)
+
+// validateTags rejects tags that would not survive a CSV round trip
+func validateTags(tags []string) error {
+	for _, tag := range tags {
+		if !tagPattern.MatchString(tag) {
+			return fmt.Errorf("invalid tag %q", tag)
+		}
+	}
+	return nil
+}
+
+func hasTag(sw *Switch, tag string) bool {
+	for _, t := range sw.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// inventoryColumns is the CSV layout, also accepted in any order
+var inventoryColumns = []string{"ip_address", "port", "use_https", "credential", "site", "tags", "tls_mode"}
+
+// InventoryRow is one switch in an import or export file. Switches are
+// matched on ip_address; on an existing switch, fields left empty keep
+// their current value.
+type InventoryRow struct {
+	IPAddress  string   `json:"ip_address" yaml:"ip_address"`
+	Port       int      `json:"port,omitempty" yaml:"port,omitempty"`
+	UseHTTPS   *bool    `json:"use_https,omitempty" yaml:"use_https,omitempty"`
+	Credential string   `json:"credential,omitempty" yaml:"credential,omitempty"` // Name of a stored credential
+	Site       string   `json:"site,omitempty" yaml:"site,omitempty"`
+	Tags       []string `json:"tags,omitempty" yaml:"tags,omitempty"`
+	TLSMode    string   `json:"tls_mode,omitempty" yaml:"tls_mode,omitempty"`
+
+	parseErrors []string // CSV values that did not parse
+}
+
+// inventoryFile is the wrapped form of JSON and YAML files, as exported
+type inventoryFile struct {
+	Switches []InventoryRow `json:"switches" yaml:"switches"`
+}
+
+// ImportRowResult reports what an import does, or would do, with a row
+type ImportRowResult struct {
+	Row       int      `json:"row"` // 1-based, not counting the CSV header
+	IPAddress string   `json:"ip_address"`
+	Action    string   `json:"action"` // create, update, unchanged or error
+	SwitchID  int      `json:"switch_id,omitempty"`
+	Changes   []string `json:"changes,omitempty"`
+	Errors    []string `json:"errors,omitempty"`
+}
+
+// inventoryFormat picks the format from ?format, then the content type
+func inventoryFormat(c *gin.Context) string {
+	if format := strings.ToLower(c.Query("format")); format != "" {
+		if format == "yml" {
+			return "yaml"
+		}
+		return format
+	}
+	mediaType, _, _ := mime.ParseMediaType(c.GetHeader("Content-Type"))
+	switch mediaType {
+	case "text/csv":
+		return "csv"
+	case "application/json":
+		return "json"
+	case "application/yaml", "application/x-yaml", "text/yaml", "text/x-yaml":
+		return "yaml"
+	}
+	return ""
+}
+
+// parseInventory decodes a whole file. Errors here concern the file as a
+// whole; row-level problems are left to validation.
+func parseInventory(format string, data []byte) ([]InventoryRow, error) {
+	switch format {
+	case "csv":
+		return parseInventoryCSV(data)
+	case "json":
+		var rows []InventoryRow
+		if bytes.HasPrefix(bytes.TrimSpace(data), []byte("[")) {
+			if err := json.Unmarshal(data, &rows); err != nil {
+				return nil, err
+			}
+			return rows, nil
+		}
+		var file inventoryFile
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&file); err != nil {
+			return nil, err
+		}
+		return file.Switches, nil
+	case "yaml":
+		var node yaml.Node
+		if err := yaml.Unmarshal(data, &node); err != nil {
+			return nil, err
+		}
+		if len(node.Content) == 1 && node.Content[0].Kind == yaml.SequenceNode {
+			var rows []InventoryRow
+			if err := node.Decode(&rows); err != nil {
+				return nil, err
+			}
+			return rows, nil
+		}
+		var file inventoryFile
+		if err := node.Decode(&file); err != nil {
+			return nil, err
+		}
+		return file.Switches, nil
+	}
+	return nil, fmt.Errorf("format must be csv, json or yaml")
+}
+
+func parseInventoryCSV(data []byte) ([]InventoryRow, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.TrimLeadingSpace = true
+	r.FieldsPerRecord = -1
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	known := make(map[string]bool, len(inventoryColumns))
+	for _, col := range inventoryColumns {
+		known[col] = true
+	}
+	header := records[0]
+	for i, col := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(col))
+		if !known[header[i]] {
+			return nil, fmt.Errorf("unknown column %q, expected %s", col, strings.Join(inventoryColumns, ", "))
+		}
+	}
+
+	rows := make([]InventoryRow, 0, len(records)-1)
+	for _, record := range records[1:] {
+		var row InventoryRow
+		for i, value := range record {
+			if i >= len(header) {
+				break
+			}
+			value = strings.TrimSpace(value)
+			if value == "" {
+				continue
+			}
+			switch header[i] {
+			case "ip_address":
+				row.IPAddress = value
+			case "port":
+				port, err := strconv.Atoi(value)
+				if err != nil {
+					row.parseErrors = append(row.parseErrors, fmt.Sprintf("invalid port %q", value))
+					continue
+				}
+				row.Port = port
+			case "use_https":
+				b, err := strconv.ParseBool(value)
+				if err != nil {
+					row.parseErrors = append(row.parseErrors, fmt.Sprintf("invalid use_https %q", value))
+					continue
+				}
+				row.UseHTTPS = &b
+			case "credential":
+				row.Credential = value
+			case "site":
+				row.Site = value
+			case "tags":
+				for _, tag := range strings.Split(value, ";") {
+					if tag = strings.TrimSpace(tag); tag != "" {
+						row.Tags = append(row.Tags, tag)
+					}
+				}
+			case "tls_mode":
+				row.TLSMode = value
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// validateInventoryRow returns every problem with row; existing is the
+// switch with the same address, if any
+func validateInventoryRow(row InventoryRow, existing *Switch, creds map[string]Credential) []string {
+	errs := append([]string(nil), row.parseErrors...)
+	if net.ParseIP(row.IPAddress) == nil {
+		errs = append(errs, fmt.Sprintf("invalid ip_address %q", row.IPAddress))
+	}
+	if row.Port < 0 || row.Port > 65535 {
+		errs = append(errs, "port must be between 1 and 65535")
+	}
+	if row.TLSMode != "" && !validTLSMode(row.TLSMode) {
+		errs = append(errs, "tls_mode must be tofu, ca or insecure")
+	}
+	if row.Credential == "" {
+		if existing == nil {
+			errs = append(errs, "credential is required for new switches")
+		}
+	} else if _, ok := creds[row.Credential]; !ok {
+		errs = append(errs, fmt.Sprintf("unknown credential %q", row.Credential))
+	}
+	if err := validateTags(row.Tags); err != nil {
+		errs = append(errs, err.Error())
+	}
+	return errs
+}
+
+// inventoryChanges lists the fields row would change on sw
+func inventoryChanges(row InventoryRow, sw *Switch) []string {
+	var changes []string
+	if row.Port != 0 && row.Port != sw.Port {
+		changes = append(changes, "port")
+	}
+	if row.UseHTTPS != nil && *row.UseHTTPS != sw.UseHTTPS {
+		changes = append(changes, "use_https")
+	}
+	if row.Credential != "" && row.Credential != sw.CredentialRef {
+		changes = append(changes, "credential")
+	}
+	if row.Site != "" && row.Site != sw.Site {
+		changes = append(changes, "site")
+	}
+	if row.Tags != nil && strings.Join(row.Tags, ";") != strings.Join(sw.Tags, ";") {
+		changes = append(changes, "tags")
+	}
+	if row.TLSMode != "" && row.TLSMode != sw.TLSMode {
+		changes = append(changes, "tls_mode")
+	}
+	return changes
+}
+
+// applyInventoryRow updates sw from row; callers hold s.mu
+func applyInventoryRow(row InventoryRow, sw *Switch, creds map[string]Credential) {
+	if row.Port != 0 && row.Port != sw.Port {
+		sw.Port = row.Port
+		// A different endpoint presents a different certificate
+		sw.CertFingerprint = ""
+		sw.Certificate = nil
+		sw.PendingCertificate = nil
+	}
+	if row.UseHTTPS != nil {
+		sw.UseHTTPS = *row.UseHTTPS
+	}
+	if row.Credential != "" {
+		cred := creds[row.Credential]
+		sw.CredentialRef = cred.Name
+		sw.Username = cred.Username
+		sw.Password = cred.Password
+	}
+	if row.Site != "" {
+		sw.Site = row.Site
+	}
+	if row.Tags != nil {
+		sw.Tags = append([]string(nil), row.Tags...)
+	}
+	if row.TLSMode != "" {
+		sw.TLSMode = row.TLSMode
+	}
+	sw.AuthToken = ""
+	sw.TokenExpiry = time.Time{}
+	sw.resetSwitchClient()
+}
+
+// importInventory creates and updates switches from a CSV, JSON or YAML
+// file. Every row is validated first and nothing is applied unless all
+// rows are valid; ?dry_run=true only reports what would happen.
+func (s *Server) importInventory(c *gin.Context) {
+	format := inventoryFormat(c)
+	dryRun := c.Query("dry_run") == "true"
+
+	data, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxInventorySize))
+	if err != nil {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Inventory file too large"})
+		return
+	}
+	rows, err := parseInventory(format, data)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid inventory file: " + err.Error()})
+		return
+	}
+	if len(rows) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Inventory file has no switches"})
+		return
+	}
+
+	creds := s.credentials.snapshot()
+	results := make([]ImportRowResult, len(rows))
+	summary := map[string]int{"create": 0, "update": 0, "unchanged": 0, "error": 0}
+
+	s.mu.Lock()
+	byIP := make(map[string]*Switch, len(s.switches))
+	for _, sw := range s.switches {
+		byIP[sw.IPAddress] = sw
+	}
+
+	seen := make(map[string]int)
+	for i, row := range rows {
+		row.IPAddress = strings.TrimSpace(row.IPAddress)
+		rows[i] = row
+		res := ImportRowResult{Row: i + 1, IPAddress: row.IPAddress}
+
+		existing := byIP[row.IPAddress]
+		res.Errors = validateInventoryRow(row, existing, creds)
+		if first, dup := seen[row.IPAddress]; dup && row.IPAddress != "" {
+			res.Errors = append(res.Errors, fmt.Sprintf("duplicate of row %d", first))
+		}
+		seen[row.IPAddress] = i + 1
+
+		switch {
+		case len(res.Errors) > 0:
+			res.Action = "error"
+		case existing == nil:
+			res.Action = "create"
+		default:
+			res.SwitchID = existing.ID
+			res.Changes = inventoryChanges(row, existing)
+			res.Action = "unchanged"
+			if len(res.Changes) > 0 {
+				res.Action = "update"
+			}
+		}
+		summary[res.Action]++
+		results[i] = res
+	}
+
+	apply := !dryRun && summary["error"] == 0
+	var created, updated []*Switch
+	if apply {
+		for i, row := range rows {
+			switch results[i].Action {
+			case "create":
+				sw := &Switch{
+					ID:        s.nextID,
+					Name:      fmt.Sprintf("%s:%d", row.IPAddress, row.Port), // Temporary name until sync
+					IPAddress: row.IPAddress,
+					Port:      443,
+					UseHTTPS:  true,
+					Status:    "connecting",
+					TLSMode:   TLSModeTOFU,
+				}
+				if row.UseHTTPS != nil && !*row.UseHTTPS && row.Port == 0 {
+					sw.Port = 80
+				}
+				applyInventoryRow(row, sw, creds)
+				sw.Name = fmt.Sprintf("%s:%d", sw.IPAddress, sw.Port)
+				s.switches[sw.ID] = sw
+				s.nextID++
+				results[i].SwitchID = sw.ID
+				created = append(created, sw)
+			case "update":
+				sw := s.switches[results[i].SwitchID]
+				applyInventoryRow(row, sw, creds)
+				updated = append(updated, sw)
+			}
+		}
+	}
+	s.mu.Unlock()
+
+	ctx := detach(c)
+	for _, sw := range created {
+		s.events.Publish(EventSwitchAdded, sw.ID, gin.H{"ip_address": sw.IPAddress, "port": sw.Port})
+		go s.syncSwitch(ctx, sw)
+	}
+	for _, sw := range updated {
+		go s.syncSwitch(ctx, sw)
+	}
+
+	if !dryRun {
+		result := "success"
+		if !apply {
+			result = "failure"
+		}
+		s.audit(c, "switch.import", 0, result, gin.H{"format": format, "summary": summary})
+	}
+
+	status := http.StatusOK
+	if summary["error"] > 0 {
+		status = http.StatusUnprocessableEntity
+	}
+	c.JSON(status, gin.H{"dry_run": dryRun, "applied": apply, "summary": summary, "rows": results})
+}
+
+// exportInventory writes every switch in the import format, without
+// credentials other than their reference
+func (s *Server) exportInventory(c *gin.Context) {
+	format := strings.ToLower(c.DefaultQuery("format", "json"))
+	if format == "yml" {
+		format = "yaml"
+	}
+
+	s.mu.RLock()
+	switches := make([]*Switch, 0, len(s.switches))
+	for _, sw := range s.switches {
+		switches = append(switches, sw)
+	}
+	sort.Slice(switches, func(i, j int) bool { return switches[i].ID < switches[j].ID })
+	rows := make([]InventoryRow, len(switches))
+	for i, sw := range switches {
+		useHTTPS := sw.UseHTTPS
+		rows[i] = InventoryRow{
+			IPAddress:  sw.IPAddress,
+			Port:       sw.Port,
+			UseHTTPS:   &useHTTPS,
+			Credential: sw.CredentialRef,
+			Site:       sw.Site,
+			Tags:       append([]string(nil), sw.Tags...),
+			TLSMode:    sw.TLSMode,
+		}
+	}
+	s.mu.RUnlock()
+
+	switch format {
+	case "csv":
+		var buf bytes.Buffer
+		w := csv.NewWriter(&buf)
+		w.Write(inventoryColumns)
+		for _, row := range rows {
+			w.Write([]string{
+				row.IPAddress,
+				strconv.Itoa(row.Port),
+				strconv.FormatBool(*row.UseHTTPS),
+				row.Credential,
+				row.Site,
+				strings.Join(row.Tags, ";"),
+				row.TLSMode,
+			})
+		}
+		w.Flush()
+		c.Header("Content-Disposition", `attachment; filename="switches.csv"`)
+		c.Data(http.StatusOK, "text/csv", buf.Bytes())
+	case "yaml":
+		data, err := yaml.Marshal(inventoryFile{Switches: rows})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.Header("Content-Disposition", `attachment; filename="switches.yaml"`)
+		c.Data(http.StatusOK, "application/yaml", data)
+	case "json":
+		c.JSON(http.StatusOK, inventoryFile{Switches: rows})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be csv, json or yaml"})
+	}
+}
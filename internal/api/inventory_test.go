@@ -0,0 +1,103 @@
+package api
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseInventory(t *testing.T) {
+	yes, no := true, false
+	core := InventoryRow{IPAddress: "10.0.0.1", Port: 443, UseHTTPS: &yes, Credential: "default", Site: "hq", Tags: []string{"core", "rack:4"}}
+	access := InventoryRow{IPAddress: "10.0.0.2", UseHTTPS: &no}
+
+	tests := []struct {
+		name    string
+		format  string
+		data    string
+		want    []InventoryRow
+		wantErr bool
+	}{
+		{
+			name:   "csv",
+			format: "csv",
+			data: "ip_address,port,use_https,credential,site,tags,tls_mode\n" +
+				"10.0.0.1,443,true,default,hq,core;rack:4,\n" +
+				"10.0.0.2,,false,,,,\n",
+			want: []InventoryRow{core, access},
+		},
+		{
+			name:   "csv columns in any order and case",
+			format: "csv",
+			data:   "Use_HTTPS, IP_Address\nfalse,10.0.0.2\n",
+			want:   []InventoryRow{access},
+		},
+		{
+			name:   "csv values that do not parse are kept for validation",
+			format: "csv",
+			data:   "ip_address,port,use_https\n10.0.0.3,https,maybe\n",
+			want: []InventoryRow{{
+				IPAddress:   "10.0.0.3",
+				parseErrors: []string{`invalid port "https"`, `invalid use_https "maybe"`},
+			}},
+		},
+		{
+			name:    "csv unknown column",
+			format:  "csv",
+			data:    "ip_address,password\n10.0.0.1,secret\n",
+			wantErr: true,
+		},
+		{
+			name:   "csv header only",
+			format: "csv",
+			data:   "ip_address,port\n",
+			want:   []InventoryRow{},
+		},
+		{
+			name:   "json array",
+			format: "json",
+			data:   `[{"ip_address":"10.0.0.1","port":443,"use_https":true,"credential":"default","site":"hq","tags":["core","rack:4"]}]`,
+			want:   []InventoryRow{core},
+		},
+		{
+			name:   "json export file",
+			format: "json",
+			data:   `{"switches":[{"ip_address":"10.0.0.2","use_https":false}]}`,
+			want:   []InventoryRow{access},
+		},
+		{
+			name:    "json file with unknown field",
+			format:  "json",
+			data:    `{"switches":[],"devices":[]}`,
+			wantErr: true,
+		},
+		{
+			name:   "yaml list",
+			format: "yaml",
+			data:   "- ip_address: 10.0.0.2\n  use_https: false\n",
+			want:   []InventoryRow{access},
+		},
+		{
+			name:   "yaml export file",
+			format: "yaml",
+			data:   "switches:\n  - ip_address: 10.0.0.1\n    port: 443\n    use_https: true\n    credential: default\n    site: hq\n    tags: [core, \"rack:4\"]\n",
+			want:   []InventoryRow{core},
+		},
+		{
+			name:    "unknown format",
+			format:  "xml",
+			data:    "<switches/>",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseInventory(tt.format, []byte(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseInventory() error = %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseInventory() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,499 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/JarvisTchibClawBot/OpenExtremeManagement/internal/logging"
+	"github.com/gin-gonic/gin"
+)
+
+// Job states
+const (
+	JobPending   = "pending"
+	JobRunning   = "running"
+	JobCompleted = "completed"
+	JobCancelled = "cancelled"
+)
+
+const (
+	defaultJobConcurrency = 5
+	maxJobConcurrency     = 20
+	maxStoredJobs         = 500
+)
+
+// SwitchSelector picks a set of switches. All non-empty criteria must match.
+type SwitchSelector struct {
+	IDs    []int  `json:"ids"`
+	Name   string `json:"name"`   // Regular expression on the switch name
+	Model  string `json:"model"`  // Substring of the model name
+	Status string `json:"status"` // Exact status, e.g. online
+	Tag    string `json:"tag"`    // Switches carrying this tag
+}
+
+// selectSwitches returns the switches matching sel, ordered by ID
+func (s *Server) selectSwitches(sel SwitchSelector) ([]*Switch, error) {
+	var nameRe *regexp.Regexp
+	if sel.Name != "" {
+		re, err := regexp.Compile(sel.Name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid name pattern: %v", err)
+		}
+		nameRe = re
+	}
+
+	ids := make(map[int]bool, len(sel.IDs))
+	for _, id := range sel.IDs {
+		ids[id] = true
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var selected []*Switch
+	for _, sw := range s.switches {
+		if len(ids) > 0 && !ids[sw.ID] {
+			continue
+		}
+		if nameRe != nil && !nameRe.MatchString(sw.Name) {
+			continue
+		}
+		if sel.Model != "" && (sw.SystemInfo == nil || !strings.Contains(sw.SystemInfo.ModelName, sel.Model)) {
+			continue
+		}
+		if sel.Status != "" && sw.Status != sel.Status {
+			continue
+		}
+		if sel.Tag != "" && !hasTag(sw, sel.Tag) {
+			continue
+		}
+		selected = append(selected, sw)
+	}
+
+	sort.Slice(selected, func(i, j int) bool { return selected[i].ID < selected[j].ID })
+	return selected, nil
+}
+
+// JobProgress summarizes how far a job has got
+type JobProgress struct {
+	Total     int `json:"total"`
+	Completed int `json:"completed"`
+	Succeeded int `json:"succeeded"`
+	Failed    int `json:"failed"`
+	Skipped   int `json:"skipped"`
+}
+
+// JobSwitchResult is the outcome of a job on one switch
+type JobSwitchResult struct {
+	SwitchID   int                `json:"switch_id"`
+	SwitchName string             `json:"switch_name"`
+	Status     string             `json:"status"` // pending, running, success, failure, skipped
+	Error      string             `json:"error,omitempty"`
+	Commands   []CLICommandResult `json:"commands,omitempty"`
+	StartedAt  *time.Time         `json:"started_at,omitempty"`
+	FinishedAt *time.Time         `json:"finished_at,omitempty"`
+
+	Verification *VerificationResult `json:"verification,omitempty"`
+}
+
+// Job is a CLI command batch run across several switches. Template
+// deployments render different commands per switch, so Commands is only
+// set for plain CLI jobs.
+type Job struct {
+	ID          int                `json:"id"`
+	Type        string             `json:"type"`
+	Status      string             `json:"status"`
+	CreatedBy   string             `json:"created_by"`
+	CreatedAt   time.Time          `json:"created_at"`
+	StartedAt   *time.Time         `json:"started_at,omitempty"`
+	FinishedAt  *time.Time         `json:"finished_at,omitempty"`
+	Commands    []string           `json:"commands,omitempty"`
+	TemplateID  int                `json:"template_id,omitempty"`
+	Verify      *VerifySpec        `json:"verify,omitempty"`
+	AutoSave    bool               `json:"auto_save,omitempty"`
+	Concurrency int                `json:"concurrency"`
+	Progress    JobProgress        `json:"progress"`
+	Results     []*JobSwitchResult `json:"results,omitempty"`
+
+	cancel context.CancelFunc
+	// afterSwitch, if set, runs once a switch has applied all its commands
+	afterSwitch func(ctx context.Context, sw *Switch)
+}
+
+// JobStore keeps jobs and their results in memory
+type JobStore struct {
+	mu     sync.RWMutex
+	jobs   map[int]*Job
+	nextID int
+	oldest int
+}
+
+func NewJobStore() *JobStore {
+	return &JobStore{jobs: make(map[int]*Job), nextID: 1, oldest: 1}
+}
+
+// add stores a new job, evicting the oldest finished jobs once the store
+// is full
+func (js *JobStore) add(job *Job) {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+
+	job.ID = js.nextID
+	js.nextID++
+	js.jobs[job.ID] = job
+
+	for len(js.jobs) > maxStoredJobs && js.oldest < job.ID {
+		if old, ok := js.jobs[js.oldest]; ok {
+			if old.FinishedAt == nil {
+				break
+			}
+			delete(js.jobs, js.oldest)
+		}
+		js.oldest++
+	}
+}
+
+func (js *JobStore) get(id int) (*Job, bool) {
+	js.mu.RLock()
+	defer js.mu.RUnlock()
+	job, ok := js.jobs[id]
+	return job, ok
+}
+
+// snapshot returns a copy that is safe to serialize while the job runs
+func (js *JobStore) snapshot(job *Job, withResults bool) Job {
+	js.mu.RLock()
+	defer js.mu.RUnlock()
+
+	copied := *job
+	copied.Results = nil
+	if withResults {
+		for _, r := range job.Results {
+			rc := *r
+			copied.Results = append(copied.Results, &rc)
+		}
+	}
+	return copied
+}
+
+type CLIJobRequest struct {
+	Commands    []string       `json:"commands" binding:"required"`
+	Switches    SwitchSelector `json:"switches"`
+	Concurrency int            `json:"concurrency"`
+	Verify      *VerifySpec    `json:"verify"`
+	AutoSave    bool           `json:"auto_save"` // Save the config on each switch that applied the commands
+}
+
+func (s *Server) createCLIJob(c *gin.Context) {
+	var req CLIJobRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	if len(req.Commands) == 0 || len(req.Commands) > maxCLICommands {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Between 1 and %d commands are required", maxCLICommands)})
+		return
+	}
+
+	concurrency := jobConcurrency(req.Concurrency)
+
+	switches, err := s.selectSwitches(req.Switches)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(switches) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No switches match the selector"})
+		return
+	}
+
+	scrubbed := make([]string, len(req.Commands))
+	for i, cmd := range req.Commands {
+		scrubbed[i] = logging.Scrub(cmd)
+	}
+
+	role := c.GetString("role")
+	for _, cmd := range req.Commands {
+		if err := s.cliPolicy.Check(role, cmd); err != nil {
+			s.audit(c, "cli.job", 0, "denied", gin.H{"commands": scrubbed, "reason": logging.Scrub(err.Error())})
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	job := &Job{
+		Type:        "cli",
+		Status:      JobPending,
+		CreatedBy:   c.GetString("username"),
+		CreatedAt:   time.Now(),
+		Commands:    scrubbed,
+		Verify:      req.Verify,
+		AutoSave:    req.AutoSave,
+		Concurrency: concurrency,
+		Progress:    JobProgress{Total: len(switches)},
+	}
+	commands := make(map[int][]string, len(switches))
+	ids := make([]int, len(switches))
+	for i, sw := range switches {
+		commands[sw.ID] = req.Commands
+		ids[i] = sw.ID
+	}
+
+	s.startJob(c, job, switches, commands)
+	s.audit(c, "cli.job", 0, "success", gin.H{"job_id": job.ID, "commands": scrubbed, "switch_ids": ids})
+
+	c.JSON(http.StatusAccepted, gin.H{"job": s.jobs.snapshot(job, true)})
+}
+
+// jobConcurrency clamps the number of switches a job works on at a time,
+// defaulting to defaultJobConcurrency
+func jobConcurrency(requested int) int {
+	if requested <= 0 {
+		return defaultJobConcurrency
+	}
+	if requested > maxJobConcurrency {
+		return maxJobConcurrency
+	}
+	return requested
+}
+
+// startJob registers job and runs it in the background. The job outlives
+// the request but keeps its request ID in the logs.
+func (s *Server) startJob(c *gin.Context, job *Job, switches []*Switch, commands map[int][]string) {
+	for _, sw := range switches {
+		job.Results = append(job.Results, &JobSwitchResult{
+			SwitchID:   sw.ID,
+			SwitchName: sw.Name,
+			Status:     JobPending,
+		})
+	}
+	// Set before the job is listed, it can be cancelled from then on
+	ctx, cancel := context.WithCancel(detach(c))
+	job.cancel = cancel
+	s.jobs.add(job)
+
+	go s.runCLIJob(logging.With(ctx, "job_id", job.ID), job, switches, commands)
+}
+
+// runCLIJob sends each switch its commands, at most job.Concurrency
+// switches at a time, and publishes progress after every switch
+func (s *Server) runCLIJob(ctx context.Context, job *Job, switches []*Switch, commands map[int][]string) {
+	logger := logging.FromContext(ctx)
+
+	s.jobs.mu.Lock()
+	now := time.Now()
+	job.Status = JobRunning
+	job.StartedAt = &now
+	s.jobs.mu.Unlock()
+
+	logger.Info("CLI job started", "switches", len(switches), "concurrency", job.Concurrency)
+
+	sem := make(chan struct{}, job.Concurrency)
+	var wg sync.WaitGroup
+
+	for i, sw := range switches {
+		res := job.Results[i]
+
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			s.finishJobSwitch(job, res, nil, nil, fmt.Errorf("job cancelled"), "skipped")
+			continue
+		}
+
+		wg.Add(1)
+		go func(sw *Switch, res *JobSwitchResult) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			s.jobs.mu.Lock()
+			started := time.Now()
+			res.Status = JobRunning
+			res.StartedAt = &started
+			s.jobs.mu.Unlock()
+
+			// Cancelling stops new switches from starting, but a batch
+			// already sent to a switch is not interrupted halfway
+			swCtx := switchContext(context.WithoutCancel(ctx), sw, "cli_job")
+			var execution *CLICommandExecution
+			var verification *VerificationResult
+			err := s.ensureAuthenticated(swCtx, sw)
+			if err == nil {
+				execution, verification, err = s.pushWithVerification(swCtx, sw, commands[sw.ID], job.Verify, nil)
+			}
+
+			if err == nil && job.afterSwitch != nil {
+				job.afterSwitch(swCtx, sw)
+			}
+			if err == nil && job.AutoSave {
+				if saveErr := s.saveConfig(swCtx, sw); saveErr != nil {
+					err = fmt.Errorf("changes applied but save config failed: %w", saveErr)
+				}
+			}
+
+			status := "success"
+			if err != nil {
+				status = "failure"
+			}
+			s.finishJobSwitch(job, res, execution, verification, err, status)
+		}(sw, res)
+	}
+
+	wg.Wait()
+
+	s.jobs.mu.Lock()
+	finished := time.Now()
+	job.FinishedAt = &finished
+	if ctx.Err() != nil {
+		job.Status = JobCancelled
+	} else {
+		job.Status = JobCompleted
+	}
+	progress := job.Progress
+	status := job.Status
+	s.jobs.mu.Unlock()
+
+	s.events.Publish(EventJobProgress, 0, gin.H{"job_id": job.ID, "status": status, "progress": progress})
+	logger.Info("CLI job finished", "status", status, "succeeded", progress.Succeeded, "failed", progress.Failed)
+}
+
+func (s *Server) finishJobSwitch(job *Job, res *JobSwitchResult, execution *CLICommandExecution, verification *VerificationResult, err error, status string) {
+	s.jobs.mu.Lock()
+	finished := time.Now()
+	res.Status = status
+	res.FinishedAt = &finished
+	res.Verification = verification
+	if execution != nil {
+		res.Commands = execution.Commands
+	}
+	if err != nil {
+		res.Error = err.Error()
+	}
+	job.Progress.Completed++
+	switch status {
+	case "success":
+		job.Progress.Succeeded++
+	case "skipped":
+		job.Progress.Skipped++
+	default:
+		job.Progress.Failed++
+	}
+	progress := job.Progress
+	s.jobs.mu.Unlock()
+
+	s.events.Publish(EventJobProgress, res.SwitchID, gin.H{
+		"job_id":        job.ID,
+		"status":        JobRunning,
+		"switch_status": status,
+		"progress":      progress,
+	})
+}
+
+func (s *Server) listJobs(c *gin.Context) {
+	s.jobs.mu.RLock()
+	jobs := make([]*Job, 0, len(s.jobs.jobs))
+	for _, job := range s.jobs.jobs {
+		jobs = append(jobs, job)
+	}
+	s.jobs.mu.RUnlock()
+
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].ID > jobs[j].ID })
+
+	summaries := make([]Job, len(jobs))
+	for i, job := range jobs {
+		summaries[i] = s.jobs.snapshot(job, false)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"jobs": summaries})
+}
+
+func (s *Server) lookupJob(c *gin.Context) (*Job, bool) {
+	idStr := c.Param("id")
+	var id int
+	if _, err := fmt.Sscanf(idStr, "%d", &id); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid job ID"})
+		return nil, false
+	}
+
+	job, exists := s.jobs.get(id)
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+		return nil, false
+	}
+	return job, true
+}
+
+func (s *Server) getJob(c *gin.Context) {
+	job, ok := s.lookupJob(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"job": s.jobs.snapshot(job, true)})
+}
+
+// downloadJobResults serves the collected output as an attachment, either
+// as JSON or as plain text grouped by switch (?format=text)
+func (s *Server) downloadJobResults(c *gin.Context) {
+	job, ok := s.lookupJob(c)
+	if !ok {
+		return
+	}
+
+	snapshot := s.jobs.snapshot(job, true)
+
+	if c.Query("format") != "text" {
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=job-%d.json", job.ID))
+		c.JSON(http.StatusOK, snapshot)
+		return
+	}
+
+	var b strings.Builder
+	for _, res := range snapshot.Results {
+		fmt.Fprintf(&b, "===== %s (switch %d): %s =====\n", res.SwitchName, res.SwitchID, res.Status)
+		if res.Error != "" {
+			fmt.Fprintf(&b, "error: %s\n", res.Error)
+		}
+		for _, cmd := range res.Commands {
+			fmt.Fprintf(&b, "# %s [%s]\n%s\n", cmd.Command, cmd.Status, cmd.Output)
+		}
+		b.WriteString("\n")
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=job-%d.txt", job.ID))
+	c.String(http.StatusOK, b.String())
+}
+
+func (s *Server) cancelJob(c *gin.Context) {
+	job, ok := s.lookupJob(c)
+	if !ok {
+		return
+	}
+
+	if c.GetString("username") != job.CreatedBy && c.GetString("role") != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the creator or an admin can cancel a job"})
+		return
+	}
+
+	s.jobs.mu.RLock()
+	status, cancel := job.Status, job.cancel
+	s.jobs.mu.RUnlock()
+
+	if status != JobPending && status != JobRunning {
+		c.JSON(http.StatusConflict, gin.H{"error": "Job is not running"})
+		return
+	}
+
+	cancel()
+	s.audit(c, "job.cancel", 0, "success", gin.H{"job_id": job.ID})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Job cancellation requested"})
+}
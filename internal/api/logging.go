@@ -0,0 +1,70 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/JarvisTchibClawBot/OpenExtremeManagement/internal/logging"
+	"github.com/gin-gonic/gin"
+)
+
+const requestIDHeader = "X-Request-ID"
+
+// requestLogger assigns a request ID (reusing the caller's if present),
+// attaches a tagged logger to the request context and writes one access
+// log line per request
+func requestLogger() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		requestID := c.GetHeader(requestIDHeader)
+		if requestID == "" || len(requestID) > 64 {
+			requestID = logging.NewRequestID()
+		}
+		c.Header(requestIDHeader, requestID)
+
+		ctx := logging.WithRequestID(c.Request.Context(), requestID)
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		// Probes and scrapes would otherwise drown out real traffic
+		level := slog.LevelInfo
+		if strings.HasPrefix(c.Request.URL.Path, "/health") || c.Request.URL.Path == "/metrics" {
+			level = slog.LevelDebug
+		}
+		if c.Writer.Status() >= 500 {
+			level = slog.LevelError
+		} else if c.Writer.Status() >= 400 {
+			level = slog.LevelWarn
+		}
+
+		attrs := []any{
+			"method", c.Request.Method,
+			"path", c.Request.URL.Path,
+			"route", c.FullPath(),
+			"status", c.Writer.Status(),
+			"duration_ms", time.Since(start).Milliseconds(),
+			"client_ip", c.ClientIP(),
+		}
+		if username := c.GetString("username"); username != "" {
+			attrs = append(attrs, "user", username)
+		}
+
+		logging.FromContext(ctx).Log(ctx, level, "http request", attrs...)
+	}
+}
+
+// switchContext tags the logger in ctx with the switch and operation so
+// every line logged for the call can be traced back to it
+func switchContext(ctx context.Context, sw *Switch, operation string) context.Context {
+	return logging.With(ctx, "switch_id", sw.ID, "switch_ip", sw.IPAddress, "operation", operation)
+}
+
+// detach keeps the request ID and logger of a handler's context for work
+// that continues after the response has been sent
+func detach(c *gin.Context) context.Context {
+	return context.WithoutCancel(c.Request.Context())
+}
@@ -0,0 +1,185 @@
+package api
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Metrics holds the backend's Prometheus instruments
+type Metrics struct {
+	registry *prometheus.Registry
+
+	httpDuration *prometheus.HistogramVec
+	syncDuration *prometheus.HistogramVec
+	syncTotal    *prometheus.CounterVec
+	tokenRefresh *prometheus.CounterVec
+	cliPushTotal *prometheus.CounterVec
+}
+
+func newMetrics(s *Server) *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "oem_http_request_duration_seconds",
+			Help:    "HTTP request latency by route.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		syncDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "oem_switch_sync_duration_seconds",
+			Help:    "Duration of switch syncs.",
+			Buckets: []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+		}, []string{"switch_id"}),
+		syncTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "oem_switch_sync_total",
+			Help: "Switch syncs by result.",
+		}, []string{"switch_id", "result"}),
+		tokenRefresh: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "oem_switch_token_refresh_total",
+			Help: "Switch API token refreshes by result.",
+		}, []string{"switch_id", "result"}),
+		cliPushTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "oem_cli_push_total",
+			Help: "CLI command batches pushed to switches by result.",
+		}, []string{"switch_id", "result"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpDuration,
+		m.syncDuration,
+		m.syncTotal,
+		m.tokenRefresh,
+		m.cliPushTotal,
+		&switchCollector{server: s},
+	)
+
+	return m
+}
+
+// middleware records request latency. Unmatched routes are grouped so
+// scanners can't blow up label cardinality.
+func (m *Metrics) middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		m.httpDuration.WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).
+			Observe(time.Since(start).Seconds())
+	}
+}
+
+func (m *Metrics) handler() gin.HandlerFunc {
+	return gin.WrapH(promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{}))
+}
+
+// forgetSwitch drops the per-switch series of a deleted switch
+func (m *Metrics) forgetSwitch(id int) {
+	labels := prometheus.Labels{"switch_id": strconv.Itoa(id)}
+	m.syncDuration.DeletePartialMatch(labels)
+	m.syncTotal.DeletePartialMatch(labels)
+	m.tokenRefresh.DeletePartialMatch(labels)
+	m.cliPushTotal.DeletePartialMatch(labels)
+}
+
+func result(err error) string {
+	if err != nil {
+		return "failure"
+	}
+	return "success"
+}
+
+// Per-switch gauges are read from the switch table at scrape time so they
+// never go stale when a switch is deleted
+var (
+	switchStatusDesc = prometheus.NewDesc("oem_switch_status",
+		"Current switch status (1 for the active status).",
+		[]string{"switch_id", "name", "status"}, nil)
+	switchUpDesc = prometheus.NewDesc("oem_switch_up",
+		"Whether the last sync of the switch succeeded.",
+		[]string{"switch_id", "name"}, nil)
+	switchUptimeDesc = prometheus.NewDesc("oem_switch_uptime_seconds",
+		"Switch uptime as reported by the first card.",
+		[]string{"switch_id", "name"}, nil)
+	switchPortsDesc = prometheus.NewDesc("oem_switch_ports",
+		"Number of ports by operational status.",
+		[]string{"switch_id", "name", "status"}, nil)
+	switchConfigDirtyDesc = prometheus.NewDesc("oem_switch_config_dirty",
+		"Whether the running config has unsaved changes.",
+		[]string{"switch_id", "name"}, nil)
+	switchLastSyncDesc = prometheus.NewDesc("oem_switch_last_sync_timestamp_seconds",
+		"Unix time of the last successful sync.",
+		[]string{"switch_id", "name"}, nil)
+	switchHealthDesc = prometheus.NewDesc("oem_switch_health_score",
+		"Environment health score, 100 when fans, power supplies and temperatures are fine.",
+		[]string{"switch_id", "name"}, nil)
+)
+
+type switchCollector struct {
+	server *Server
+}
+
+func (sc *switchCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- switchStatusDesc
+	ch <- switchUpDesc
+	ch <- switchUptimeDesc
+	ch <- switchPortsDesc
+	ch <- switchConfigDirtyDesc
+	ch <- switchLastSyncDesc
+	ch <- switchHealthDesc
+}
+
+func (sc *switchCollector) Collect(ch chan<- prometheus.Metric) {
+	s := sc.server
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, sw := range s.switches {
+		id := strconv.Itoa(sw.ID)
+
+		ch <- prometheus.MustNewConstMetric(switchStatusDesc, prometheus.GaugeValue, 1, id, sw.Name, sw.Status)
+
+		up := 0.0
+		if sw.Status == "online" || sw.Status == StatusDegraded {
+			up = 1
+		}
+		ch <- prometheus.MustNewConstMetric(switchUpDesc, prometheus.GaugeValue, up, id, sw.Name)
+
+		if sw.LastSync != nil {
+			ch <- prometheus.MustNewConstMetric(switchLastSyncDesc, prometheus.GaugeValue, float64(sw.LastSync.Unix()), id, sw.Name)
+		}
+
+		if sw.Environment != nil {
+			ch <- prometheus.MustNewConstMetric(switchHealthDesc, prometheus.GaugeValue, float64(sw.Environment.Score), id, sw.Name)
+		}
+
+		if sw.SystemInfo != nil {
+			ch <- prometheus.MustNewConstMetric(switchUptimeDesc, prometheus.GaugeValue, float64(sw.SystemInfo.SysUpTime), id, sw.Name)
+
+			dirty := 0.0
+			if sw.SystemInfo.IsConfigDirty {
+				dirty = 1
+			}
+			ch <- prometheus.MustNewConstMetric(switchConfigDirtyDesc, prometheus.GaugeValue, dirty, id, sw.Name)
+		}
+
+		if sw.Ports != nil {
+			counts := map[string]int{"up": 0, "down": 0, "disabled": 0}
+			for _, p := range sw.Ports {
+				counts[p.Status]++
+			}
+			for status, n := range counts {
+				ch <- prometheus.MustNewConstMetric(switchPortsDesc, prometheus.GaugeValue, float64(n), id, sw.Name, status)
+			}
+		}
+	}
+}
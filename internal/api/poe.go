@@ -0,0 +1,327 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/JarvisTchibClawBot/OpenExtremeManagement/internal/logging"
+	"github.com/gin-gonic/gin"
+)
+
+// How long a power-cycled port stays unpowered, long enough for an AP or
+// phone to fully lose power
+const poeCycleDelay = 5 * time.Second
+
+// PoE port priorities, in the switch's CLI spelling
+var poePriorities = []string{"low", "high", "critical"}
+
+// PoEPort is the PoE state of one port
+type PoEPort struct {
+	Port     string  `json:"port"`
+	Enabled  bool    `json:"enabled"`
+	Status   string  `json:"status"` // delivering, searching, disabled, fault
+	Class    int     `json:"class"`  // Power class of the attached device, -1 if none
+	Priority string  `json:"priority"`
+	Power    float64 `json:"power"` // Watts drawn
+}
+
+// PoEInfo is the power budget of a switch and the state of its PoE ports
+type PoEInfo struct {
+	Budget      float64   `json:"budget"`      // Watts
+	Consumption float64   `json:"consumption"` // Watts
+	Ports       []PoEPort `json:"ports"`
+}
+
+// Available is the power left for more devices
+func (p *PoEInfo) Available() float64 {
+	return p.Budget - p.Consumption
+}
+
+// port returns the PoE state of a port, nil if it has no PoE
+func (p *PoEInfo) port(name string) *PoEPort {
+	for i := range p.Ports {
+		if p.Ports[i].Port == name {
+			return &p.Ports[i]
+		}
+	}
+	return nil
+}
+
+// fetchPoE reads the power budget and per-port PoE state from the switch
+func (s *Server) fetchPoE(ctx context.Context, sw *Switch) (*PoEInfo, error) {
+	var state struct {
+		PowerBudget      float64 `json:"powerBudget"`
+		PowerConsumption float64 `json:"powerConsumption"`
+		Ports            []struct {
+			PortName        string  `json:"portName"`
+			AdminStatus     string  `json:"adminStatus"`
+			DetectionStatus string  `json:"detectionStatus"`
+			PowerClass      int     `json:"powerClass"`
+			Priority        string  `json:"priority"`
+			PowerDraw       float64 `json:"powerDraw"`
+		} `json:"ports"`
+	}
+
+	if err := s.getSwitchState(ctx, sw, "/v0/state/poe", &state); err != nil {
+		return nil, err
+	}
+
+	info := &PoEInfo{Budget: state.PowerBudget, Consumption: state.PowerConsumption, Ports: make([]PoEPort, len(state.Ports))}
+	for i, p := range state.Ports {
+		status := "searching"
+		switch p.DetectionStatus {
+		case "DELIVERING_POWER":
+			status = "delivering"
+		case "DISABLED":
+			status = "disabled"
+		case "FAULT", "OTHER_FAULT", "TEST":
+			status = "fault"
+		}
+		info.Ports[i] = PoEPort{
+			Port:     p.PortName,
+			Enabled:  p.AdminStatus == "ENABLED",
+			Status:   status,
+			Class:    p.PowerClass,
+			Priority: strings.ToLower(p.Priority),
+			Power:    p.PowerDraw,
+		}
+	}
+	sort.Slice(info.Ports, func(i, j int) bool { return portLess(info.Ports[i].Port, info.Ports[j].Port) })
+	return info, nil
+}
+
+// refreshPoE reads the PoE state again after an action so the response
+// shows its effect
+func (s *Server) refreshPoE(ctx context.Context, sw *Switch) *PoEInfo {
+	info, err := s.fetchPoE(ctx, sw)
+	if err != nil {
+		logging.FromContext(ctx).Warn("PoE poll failed", "error", err)
+		return nil
+	}
+	s.mu.Lock()
+	sw.PoE = info
+	s.mu.Unlock()
+	return info
+}
+
+// getPoE returns the power budget and PoE ports of a switch
+func (s *Server) getPoE(c *gin.Context) {
+	sw, ok := s.lookupSwitch(c)
+	if !ok {
+		return
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if sw.PoE == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "PoE state not collected yet"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"switch_id":   sw.ID,
+		"budget":      sw.PoE.Budget,
+		"consumption": sw.PoE.Consumption,
+		"available":   sw.PoE.Available(),
+		"ports":       sw.PoE.Ports,
+	})
+}
+
+// PoESummary is the power budget of one switch in the fleet listing
+type PoESummary struct {
+	SwitchID    int     `json:"switch_id"`
+	SwitchName  string  `json:"switch_name"`
+	Site        string  `json:"site,omitempty"`
+	Budget      float64 `json:"budget"`
+	Consumption float64 `json:"consumption"`
+	Available   float64 `json:"available"`
+	Delivering  int     `json:"delivering"` // Ports powering a device
+	Faults      int     `json:"faults"`
+}
+
+// listPoE summarizes the power budget of every PoE switch, ?site limiting
+// it to one site
+func (s *Server) listPoE(c *gin.Context) {
+	site := c.Query("site")
+
+	s.mu.RLock()
+	summaries := []PoESummary{}
+	for _, sw := range s.switches {
+		if sw.PoE == nil || (site != "" && sw.Site != site) {
+			continue
+		}
+		summary := PoESummary{
+			SwitchID:    sw.ID,
+			SwitchName:  sw.Name,
+			Site:        sw.Site,
+			Budget:      sw.PoE.Budget,
+			Consumption: sw.PoE.Consumption,
+			Available:   sw.PoE.Available(),
+		}
+		for _, p := range sw.PoE.Ports {
+			switch p.Status {
+			case "delivering":
+				summary.Delivering++
+			case "fault":
+				summary.Faults++
+			}
+		}
+		summaries = append(summaries, summary)
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].SwitchID < summaries[j].SwitchID })
+	c.JSON(http.StatusOK, gin.H{"switches": summaries})
+}
+
+// lookupPoEPort resolves the switch and PoE port of a port action route,
+// writing the error response if either is missing
+func (s *Server) lookupPoEPort(c *gin.Context) (*Switch, PoEPort, bool) {
+	sw, ok := s.lookupSwitch(c)
+	if !ok {
+		return nil, PoEPort{}, false
+	}
+	name := c.Param("port")
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if sw.PoE == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "PoE state not collected yet, sync the switch first"})
+		return nil, PoEPort{}, false
+	}
+	port := sw.PoE.port(name)
+	if port == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Port has no PoE"})
+		return nil, PoEPort{}, false
+	}
+	return sw, *port, true
+}
+
+// poeCommands builds the CLI that applies PoE settings to a port
+func poeCommands(port string, settings ...string) []string {
+	commands := []string{"configure terminal", "interface gigabitEthernet " + port}
+	commands = append(commands, settings...)
+	return append(commands, "exit", "exit")
+}
+
+// UpdatePoERequest changes the PoE settings of a port; fields left out
+// stay as they are
+type UpdatePoERequest struct {
+	Enabled  *bool   `json:"enabled"`
+	Priority *string `json:"priority"` // low, high or critical
+}
+
+// updatePoE enables or disables PoE on a port and sets its priority
+func (s *Server) updatePoE(c *gin.Context) {
+	sw, port, ok := s.lookupPoEPort(c)
+	if !ok {
+		return
+	}
+
+	var req UpdatePoERequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+	var settings []string
+	if req.Enabled != nil {
+		if *req.Enabled {
+			settings = append(settings, "no poe poe-shutdown")
+		} else {
+			settings = append(settings, "poe poe-shutdown")
+		}
+	}
+	if req.Priority != nil {
+		priority := strings.ToLower(*req.Priority)
+		valid := false
+		for _, p := range poePriorities {
+			valid = valid || p == priority
+		}
+		if !valid {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "priority must be one of " + strings.Join(poePriorities, ", ")})
+			return
+		}
+		settings = append(settings, "poe poe-priority "+priority)
+	}
+	if len(settings) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Nothing to change, give enabled or priority"})
+		return
+	}
+
+	ctx := switchContext(c.Request.Context(), sw, "poe")
+	details := gin.H{"port": port.Port, "request": req}
+	if err := s.applyPoE(ctx, sw, poeCommands(port.Port, settings...)); err != nil {
+		details["error"] = err.Error()
+		s.audit(c, "poe.update", sw.ID, "failure", details)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to update PoE: " + err.Error()})
+		return
+	}
+	s.audit(c, "poe.update", sw.ID, "success", details)
+
+	logging.FromContext(ctx).Info("updated PoE", "port", port.Port)
+	c.JSON(http.StatusOK, gin.H{"port": s.poePortAfter(ctx, sw, port)})
+}
+
+// powerCyclePoE cuts power to a port and restores it after poeCycleDelay,
+// which reboots a hung AP or phone. Power is restored even if the client
+// goes away in between.
+func (s *Server) powerCyclePoE(c *gin.Context) {
+	sw, port, ok := s.lookupPoEPort(c)
+	if !ok {
+		return
+	}
+	if !port.Enabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "PoE is disabled on this port, enable it instead"})
+		return
+	}
+
+	ctx := switchContext(detach(c), sw, "poe_power_cycle")
+	details := gin.H{"port": port.Port, "class": port.Class, "power": port.Power}
+	if err := s.applyPoE(ctx, sw, poeCommands(port.Port, "poe poe-shutdown")); err != nil {
+		details["error"] = err.Error()
+		s.audit(c, "poe.power_cycle", sw.ID, "failure", details)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to power off port: " + err.Error()})
+		return
+	}
+
+	time.Sleep(poeCycleDelay)
+
+	if err := s.applyPoE(ctx, sw, poeCommands(port.Port, "no poe poe-shutdown")); err != nil {
+		details["error"] = "power off succeeded, power on failed: " + err.Error()
+		s.audit(c, "poe.power_cycle", sw.ID, "failure", details)
+		logging.FromContext(ctx).Error("PoE power on failed, port left unpowered", "port", port.Port, "error", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Port powered off but power on failed: " + err.Error()})
+		return
+	}
+	s.audit(c, "poe.power_cycle", sw.ID, "success", details)
+
+	logging.FromContext(ctx).Info("power-cycled PoE port", "port", port.Port)
+	c.JSON(http.StatusOK, gin.H{"message": "Port power-cycled", "port": s.poePortAfter(ctx, sw, port)})
+}
+
+// applyPoE runs PoE commands on sw and fails if any of them did
+func (s *Server) applyPoE(ctx context.Context, sw *Switch, commands []string) error {
+	if err := s.ensureAuthenticated(ctx, sw); err != nil {
+		return fmt.Errorf("authentication failed: %w", err)
+	}
+	execution, err := s.runCLI(ctx, sw, commands)
+	if err != nil {
+		return err
+	}
+	return firstFailedCommand(execution)
+}
+
+// poePortAfter returns the state of port read back after an action, or
+// the state before it if the switch could not be read
+func (s *Server) poePortAfter(ctx context.Context, sw *Switch, before PoEPort) PoEPort {
+	if info := s.refreshPoE(ctx, sw); info != nil {
+		if p := info.port(before.Port); p != nil {
+			return *p
+		}
+	}
+	return before
+}
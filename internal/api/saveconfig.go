@@ -0,0 +1,156 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// saveConfigCommand copies the running config to the startup config
+const saveConfigCommand = "save config"
+
+// changesRunningConfig reports whether a successful command modifies the
+// running config. Mode changes and read-only commands don't.
+func changesRunningConfig(cmd string) bool {
+	cmd = strings.ToLower(strings.TrimSpace(cmd))
+	switch cmd {
+	case "", "exit", "end", "enable", "configure terminal", "config terminal":
+		return false
+	}
+	for _, prefix := range []string{"show ", "ping ", "traceroute ", "terminal "} {
+		if strings.HasPrefix(cmd, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// trackConfigState updates the cached unsaved-changes flag from the
+// commands that just ran on sw, so it is right before the next sync
+func (s *Server) trackConfigState(sw *Switch, execution *CLICommandExecution) {
+	changed, saved, dirty := false, false, false
+	for _, r := range execution.Commands {
+		if !r.Success {
+			continue
+		}
+		if strings.EqualFold(strings.TrimSpace(r.Command), saveConfigCommand) {
+			changed, saved, dirty = true, true, false
+		} else if changesRunningConfig(r.Command) {
+			changed, dirty = true, true
+		}
+	}
+	if !changed {
+		return
+	}
+
+	s.mu.Lock()
+	if sw.SystemInfo == nil {
+		sw.SystemInfo = &SystemInfo{}
+	}
+	was := sw.SystemInfo.IsConfigDirty
+	sw.SystemInfo.IsConfigDirty = dirty
+	if saved {
+		now := time.Now()
+		sw.ConfigSavedAt = &now
+	}
+	s.mu.Unlock()
+
+	if was != dirty {
+		s.events.Publish(EventSwitchConfig, sw.ID, gin.H{"isConfigDirty": dirty})
+	}
+}
+
+// saveConfig writes the running config of sw to its startup config
+func (s *Server) saveConfig(ctx context.Context, sw *Switch) error {
+	if err := s.ensureAuthenticated(ctx, sw); err != nil {
+		return fmt.Errorf("authentication failed: %w", err)
+	}
+	execution, err := s.runCLI(ctx, sw, []string{saveConfigCommand})
+	if err != nil {
+		return err
+	}
+	return firstFailedCommand(execution)
+}
+
+func (s *Server) saveConfigEndpoint(c *gin.Context) {
+	sw, ok := s.lookupSwitch(c)
+	if !ok {
+		return
+	}
+
+	if err := s.saveConfig(switchContext(c.Request.Context(), sw, "save_config"), sw); err != nil {
+		s.audit(c, "config.save", sw.ID, "failure", gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Save config failed: " + err.Error()})
+		return
+	}
+
+	s.audit(c, "config.save", sw.ID, "success", nil)
+
+	s.mu.RLock()
+	savedAt := sw.ConfigSavedAt
+	s.mu.RUnlock()
+	c.JSON(http.StatusOK, gin.H{"switch_id": sw.ID, "saved": true, "config_saved_at": savedAt})
+}
+
+type SaveConfigJobRequest struct {
+	Switches    SwitchSelector `json:"switches"`
+	OnlyDirty   *bool          `json:"only_dirty"` // Skip switches without unsaved changes, default true
+	Concurrency int            `json:"concurrency"`
+}
+
+// createSaveConfigJob saves the config of every selected switch in the
+// background
+func (s *Server) createSaveConfigJob(c *gin.Context) {
+	var req SaveConfigJobRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	concurrency := jobConcurrency(req.Concurrency)
+
+	selected, err := s.selectSwitches(req.Switches)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	onlyDirty := req.OnlyDirty == nil || *req.OnlyDirty
+	var switches []*Switch
+	s.mu.RLock()
+	for _, sw := range selected {
+		if !onlyDirty || (sw.SystemInfo != nil && sw.SystemInfo.IsConfigDirty) {
+			switches = append(switches, sw)
+		}
+	}
+	s.mu.RUnlock()
+	if len(switches) == 0 {
+		c.JSON(http.StatusOK, gin.H{"message": "No switches with unsaved changes match the selector"})
+		return
+	}
+
+	job := &Job{
+		Type:        "save",
+		Status:      JobPending,
+		CreatedBy:   c.GetString("username"),
+		CreatedAt:   time.Now(),
+		Commands:    []string{saveConfigCommand},
+		Concurrency: concurrency,
+		Progress:    JobProgress{Total: len(switches)},
+	}
+	commands := make(map[int][]string, len(switches))
+	ids := make([]int, len(switches))
+	for i, sw := range switches {
+		commands[sw.ID] = job.Commands
+		ids[i] = sw.ID
+	}
+
+	s.startJob(c, job, switches, commands)
+	s.audit(c, "config.save", 0, "success", gin.H{"job_id": job.ID, "switch_ids": ids})
+
+	c.JSON(http.StatusAccepted, gin.H{"job": s.jobs.snapshot(job, true)})
+}
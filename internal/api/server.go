@@ -2,27 +2,22 @@ package api
 
 import (
 	"bytes"
-	"crypto/tls"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
-	"log"
+	"log/slog"
 	"net/http"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/JarvisTchibClawBot/OpenExtremeManagement/internal/config"
+	"github.com/JarvisTchibClawBot/OpenExtremeManagement/internal/logging"
 	"github.com/gin-gonic/gin"
 )
 
-// HTTP client with TLS skip verify for self-signed certs
-var insecureClient = &http.Client{
-	Timeout: 10 * time.Second,
-	Transport: &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-	},
-}
-
 // Switch represents a managed switch
 type Switch struct {
 	ID              int          `json:"id"`
@@ -33,10 +28,31 @@ type Switch struct {
 	Username        string       `json:"username"`
 	Password        string       `json:"-"`
 	Status          string       `json:"status"`
+	Site            string       `json:"site,omitempty"`
+	Tags            []string     `json:"tags,omitempty"`
+	CredentialRef   string       `json:"credential_ref,omitempty"` // Stored credential the login follows
 	LastSync        *time.Time   `json:"last_sync,omitempty"`
+	ConfigSavedAt   *time.Time   `json:"config_saved_at,omitempty"` // Last save config run through OEM
 	SystemInfo      *SystemInfo  `json:"system_info,omitempty"`
+	HealthScore     *int         `json:"health_score,omitempty"` // Environment health, 0-100
 	AuthToken       string       `json:"-"`
 	TokenExpiry     time.Time    `json:"-"`
+
+	// TLS policy
+	TLSMode            string           `json:"tls_mode"`
+	TLSServerName      string           `json:"tls_server_name,omitempty"`
+	CACert             string           `json:"-"`
+	CertFingerprint    string           `json:"cert_fingerprint,omitempty"`
+	Certificate        *CertificateInfo `json:"certificate,omitempty"`
+	PendingCertificate *CertificateInfo `json:"pending_certificate,omitempty"`
+	client             *http.Client
+
+	Ports        []Port           `json:"-"` // Collected port state, nil until the first successful poll
+	Neighbors    []LLDPNeighbor   `json:"-"` // LLDP neighbors, nil until the first successful poll
+	Fabric       *FabricInfo      `json:"-"` // SPBM state, Fabric Engine switches only
+	Transceivers []Transceiver    `json:"-"` // Optics with DOM values, nil until the first successful poll
+	PoE          *PoEInfo         `json:"-"` // Power budget and PoE ports, nil until the first successful poll
+	Environment  *EnvironmentInfo `json:"-"` // Fans, power supplies and temperatures, nil until the first successful poll
 }
 
 // SystemInfo from Fabric Engine
@@ -51,6 +67,10 @@ type SystemInfo struct {
 	ChassisId       string `json:"chassisId"`
 	NumPorts        int    `json:"numPorts"`
 	IsDigitalTwin   bool   `json:"isDigitalTwin"`
+	IsConfigDirty   bool   `json:"isConfigDirty"`
+	SysUpTime       int64  `json:"sysUpTime"` // Seconds
+
+	Cards []Card `json:"-"` // Served by /switches/:id/hardware
 }
 
 type Server struct {
@@ -60,6 +80,26 @@ type Server struct {
 	mu           sync.RWMutex
 	nextID       int
 	stopSync     chan struct{}
+	events       *EventHub
+	metrics      *Metrics
+	auditLog     *AuditLog
+	cliPolicy    *CLIPolicy
+	jobs         *JobStore
+	templates    *TemplateStore
+	backups      *BackupStore
+	compliance   *ComplianceStore
+	desiredState *DesiredStateStore
+	changes      *ChangeStore
+	firmware     *FirmwareStore
+	images       *ImageStore
+	upgrades     *UpgradeStore
+	credentials  *CredentialStore
+	discovery    *DiscoveryStore
+	endpoints    *EndpointStore
+	stats        *StatsStore
+	transceivers *TransceiverStore
+
+	syncHeartbeat atomic.Int64 // UnixNano of the last sync loop progress
 }
 
 func NewServer(cfg *config.Config) *Server {
@@ -67,13 +107,17 @@ func NewServer(cfg *config.Config) *Server {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
-	router := gin.Default()
+	router := gin.New()
+	// Port names such as 1/3 arrive escaped as 1%2F3 in a single segment
+	router.UseRawPath = true
+	router.Use(gin.Recovery(), requestLogger())
 
 	// CORS middleware
 	router.Use(func(c *gin.Context) {
 		c.Header("Access-Control-Allow-Origin", "*")
 		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Request-ID")
+		c.Header("Access-Control-Expose-Headers", "X-Request-ID")
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
 			return
@@ -87,22 +131,59 @@ func NewServer(cfg *config.Config) *Server {
 		switches:     make(map[int]*Switch),
 		nextID:       1,
 		stopSync:     make(chan struct{}),
+		events:       NewEventHub(cfg.RedisURL),
+		auditLog:     NewAuditLog(),
+		jobs:         NewJobStore(),
+		templates:    NewTemplateStore(),
+		backups:      NewBackupStore(),
+		compliance:   NewComplianceStore(),
+		desiredState: NewDesiredStateStore(),
+		changes:      NewChangeStore(),
+		firmware:     NewFirmwareStore(),
+		images:       NewImageStore(cfg.FirmwareImageDir),
+		upgrades:     NewUpgradeStore(),
+		credentials:  NewCredentialStore(),
+		discovery:    NewDiscoveryStore(),
+		endpoints:    NewEndpointStore(),
+		stats: NewStatsStore(
+			statsRetention("STATS_RAW_RETENTION", cfg.StatsRawRetention, 24*time.Hour),
+			statsRetention("STATS_5M_RETENTION", cfg.Stats5mRetention, 7*24*time.Hour),
+			statsRetention("STATS_HOURLY_RETENTION", cfg.StatsHourlyRetention, 90*24*time.Hour),
+		),
+		transceivers: NewTransceiverStore(statsRetention("DOM_RETENTION", cfg.DOMRetention, 30*24*time.Hour)),
+	}
+
+	cliPolicy, err := loadCLIPolicy(cfg.CLIPolicyFile)
+	if err != nil {
+		// Fail closed: nobody may use the CLI endpoint until the policy is fixed
+		slog.Error("failed to load CLI policy, CLI access disabled", "error", err)
+		cliPolicy = &CLIPolicy{Roles: map[string]*CLIRolePolicy{}}
 	}
+	server.cliPolicy = cliPolicy
+
+	server.metrics = newMetrics(server)
+	router.Use(server.metrics.middleware())
 
 	server.setupRoutes()
 	
 	// Start background sync
+	server.syncHeartbeat.Store(time.Now().UnixNano())
 	go server.syncLoop()
+	go server.changeScheduler()
 
 	return server
 }
 
 func (s *Server) setupRoutes() {
-	s.router.GET("/health", s.healthCheck)
+	s.router.GET("/health", s.liveness) // Always 200, as existing probes expect
+	s.router.GET("/health/live", s.liveness)
+	s.router.GET("/health/ready", s.readiness)
+	s.router.GET("/metrics", s.metrics.handler())
 
 	v1 := s.router.Group("/api/v1")
 	{
 		v1.POST("/auth/login", s.login)
+		v1.GET("/events", s.queryTokenAuth(), s.authMiddleware(), s.streamEvents)
 
 		protected := v1.Group("")
 		protected.Use(s.authMiddleware())
@@ -114,10 +195,104 @@ func (s *Server) setupRoutes() {
 			protected.DELETE("/switches/:id", s.deleteSwitch)
 			protected.POST("/switches/:id/sync", s.syncSwitchEndpoint)
 			protected.GET("/switches/:id/ports", s.getPorts)
+			protected.GET("/switches/:id/ports/:port/stats", s.getPortStats)
+			protected.GET("/switches/:id/ports/:port/transceiver/dom", s.getTransceiverDOM)
+			protected.GET("/switches/:id/transceivers", s.getTransceivers)
+			protected.POST("/switches/:id/transceivers/collect", s.collectTransceiversEndpoint)
+			protected.GET("/switches/:id/poe", s.getPoE)
+			protected.GET("/switches/:id/environment", s.getEnvironment)
+			protected.PUT("/switches/:id/ports/:port/poe", s.requireRole("admin", "operator"), s.updatePoE)
+			protected.POST("/switches/:id/ports/:port/poe/power-cycle", s.requireRole("admin", "operator"), s.powerCyclePoE)
+			protected.GET("/switches/:id/hardware", s.getHardware)
+			protected.GET("/switches/:id/neighbors", s.getNeighbors)
+			protected.GET("/switches/:id/fabric", s.getSwitchFabric)
+			protected.POST("/switches/:id/endpoints/collect", s.collectEndpointsEndpoint)
 			protected.PUT("/switches/:id/system", s.updateSystemInfo)
+			protected.POST("/switches/:id/cli", s.executeCLI)
+			protected.POST("/switches/:id/save-config", s.requireRole("admin", "operator"), s.saveConfigEndpoint)
+			protected.GET("/switches/:id/certificate", s.getCertificate)
+			protected.POST("/switches/:id/certificate/accept", s.requireRole("admin"), s.acceptCertificate)
+			protected.GET("/audit", s.requireRole("admin"), s.listAudit)
+			protected.POST("/jobs/cli", s.createCLIJob)
+			protected.POST("/jobs/save-config", s.requireRole("admin", "operator"), s.createSaveConfigJob)
+			protected.GET("/jobs", s.listJobs)
+			protected.GET("/jobs/:id", s.getJob)
+			protected.GET("/jobs/:id/results", s.downloadJobResults)
+			protected.POST("/jobs/:id/cancel", s.cancelJob)
+			protected.GET("/templates", s.listTemplates)
+			protected.POST("/templates", s.requireRole("admin", "operator"), s.createTemplate)
+			protected.GET("/templates/:id", s.getTemplate)
+			protected.PUT("/templates/:id", s.requireRole("admin", "operator"), s.updateTemplate)
+			protected.DELETE("/templates/:id", s.requireRole("admin", "operator"), s.deleteTemplate)
+			protected.POST("/templates/:id/render", s.renderTemplateEndpoint)
+			protected.POST("/templates/:id/deploy", s.deployTemplate)
+			protected.GET("/variables", s.getGlobalVariables)
+			protected.PUT("/variables", s.requireRole("admin", "operator"), s.setGlobalVariables)
+			protected.GET("/sites/:site/variables", s.getSiteVariables)
+			protected.PUT("/sites/:site/variables", s.requireRole("admin", "operator"), s.setSiteVariables)
+			protected.GET("/switches/:id/variables", s.getSwitchVariables)
+			protected.PUT("/switches/:id/variables", s.requireRole("admin", "operator"), s.setSwitchVariables)
+			protected.GET("/switches/:id/backups", s.listBackups)
+			protected.POST("/switches/:id/backups", s.requireRole("admin", "operator"), s.createBackup)
+			protected.GET("/switches/:id/backups/:backupId", s.getBackup)
+			protected.GET("/compliance", s.getCompliance)
+			protected.GET("/compliance/rules", s.listComplianceRules)
+			protected.POST("/compliance/rules", s.requireRole("admin"), s.createComplianceRule)
+			protected.PUT("/compliance/rules/:id", s.requireRole("admin"), s.updateComplianceRule)
+			protected.DELETE("/compliance/rules/:id", s.requireRole("admin"), s.deleteComplianceRule)
+			protected.POST("/compliance/remediate", s.requireRole("admin", "operator"), s.remediateCompliance)
+			protected.GET("/switches/:id/desired-state", s.getDesiredState)
+			protected.PUT("/switches/:id/desired-state", s.requireRole("admin", "operator"), s.setDesiredState)
+			protected.DELETE("/switches/:id/desired-state", s.requireRole("admin", "operator"), s.deleteDesiredState)
+			protected.POST("/switches/:id/desired-state/reconcile", s.requireRole("admin", "operator"), s.reconcileEndpoint)
+			protected.GET("/drift", s.listDrift)
+			protected.GET("/changes", s.listChangePlans)
+			protected.POST("/changes", s.requireRole("admin", "operator"), s.createChangePlan)
+			protected.GET("/changes/:id", s.getChangePlan)
+			protected.GET("/changes/:id/preview", s.previewChangePlan)
+			protected.POST("/changes/:id/approve", s.requireRole("admin"), s.approveChangePlan)
+			protected.POST("/changes/:id/reject", s.requireRole("admin"), s.rejectChangePlan)
+			protected.POST("/changes/:id/execute", s.requireRole("admin", "operator"), s.executeChangePlan)
+			protected.POST("/changes/:id/cancel", s.requireRole("admin", "operator"), s.cancelChangePlan)
+			protected.GET("/hardware", s.searchHardware)
+			protected.GET("/transceivers", s.listTransceivers)
+			protected.GET("/poe", s.listPoE)
+			protected.GET("/transceivers/thresholds", s.getDOMThresholds)
+			protected.PUT("/transceivers/thresholds", s.requireRole("admin"), s.setDOMThresholds)
+			protected.GET("/topology", s.getTopology)
+			protected.GET("/fabric", s.getFabric)
+			protected.GET("/search/endpoint", s.searchEndpoint)
+			protected.GET("/firmware/targets", s.listFirmwareTargets)
+			protected.PUT("/firmware/targets/:family", s.requireRole("admin"), s.setFirmwareTarget)
+			protected.DELETE("/firmware/targets/:family", s.requireRole("admin"), s.deleteFirmwareTarget)
+			protected.GET("/firmware/compliance", s.getFirmwareCompliance)
+			protected.GET("/firmware/images", s.listImages)
+			protected.POST("/firmware/images", s.requireRole("admin"), s.uploadImage)
+			protected.GET("/firmware/images/:id", s.getImage)
+			protected.DELETE("/firmware/images/:id", s.requireRole("admin"), s.deleteImage)
+			protected.GET("/upgrades", s.listUpgrades)
+			protected.POST("/upgrades", s.requireRole("admin"), s.createUpgrade)
+			protected.GET("/upgrades/:id", s.getUpgrade)
+			protected.POST("/upgrades/:id/activate", s.requireRole("admin"), s.activateUpgradeEndpoint)
+			protected.POST("/upgrades/:id/rollback", s.requireRole("admin"), s.rollbackUpgrade)
+			protected.POST("/upgrades/:id/cancel", s.requireRole("admin"), s.cancelUpgrade)
+			protected.GET("/credentials", s.requireRole("admin"), s.listCredentials)
+			protected.PUT("/credentials/:name", s.requireRole("admin"), s.setCredential)
+			protected.DELETE("/credentials/:name", s.requireRole("admin"), s.deleteCredential)
+			protected.POST("/inventory/import", s.requireRole("admin"), s.importInventory)
+			protected.GET("/inventory/export", s.exportInventory)
+			protected.GET("/discovery", s.listDiscoveries)
+			protected.POST("/discovery", s.requireRole("admin"), s.createDiscovery)
+			protected.GET("/discovery/:id", s.getDiscovery)
+			protected.POST("/discovery/:id/cancel", s.requireRole("admin"), s.cancelDiscovery)
+			protected.POST("/discovery/:id/onboard", s.requireRole("admin"), s.onboardDiscoveries)
 		}
 
 		// Public upload endpoint (no auth required as it's called by the switch)
+
+		// Image downloads by switches staging firmware, authorized by the
+		// image's token
+		v1.GET("/firmware/images/:id/download", s.downloadImage)
 	}
 }
 
@@ -125,19 +300,31 @@ func (s *Server) Run(addr string) error {
 	return s.router.Run(addr)
 }
 
-func (s *Server) healthCheck(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{
-		"status":  "healthy",
-		"service": "OpenExtremeManagement",
-	})
-}
-
+// listSwitches returns all switches, or with ?firmware and
+// ?firmware_severity only those in that firmware compliance state. ?tag
+// keeps switches carrying the tag.
 func (s *Server) listSwitches(c *gin.Context) {
+	filter, err := parseFirmwareFilter(c.Query("firmware"), c.Query("firmware_severity"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var targets []FirmwareTarget
+	if filter != nil {
+		targets = s.firmware.list()
+	}
+
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
 	switches := make([]*Switch, 0, len(s.switches))
 	for _, sw := range s.switches {
+		if filter != nil && !filter.matches(firmwareStatus(sw, targets)) {
+			continue
+		}
+		if tag := c.Query("tag"); tag != "" && !hasTag(sw, tag) {
+			continue
+		}
 		switches = append(switches, sw)
 	}
 
@@ -170,6 +357,13 @@ type CreateSwitchRequest struct {
 	UseHTTPS  *bool  `json:"use_https"` // Pointer to detect if provided, defaults to true
 	Username  string `json:"username" binding:"required"`
 	Password  string `json:"password" binding:"required"`
+
+	TLSMode       string `json:"tls_mode"` // tofu (default), ca or insecure
+	TLSServerName string `json:"tls_server_name"`
+	CACert        string `json:"ca_cert"` // PEM, overrides the global CA bundle
+
+	Site string   `json:"site"`
+	Tags []string `json:"tags"`
 }
 
 func (s *Server) createSwitch(c *gin.Context) {
@@ -185,6 +379,33 @@ func (s *Server) createSwitch(c *gin.Context) {
 		useHTTPS = *req.UseHTTPS
 	}
 
+	tlsMode := TLSModeTOFU
+	if req.TLSMode != "" {
+		if !validTLSMode(req.TLSMode) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tls_mode: must be tofu, ca or insecure"})
+			return
+		}
+		tlsMode = req.TLSMode
+	}
+	if err := validateTags(req.Tags); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Capture the certificate now so the pin reflects what the operator
+	// saw when adding the switch. If the switch is unreachable the pin is
+	// taken on the first successful connection instead.
+	var cert *CertificateInfo
+	if useHTTPS && tlsMode == TLSModeTOFU {
+		leaf, err := fetchCertificate(req.IPAddress, req.Port)
+		if err != nil {
+			logging.FromContext(c.Request.Context()).Warn("could not capture certificate, pinning on first connection",
+				"switch_ip", req.IPAddress, "switch_port", req.Port, "error", err)
+		} else {
+			cert = newCertificateInfo(leaf)
+		}
+	}
+
 	s.mu.Lock()
 	sw := &Switch{
 		ID:        s.nextID,
@@ -195,13 +416,26 @@ func (s *Server) createSwitch(c *gin.Context) {
 		Username:  req.Username,
 		Password:  req.Password,
 		Status:    "connecting",
+
+		TLSMode:       tlsMode,
+		TLSServerName: req.TLSServerName,
+		CACert:        req.CACert,
+
+		Site: req.Site,
+		Tags: req.Tags,
+	}
+	if cert != nil {
+		sw.Certificate = cert
+		sw.CertFingerprint = cert.Fingerprint
 	}
 	s.switches[s.nextID] = sw
 	s.nextID++
 	s.mu.Unlock()
 
+	s.events.Publish(EventSwitchAdded, sw.ID, gin.H{"ip_address": sw.IPAddress, "port": sw.Port})
+
 	// Trigger immediate sync for this switch
-	go s.syncSwitch(sw)
+	go s.syncSwitch(detach(c), sw)
 
 	c.JSON(http.StatusCreated, gin.H{"switch": sw})
 }
@@ -223,6 +457,14 @@ func (s *Server) deleteSwitch(c *gin.Context) {
 	}
 
 	delete(s.switches, id)
+	s.templates.forgetSwitch(id)
+	s.backups.forgetSwitch(id)
+	s.desiredState.forgetSwitch(id)
+	s.metrics.forgetSwitch(id)
+	s.endpoints.forgetSwitch(id)
+	s.stats.forgetSwitch(id)
+	s.transceivers.forgetSwitch(id)
+	s.events.Publish(EventSwitchDeleted, id, nil)
 	c.JSON(http.StatusOK, gin.H{"message": "Switch deleted"})
 }
 
@@ -232,6 +474,13 @@ type UpdateSwitchRequest struct {
 	UseHTTPS  *bool  `json:"use_https"`
 	Username  string `json:"username"`
 	Password  string `json:"password"`
+
+	TLSMode       string  `json:"tls_mode"`
+	TLSServerName *string `json:"tls_server_name"`
+	CACert        *string `json:"ca_cert"`
+
+	Site *string   `json:"site"`
+	Tags *[]string `json:"tags"`
 }
 
 func (s *Server) updateSwitch(c *gin.Context) {
@@ -248,6 +497,17 @@ func (s *Server) updateSwitch(c *gin.Context) {
 		return
 	}
 
+	if req.TLSMode != "" && !validTLSMode(req.TLSMode) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tls_mode: must be tofu, ca or insecure"})
+		return
+	}
+	if req.Tags != nil {
+		if err := validateTags(*req.Tags); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
 	s.mu.Lock()
 	sw, exists := s.switches[id]
 	if !exists {
@@ -256,6 +516,14 @@ func (s *Server) updateSwitch(c *gin.Context) {
 		return
 	}
 
+	// A different endpoint presents a different certificate, so the pin
+	// only survives if the address stays the same
+	if (req.IPAddress != "" && req.IPAddress != sw.IPAddress) || (req.Port != 0 && req.Port != sw.Port) {
+		sw.CertFingerprint = ""
+		sw.Certificate = nil
+		sw.PendingCertificate = nil
+	}
+
 	// Update fields
 	if req.IPAddress != "" {
 		sw.IPAddress = req.IPAddress
@@ -266,6 +534,10 @@ func (s *Server) updateSwitch(c *gin.Context) {
 	if req.UseHTTPS != nil {
 		sw.UseHTTPS = *req.UseHTTPS
 	}
+	if req.Username != "" || req.Password != "" {
+		// Explicit credentials detach the switch from a stored one
+		sw.CredentialRef = ""
+	}
 	if req.Username != "" {
 		sw.Username = req.Username
 	}
@@ -275,14 +547,31 @@ func (s *Server) updateSwitch(c *gin.Context) {
 		sw.AuthToken = ""
 		sw.TokenExpiry = time.Time{}
 	}
+	if req.TLSMode != "" {
+		sw.TLSMode = req.TLSMode
+	}
+	if req.TLSServerName != nil {
+		sw.TLSServerName = *req.TLSServerName
+	}
+	if req.CACert != nil {
+		sw.CACert = *req.CACert
+	}
+	if req.Site != nil {
+		sw.Site = *req.Site
+	}
+	if req.Tags != nil {
+		sw.Tags = *req.Tags
+	}
+	sw.resetSwitchClient()
 
 	// Update name temporarily
 	sw.Name = fmt.Sprintf("%s:%d", sw.IPAddress, sw.Port)
-	sw.Status = "connecting"
 	s.mu.Unlock()
 
+	s.setSwitchStatus(sw, "connecting")
+
 	// Trigger re-sync
-	go s.syncSwitch(sw)
+	go s.syncSwitch(detach(c), sw)
 
 	c.JSON(http.StatusOK, gin.H{"switch": sw})
 }
@@ -305,7 +594,7 @@ func (s *Server) syncSwitchEndpoint(c *gin.Context) {
 	}
 
 	// Trigger sync in background
-	go s.syncSwitch(sw)
+	go s.syncSwitch(detach(c), sw)
 
 	c.JSON(http.StatusOK, gin.H{"message": "Sync triggered"})
 }
@@ -335,6 +624,15 @@ func (s *Server) getPorts(c *gin.Context) {
 		return
 	}
 
+	s.mu.RLock()
+	collected := sw.Ports
+	s.mu.RUnlock()
+
+	if collected != nil {
+		c.JSON(http.StatusOK, gin.H{"ports": collected})
+		return
+	}
+
 	// Generate mock ports based on numPorts
 	numPorts := 24
 	if sw.SystemInfo != nil {
@@ -366,10 +664,51 @@ func (s *Server) getPorts(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"ports": ports})
 }
 
+// fetchPorts reads operational port state from the switch
+func (s *Server) fetchPorts(ctx context.Context, sw *Switch) ([]Port, error) {
+	var states []struct {
+		PortName    string `json:"portName"`
+		AdminStatus string `json:"adminStatus"`
+		OperStatus  string `json:"operStatus"`
+		Speed       int    `json:"speed"`
+	}
+
+	if err := s.getSwitchState(ctx, sw, "/v0/state/ports", &states); err != nil {
+		return nil, err
+	}
+
+	ports := make([]Port, len(states))
+	for i, st := range states {
+		status := "down"
+		if st.AdminStatus == "DOWN" {
+			status = "disabled"
+		} else if st.OperStatus == "UP" {
+			status = "up"
+		}
+
+		speed := fmt.Sprintf("%dM", st.Speed)
+		if st.Speed >= 1000 {
+			speed = fmt.Sprintf("%dG", st.Speed/1000)
+		}
+
+		ports[i] = Port{
+			ID:     i + 1,
+			Name:   st.PortName,
+			Status: status,
+			Speed:  speed,
+		}
+	}
+
+	return ports, nil
+}
+
 type UpdateSystemInfoRequest struct {
 	SysName     string `json:"sysName"`
 	SysLocation string `json:"sysLocation"`
 	SysContact  string `json:"sysContact"`
+
+	Verify   *VerifySpec `json:"verify,omitempty"` // Post-change checks, with rollback on failure
+	AutoSave bool        `json:"auto_save,omitempty"`
 }
 
 func (s *Server) updateSystemInfo(c *gin.Context) {
@@ -395,21 +734,45 @@ func (s *Server) updateSystemInfo(c *gin.Context) {
 		return
 	}
 
+	ctx := switchContext(c.Request.Context(), sw, "update_system_info")
+
 	// Authenticate if needed
-	if sw.AuthToken == "" || time.Now().After(sw.TokenExpiry) {
-		if err := s.authenticateSwitch(sw); err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication failed: " + err.Error()})
-			return
-		}
+	if err := s.ensureAuthenticated(ctx, sw); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication failed: " + err.Error()})
+		return
 	}
 
 	// Update system info on the switch
-	if err := s.pushSystemInfoToSwitch(sw, &req); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update switch: " + err.Error()})
+	verification, err := s.pushSystemInfoToSwitch(ctx, sw, &req)
+	if err != nil {
+		details := gin.H{"request": req, "error": err.Error()}
+		if verification != nil {
+			details["rolled_back"] = verification.RolledBack
+		}
+		s.audit(c, "system.update", sw.ID, "failure", details)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to update switch: " + err.Error(), "verification": verification})
 		return
 	}
+	s.audit(c, "system.update", sw.ID, "success", gin.H{"request": req})
+
+	s.cacheSystemInfo(sw, &req, c.GetString("username"))
+
+	if req.AutoSave {
+		if err := s.saveConfig(ctx, sw); err != nil {
+			s.audit(c, "config.save", sw.ID, "failure", gin.H{"error": err.Error()})
+			c.JSON(http.StatusBadGateway, gin.H{"error": "Switch updated but save config failed: " + err.Error(), "switch": sw, "verification": verification})
+			return
+		}
+		s.audit(c, "config.save", sw.ID, "success", nil)
+	}
+
+	logging.FromContext(ctx).Info("updated system info", "switch_name", sw.Name)
+	c.JSON(http.StatusOK, gin.H{"switch": sw, "verification": verification})
+}
 
-	// Update local cache
+// cacheSystemInfo records values just pushed to the switch so they show
+// before the next sync
+func (s *Server) cacheSystemInfo(sw *Switch, req *UpdateSystemInfoRequest, changedBy string) {
 	s.mu.Lock()
 	if sw.SystemInfo == nil {
 		sw.SystemInfo = &SystemInfo{}
@@ -418,26 +781,26 @@ func (s *Server) updateSystemInfo(c *gin.Context) {
 		sw.SystemInfo.SysName = req.SysName
 		sw.Name = req.SysName
 	}
-	sw.SystemInfo.SysLocation = req.SysLocation
-	sw.SystemInfo.SysContact = req.SysContact
+	if req.SysLocation != "" {
+		sw.SystemInfo.SysLocation = req.SysLocation
+	}
+	if req.SysContact != "" {
+		sw.SystemInfo.SysContact = req.SysContact
+	}
 	s.mu.Unlock()
 
-	log.Printf("✅ Updated system info for %s", sw.Name)
-	c.JSON(http.StatusOK, gin.H{"switch": sw})
+	s.events.Publish(EventSwitchConfig, sw.ID, gin.H{
+		"sysName":     req.SysName,
+		"sysLocation": req.SysLocation,
+		"sysContact":  req.SysContact,
+		"changed_by":  changedBy,
+	})
 }
 
-// pushSystemInfoToSwitch sends system info updates to the switch via CLI
-func (s *Server) pushSystemInfoToSwitch(sw *Switch, req *UpdateSystemInfoRequest) error {
-	protocol := "http"
-	if sw.UseHTTPS {
-		protocol = "https"
-	}
-
-	// Extreme Networks doesn't support PATCH/PUT on /config/system
-	// We must use the CLI endpoint to modify these values
-	url := fmt.Sprintf("%s://%s:%d/rest/openapi/v0/operation/system/cli", protocol, sw.IPAddress, sw.Port)
-
-	// Build CLI commands
+// systemInfoCommands builds the CLI that applies req.
+// Extreme Networks doesn't support PATCH/PUT on /config/system, so these
+// values must be changed through the CLI endpoint.
+func systemInfoCommands(req *UpdateSystemInfoRequest) []string {
 	commands := []string{"configure terminal"}
 	
 	if req.SysName != "" {
@@ -450,48 +813,27 @@ func (s *Server) pushSystemInfoToSwitch(sw *Switch, req *UpdateSystemInfoRequest
 		commands = append(commands, fmt.Sprintf("snmp-server contact %s", req.SysContact))
 	}
 	
-	commands = append(commands, "exit")
-
-	payload := map[string]interface{}{
-		"commands": commands,
-	}
-
-	jsonData, err := json.Marshal(payload)
-	if err != nil {
-		return fmt.Errorf("failed to marshal payload: %v", err)
-	}
-
-	log.Printf("🔧 Updating system info on %s via CLI: %v", sw.Name, commands)
-
-	httpReq, _ := http.NewRequest("POST", url, bytes.NewReader(jsonData))
-	httpReq.Header.Set("X-Auth-Token", sw.AuthToken)
-	httpReq.Header.Set("Content-Type", "application/json")
-
-	resp, err := insecureClient.Do(httpReq)
-	if err != nil {
-		return fmt.Errorf("request failed: %v", err)
-	}
-	defer resp.Body.Close()
-
-	body, _ := io.ReadAll(resp.Body)
-
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		log.Printf("❌ CLI command failed (status %d): %s", resp.StatusCode, string(body))
-		return fmt.Errorf("status %d: %s", resp.StatusCode, string(body))
-	}
+	return append(commands, "exit")
+}
 
-	log.Printf("✅ System info updated successfully on %s", sw.Name)
-	return nil
+// pushSystemInfoToSwitch applies req and, if req.Verify is set, checks
+// the values read back and rolls the change back if they don't
+func (s *Server) pushSystemInfoToSwitch(ctx context.Context, sw *Switch, req *UpdateSystemInfoRequest) (*VerificationResult, error) {
+	_, verification, err := s.pushWithVerification(ctx, sw, systemInfoCommands(req), req.Verify, req)
+	return verification, err
 }
 
+const syncInterval = 30 * time.Second
+
 // Background sync loop
 func (s *Server) syncLoop() {
-	ticker := time.NewTicker(30 * time.Second)
+	ticker := time.NewTicker(syncInterval)
 	defer ticker.Stop()
 
 	for {
 		select {
 		case <-ticker.C:
+			s.syncHeartbeat.Store(time.Now().UnixNano())
 			s.syncAllSwitches()
 		case <-s.stopSync:
 			return
@@ -507,40 +849,111 @@ func (s *Server) syncAllSwitches() {
 	}
 	s.mu.RUnlock()
 
+	// A large fleet can take longer than the ticker interval, so progress
+	// is reported per switch
 	for _, sw := range switches {
-		s.syncSwitch(sw)
+		s.syncSwitch(context.Background(), sw)
+		s.syncHeartbeat.Store(time.Now().UnixNano())
 	}
 }
 
-func (s *Server) syncSwitch(sw *Switch) {
-	log.Printf("🔄 Syncing switch %s (%s:%d)", sw.Name, sw.IPAddress, sw.Port)
+func (s *Server) syncSwitch(ctx context.Context, sw *Switch) {
+	ctx = switchContext(ctx, sw, "sync")
+	logger := logging.FromContext(ctx)
+	logger.Debug("syncing switch", "switch_name", sw.Name)
+
+	start := time.Now()
+	var syncErr error
+	defer func() {
+		id := strconv.Itoa(sw.ID)
+		s.metrics.syncDuration.WithLabelValues(id).Observe(time.Since(start).Seconds())
+		s.metrics.syncTotal.WithLabelValues(id, result(syncErr)).Inc()
+	}()
 
 	// Authenticate if needed
-	if sw.AuthToken == "" || time.Now().After(sw.TokenExpiry) {
-		if err := s.authenticateSwitch(sw); err != nil {
-			log.Printf("❌ Auth failed for %s: %v", sw.Name, err)
-			s.mu.Lock()
-			sw.Status = "auth_failed"
-			s.mu.Unlock()
-			return
+	if err := s.ensureAuthenticated(ctx, sw); err != nil {
+		logger.Error("switch authentication failed", "switch_name", sw.Name, "error", err)
+		syncErr = err
+		status := "auth_failed"
+		if errors.Is(err, ErrCertificateMismatch) {
+			status = "cert_mismatch"
 		}
+		s.setSwitchStatus(sw, status)
+		return
 	}
 
 	// Fetch system info
-	systemInfo, err := s.fetchSystemInfo(sw)
+	systemInfo, err := s.fetchSystemInfo(ctx, sw)
 	if err != nil {
-		log.Printf("❌ Sync failed for %s: %v", sw.Name, err)
-		s.mu.Lock()
-		sw.Status = "error"
-		s.mu.Unlock()
+		logger.Error("sync failed", "switch_name", sw.Name, "error", err)
+		syncErr = err
+		status := "error"
+		if errors.Is(err, ErrCertificateMismatch) {
+			status = "cert_mismatch"
+		}
+		s.setSwitchStatus(sw, status)
 		return
 	}
 
+	// Port state is optional; older firmware may not expose it
+	ports, err := s.fetchPorts(ctx, sw)
+	if err != nil {
+		logger.Warn("port poll failed", "switch_name", sw.Name, "error", err)
+	}
+	countersAt := time.Now()
+	counters, err := s.fetchPortCounters(ctx, sw)
+	if err != nil {
+		logger.Warn("port counter poll failed", "switch_name", sw.Name, "error", err)
+	}
+	neighbors, err := s.fetchNeighbors(ctx, sw)
+	if err != nil {
+		logger.Warn("LLDP poll failed", "switch_name", sw.Name, "error", err)
+	}
+	environment, err := s.fetchEnvironment(ctx, sw)
+	if err != nil {
+		logger.Warn("environment poll failed", "switch_name", sw.Name, "error", err)
+	}
+	poe, err := s.fetchPoE(ctx, sw)
+	if err != nil {
+		logger.Warn("PoE poll failed", "switch_name", sw.Name, "error", err)
+	}
+	var fabric *FabricInfo
+	if systemInfo.NosType == NosFabricEngine {
+		if fabric, err = s.fetchFabric(ctx, sw); err != nil {
+			logger.Warn("fabric poll failed", "switch_name", sw.Name, "error", err)
+		}
+	}
+
 	// Update switch data
 	s.mu.Lock()
 	now := time.Now()
-	sw.Status = "online"
 	sw.LastSync = &now
+	if ports != nil {
+		sw.Ports = ports
+	}
+	if neighbors != nil {
+		sw.Neighbors = mergeNeighbors(sw.Neighbors, neighbors, sw.Ports)
+	}
+	if environment != nil {
+		sw.Environment = environment
+		sw.HealthScore = &environment.Score
+	}
+	// A failed poll keeps the last known environment, so a switch stays
+	// degraded until its fault is seen cleared
+	status := "online"
+	if sw.Environment != nil && sw.Environment.Degraded() {
+		status = StatusDegraded
+	}
+	if poe != nil {
+		sw.PoE = poe
+	}
+	if fabric != nil || systemInfo.NosType != NosFabricEngine {
+		sw.Fabric = fabric
+	}
+	var previousCards []Card
+	if sw.SystemInfo != nil {
+		previousCards = sw.SystemInfo.Cards
+	}
 	sw.SystemInfo = systemInfo
 	// Update name from sysName
 	if systemInfo.SysName != "" {
@@ -548,15 +961,45 @@ func (s *Server) syncSwitch(sw *Switch) {
 	}
 	s.mu.Unlock()
 
-	log.Printf("✅ Synced %s - %s (%s)", sw.Name, systemInfo.ModelName, systemInfo.FirmwareVersion)
-}
+	s.setSwitchStatus(sw, status)
+	s.events.Publish(EventSwitchSynced, sw.ID, gin.H{
+		"model":    systemInfo.ModelName,
+		"firmware": systemInfo.FirmwareVersion,
+	})
+
+	logger.Info("synced switch", "switch_name", sw.Name, "model", systemInfo.ModelName, "firmware", systemInfo.FirmwareVersion)
 
-func (s *Server) authenticateSwitch(sw *Switch) error {
-	protocol := "http"
-	if sw.UseHTTPS {
-		protocol = "https"
+	s.recordHardwareChanges(ctx, sw, previousCards, systemInfo.Cards)
+
+	if counters != nil {
+		s.stats.record(sw.ID, countersAt, systemInfo.SysUpTime, counters)
+	}
+
+	if s.backups.claimScheduled(sw.ID) {
+		if _, err := s.backupConfig(ctx, sw, "scheduled"); err != nil {
+			logger.Warn("config backup failed", "switch_name", sw.Name, "error", err)
+		}
 	}
-	url := fmt.Sprintf("%s://%s:%d/rest/openapi/auth/token", protocol, sw.IPAddress, sw.Port)
+
+	if s.endpoints.claimScheduled(sw.ID) {
+		if err := s.collectEndpoints(ctx, sw); err != nil {
+			logger.Warn("endpoint table poll failed", "switch_name", sw.Name, "error", err)
+		}
+	}
+
+	if s.transceivers.claimScheduled(sw.ID) {
+		if err := s.collectTransceivers(ctx, sw); err != nil {
+			logger.Warn("transceiver poll failed", "switch_name", sw.Name, "error", err)
+		}
+	}
+
+	s.checkDesiredState(ctx, sw)
+}
+
+func (s *Server) authenticateSwitch(ctx context.Context, sw *Switch) (err error) {
+	defer func() {
+		s.metrics.tokenRefresh.WithLabelValues(strconv.Itoa(sw.ID), result(err)).Inc()
+	}()
 
 	authReq := map[string]interface{}{
 		"username": sw.Username,
@@ -564,13 +1007,24 @@ func (s *Server) authenticateSwitch(sw *Switch) error {
 		"ttl":      3600,
 	}
 
+	client, err := s.switchClient(sw)
+	if err != nil {
+		return err
+	}
+
 	body, _ := json.Marshal(authReq)
-	req, _ := http.NewRequest("POST", url, bytes.NewBuffer(body))
+	req, err := http.NewRequestWithContext(ctx, "POST", switchURL(sw, "/auth/token"), bytes.NewBuffer(body))
+	if err != nil {
+		return err
+	}
 	req.Header.Set("Content-Type", "application/json")
-	
-	resp, err := insecureClient.Do(req)
+	if id := logging.RequestID(ctx); id != "" {
+		req.Header.Set(requestIDHeader, id)
+	}
+
+	resp, err := client.Do(req)
 	if err != nil {
-		return fmt.Errorf("connection failed: %v", err)
+		return fmt.Errorf("connection failed: %w", err)
 	}
 	defer resp.Body.Close()
 
@@ -592,30 +1046,12 @@ func (s *Server) authenticateSwitch(sw *Switch) error {
 	sw.TokenExpiry = time.Now().Add(time.Duration(authResp.TTL) * time.Second)
 	s.mu.Unlock()
 
+	logging.FromContext(ctx).Debug("refreshed switch token", "ttl", authResp.TTL)
+
 	return nil
 }
 
-func (s *Server) fetchSystemInfo(sw *Switch) (*SystemInfo, error) {
-	protocol := "http"
-	if sw.UseHTTPS {
-		protocol = "https"
-	}
-	url := fmt.Sprintf("%s://%s:%d/rest/openapi/v0/state/system", protocol, sw.IPAddress, sw.Port)
-
-	req, _ := http.NewRequest("GET", url, nil)
-	req.Header.Set("X-Auth-Token", sw.AuthToken)
-
-	resp, err := insecureClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("request failed: %v", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("status %d: %s", resp.StatusCode, string(body))
-	}
-
+func (s *Server) fetchSystemInfo(ctx context.Context, sw *Switch) (*SystemInfo, error) {
 	var state struct {
 		SysName        string `json:"sysName"`
 		SysDescription string `json:"sysDescription"`
@@ -624,15 +1060,15 @@ func (s *Server) fetchSystemInfo(sw *Switch) (*SystemInfo, error) {
 		NosType        string `json:"nosType"`
 		ChassisId      string `json:"chassisId"`
 		IsDigitalTwin  bool   `json:"isDigitalTwin"`
+		IsConfigDirty  bool   `json:"isConfigDirty"`
 		Cards          []struct {
-			ModelName       string `json:"modelName"`
-			FirmwareVersion string `json:"firmwareVersion"`
-			NumPorts        int    `json:"numPorts"`
+			Card
+			SysUpTime int64 `json:"sysUpTime"`
 		} `json:"cards"`
 	}
 
-	if err := json.NewDecoder(resp.Body).Decode(&state); err != nil {
-		return nil, fmt.Errorf("invalid response: %v", err)
+	if err := s.getSwitchState(ctx, sw, "/v0/state/system", &state); err != nil {
+		return nil, err
 	}
 
 	info := &SystemInfo{
@@ -643,12 +1079,20 @@ func (s *Server) fetchSystemInfo(sw *Switch) (*SystemInfo, error) {
 		NosType:        state.NosType,
 		ChassisId:      state.ChassisId,
 		IsDigitalTwin:  state.IsDigitalTwin,
+		IsConfigDirty:  state.IsConfigDirty,
 	}
 
+	// The first card describes the switch; stacks and chassis add ports
+	// with every further card
+	info.Cards = make([]Card, len(state.Cards))
+	for i, card := range state.Cards {
+		info.Cards[i] = card.Card
+		info.NumPorts += card.NumPorts
+	}
 	if len(state.Cards) > 0 {
 		info.ModelName = state.Cards[0].ModelName
 		info.FirmwareVersion = state.Cards[0].FirmwareVersion
-		info.NumPorts = state.Cards[0].NumPorts
+		info.SysUpTime = state.Cards[0].SysUpTime
 	}
 
 	return info, nil
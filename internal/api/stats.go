@@ -0,0 +1,409 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// Beyond this many points a query must use a larger step
+	maxStatsPoints = 10000
+	// Points returned when the query does not give a step
+	defaultStatsPoints = 300
+)
+
+// Port counters, in the order of counterSet
+const (
+	counterInOctets = iota
+	counterOutOctets
+	counterInPackets
+	counterOutPackets
+	counterInErrors
+	counterOutErrors
+	counterInDiscards
+	counterOutDiscards
+	counterCRCErrors
+	numCounters
+)
+
+// counterSet holds one reading of every counter of a port
+type counterSet [numCounters]uint64
+
+// counterReading is the last reading of a port, the base of the next delta
+type counterReading struct {
+	at       time.Time
+	upTime   int64 // Switch uptime in seconds when read
+	counters counterSet
+}
+
+// statsBucket accumulates counter deltas over a time slot. Keeping counts
+// instead of rates lets buckets be merged into coarser ones exactly.
+type statsBucket struct {
+	start   time.Time
+	seconds float64 // Time covered by the deltas
+	deltas  [numCounters]float64
+}
+
+func (b *statsBucket) merge(o statsBucket) {
+	b.seconds += o.seconds
+	for i := range b.deltas {
+		b.deltas[i] += o.deltas[i]
+	}
+}
+
+// statsTier is the series of a port at one resolution. Width zero keeps
+// every poll as it came in.
+type statsTier struct {
+	name      string
+	width     time.Duration
+	retention time.Duration
+	buckets   []statsBucket // Oldest first
+}
+
+func (t *statsTier) add(b statsBucket, now time.Time) {
+	if t.width > 0 {
+		b.start = b.start.Truncate(t.width)
+		if n := len(t.buckets); n > 0 && t.buckets[n-1].start.Equal(b.start) {
+			t.buckets[n-1].merge(b)
+			return
+		}
+	}
+	t.buckets = append(t.buckets, b)
+
+	cutoff := now.Add(-t.retention)
+	drop := 0
+	for drop < len(t.buckets) && t.buckets[drop].start.Add(t.width).Before(cutoff) {
+		drop++
+	}
+	if drop > 0 {
+		t.buckets = append([]statsBucket(nil), t.buckets[drop:]...)
+	}
+}
+
+// StatsStore keeps the counter time series of every port: each poll, plus
+// 5-minute and hourly rollups that are kept longer
+type StatsStore struct {
+	mu     sync.RWMutex
+	tiers  []statsTier // Template of the tiers of a new series, finest first
+	last   map[int]map[string]counterReading
+	series map[int]map[string][]statsTier
+}
+
+func NewStatsStore(raw, fiveMinute, hourly time.Duration) *StatsStore {
+	return &StatsStore{
+		tiers: []statsTier{
+			{name: "raw", retention: raw},
+			{name: "5m", width: 5 * time.Minute, retention: fiveMinute},
+			{name: "1h", width: time.Hour, retention: hourly},
+		},
+		last:   make(map[int]map[string]counterReading),
+		series: make(map[int]map[string][]statsTier),
+	}
+}
+
+// statsRetention parses a retention setting such as "168h", falling back
+// to the default when it is empty or invalid
+func statsRetention(setting, value string, fallback time.Duration) time.Duration {
+	if value == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		slog.Error("invalid stats retention, using default", "setting", setting, "value", value, "default", fallback.String())
+		return fallback
+	}
+	return d
+}
+
+// forgetSwitch drops the series of a deleted switch
+func (ss *StatsStore) forgetSwitch(switchID int) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	delete(ss.last, switchID)
+	delete(ss.series, switchID)
+}
+
+// counterDelta is how much a counter advanced from prev to cur. A counter
+// that went backwards while still within 32 bits wrapped, unless that
+// would mean it advanced more than half its range since the last poll:
+// then, like a 64-bit counter going backwards, it was cleared and counts
+// from zero again.
+func counterDelta(prev, cur uint64) float64 {
+	if cur >= prev {
+		return float64(cur - prev)
+	}
+	if prev <= math.MaxUint32 {
+		if wrapped := cur + (1 << 32) - prev; wrapped < 1<<31 {
+			return float64(wrapped)
+		}
+	}
+	return float64(cur)
+}
+
+// record adds a poll of the port counters of a switch. The first poll of
+// a port only sets the base for the next one. A switch whose uptime went
+// down rebooted, and its counters count from boot.
+func (ss *StatsStore) record(switchID int, at time.Time, upTime int64, counters map[string]counterSet) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	if ss.last[switchID] == nil {
+		ss.last[switchID] = make(map[string]counterReading)
+		ss.series[switchID] = make(map[string][]statsTier)
+	}
+	last, series := ss.last[switchID], ss.series[switchID]
+
+	for port, cur := range counters {
+		prev, ok := last[port]
+		last[port] = counterReading{at: at, upTime: upTime, counters: cur}
+		if !ok || !at.After(prev.at) {
+			continue
+		}
+
+		b := statsBucket{start: at, seconds: at.Sub(prev.at).Seconds()}
+		if upTime > 0 && upTime < prev.upTime {
+			if float64(upTime) < b.seconds {
+				b.seconds = float64(upTime)
+			}
+			for i := range cur {
+				b.deltas[i] = float64(cur[i])
+			}
+		} else {
+			for i := range cur {
+				b.deltas[i] = counterDelta(prev.counters[i], cur[i])
+			}
+		}
+		if b.seconds <= 0 {
+			continue
+		}
+
+		tiers, ok := series[port]
+		if !ok {
+			tiers = append([]statsTier(nil), ss.tiers...)
+			series[port] = tiers
+		}
+		for i := range tiers {
+			tiers[i].add(b, at)
+		}
+	}
+}
+
+// StatsPoint holds the rates of a port over one step, per second. Octets
+// are reported as bits.
+type StatsPoint struct {
+	Time        time.Time `json:"time"`
+	InBps       float64   `json:"in_bps"`
+	OutBps      float64   `json:"out_bps"`
+	InPps       float64   `json:"in_pps"`
+	OutPps      float64   `json:"out_pps"`
+	InErrors    float64   `json:"in_errors"`
+	OutErrors   float64   `json:"out_errors"`
+	InDiscards  float64   `json:"in_discards"`
+	OutDiscards float64   `json:"out_discards"`
+	CRCErrors   float64   `json:"crc_errors"`
+}
+
+func (b *statsBucket) point() StatsPoint {
+	rate := func(i int) float64 { return b.deltas[i] / b.seconds }
+	return StatsPoint{
+		Time:        b.start,
+		InBps:       rate(counterInOctets) * 8,
+		OutBps:      rate(counterOutOctets) * 8,
+		InPps:       rate(counterInPackets),
+		OutPps:      rate(counterOutPackets),
+		InErrors:    rate(counterInErrors),
+		OutErrors:   rate(counterOutErrors),
+		InDiscards:  rate(counterInDiscards),
+		OutDiscards: rate(counterOutDiscards),
+		CRCErrors:   rate(counterCRCErrors),
+	}
+}
+
+// query returns the rates of a port between from and to, one point per
+// step with data. It reads the finest tier that still covers from and is
+// no finer than step, and the name of that tier.
+func (ss *StatsStore) query(switchID int, port string, from, to time.Time, step time.Duration) ([]StatsPoint, string) {
+	ss.mu.RLock()
+	defer ss.mu.RUnlock()
+
+	tiers := ss.series[switchID][port]
+	if len(tiers) == 0 {
+		return []StatsPoint{}, ss.pickTier(ss.tiers, from, step).name
+	}
+	tier := ss.pickTier(tiers, from, step)
+
+	var out []statsBucket
+	for _, b := range tier.buckets {
+		if b.start.Before(from) || !b.start.Before(to) {
+			continue
+		}
+		start := b.start.Truncate(step)
+		if n := len(out); n > 0 && out[n-1].start.Equal(start) {
+			out[n-1].merge(b)
+			continue
+		}
+		b.start = start
+		out = append(out, b)
+	}
+
+	points := make([]StatsPoint, len(out))
+	for i := range out {
+		points[i] = out[i].point()
+	}
+	return points, tier.name
+}
+
+// pickTier chooses the tier a query reads. Caller holds ss.mu.
+func (ss *StatsStore) pickTier(tiers []statsTier, from time.Time, step time.Duration) *statsTier {
+	age := time.Since(from)
+	for i := range tiers {
+		if tiers[i].width <= step && age <= tiers[i].retention {
+			return &tiers[i]
+		}
+	}
+	// A step finer than the tiers that go back far enough gets their
+	// resolution
+	for i := range tiers {
+		if age <= tiers[i].retention {
+			return &tiers[i]
+		}
+	}
+	// Older than every retention: the longest kept tier has what is left
+	return &tiers[len(tiers)-1]
+}
+
+// fetchPortCounters reads the counters of every port from the switch
+func (s *Server) fetchPortCounters(ctx context.Context, sw *Switch) (map[string]counterSet, error) {
+	var entries []struct {
+		PortName    string `json:"portName"`
+		InOctets    uint64 `json:"inOctets"`
+		OutOctets   uint64 `json:"outOctets"`
+		InPkts      uint64 `json:"inPkts"`
+		OutPkts     uint64 `json:"outPkts"`
+		InErrors    uint64 `json:"inErrors"`
+		OutErrors   uint64 `json:"outErrors"`
+		InDiscards  uint64 `json:"inDiscards"`
+		OutDiscards uint64 `json:"outDiscards"`
+		CRCErrors   uint64 `json:"crcErrors"`
+	}
+
+	if err := s.getSwitchState(ctx, sw, "/v0/state/ports/statistics", &entries); err != nil {
+		return nil, err
+	}
+
+	counters := make(map[string]counterSet, len(entries))
+	for _, e := range entries {
+		counters[e.PortName] = counterSet{
+			counterInOctets:    e.InOctets,
+			counterOutOctets:   e.OutOctets,
+			counterInPackets:   e.InPkts,
+			counterOutPackets:  e.OutPkts,
+			counterInErrors:    e.InErrors,
+			counterOutErrors:   e.OutErrors,
+			counterInDiscards:  e.InDiscards,
+			counterOutDiscards: e.OutDiscards,
+			counterCRCErrors:   e.CRCErrors,
+		}
+	}
+	return counters, nil
+}
+
+// parseStatsTime accepts RFC 3339 or Unix seconds
+func parseStatsTime(value string) (time.Time, error) {
+	if secs, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(secs, 0), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// parseStatsStep accepts a duration such as 5m, or seconds
+func parseStatsStep(value string) (time.Duration, error) {
+	if secs, err := strconv.Atoi(value); err == nil {
+		return time.Duration(secs) * time.Second, nil
+	}
+	return time.ParseDuration(value)
+}
+
+// getPortStats serves the traffic rates of one port for graphs. ?from and
+// ?to bound the range, the last hour by default; ?step sets the spacing
+// of the points, by default about 300 of them.
+func (s *Server) getPortStats(c *gin.Context) {
+	sw, ok := s.lookupSwitch(c)
+	if !ok {
+		return
+	}
+	port := c.Param("port")
+
+	s.mu.RLock()
+	known := sw.Ports == nil
+	for _, p := range sw.Ports {
+		if p.Name == port {
+			known = true
+			break
+		}
+	}
+	s.mu.RUnlock()
+	if !known {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Port not found"})
+		return
+	}
+
+	to := time.Now()
+	if v := c.Query("to"); v != "" {
+		t, err := parseStatsTime(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to, use RFC 3339 or Unix seconds"})
+			return
+		}
+		to = t
+	}
+	from := to.Add(-time.Hour)
+	if v := c.Query("from"); v != "" {
+		t, err := parseStatsTime(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from, use RFC 3339 or Unix seconds"})
+			return
+		}
+		from = t
+	}
+	if !from.Before(to) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must be before to"})
+		return
+	}
+
+	step := (to.Sub(from) / defaultStatsPoints).Truncate(time.Second)
+	if step < syncInterval {
+		step = syncInterval
+	}
+	if v := c.Query("step"); v != "" {
+		d, err := parseStatsStep(v)
+		if err != nil || d < time.Second {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid step, use a duration of at least 1s"})
+			return
+		}
+		step = d
+	}
+	if to.Sub(from)/step > maxStatsPoints {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Range too large for step, at most %d points", maxStatsPoints)})
+		return
+	}
+
+	points, resolution := s.stats.query(sw.ID, port, from, to, step)
+	c.JSON(http.StatusOK, gin.H{
+		"switch_id":  sw.ID,
+		"port":       port,
+		"from":       from,
+		"to":         to,
+		"step":       int(step.Seconds()),
+		"resolution": resolution,
+		"points":     points,
+	})
+}
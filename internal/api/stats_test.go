@@ -0,0 +1,143 @@
+package api
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestCounterDelta(t *testing.T) {
+	tests := []struct {
+		name      string
+		prev, cur uint64
+		want      float64
+	}{
+		{"advanced", 1000, 1500, 500},
+		{"unchanged", 1000, 1000, 0},
+		{"32-bit wrap", math.MaxUint32 - 99, 100, 200},
+		{"32-bit wrap at the boundary", math.MaxUint32, 0, 1},
+		{"32-bit clear", 1_000_000_000, 5, 5},
+		{"32-bit wrap near half the range", 3_000_000_000, 5, 1_294_967_301},
+		{"64-bit clear", 1 << 40, 7, 7},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := counterDelta(tt.prev, tt.cur); got != tt.want {
+				t.Errorf("counterDelta(%d, %d) = %v, want %v", tt.prev, tt.cur, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStatsStoreRecord(t *testing.T) {
+	const port = "1/1"
+	octets := func(n uint64) map[string]counterSet {
+		var c counterSet
+		c[counterInOctets] = n
+		return map[string]counterSet{port: c}
+	}
+	t0 := time.Now().Add(-time.Hour)
+
+	tests := []struct {
+		name        string
+		polls       []int64  // Seconds since t0
+		upTimes     []int64  // Switch uptime at each poll
+		counters    []uint64 // In octets at each poll
+		wantSeconds []float64
+		wantDeltas  []float64
+	}{
+		{
+			name:        "first poll only sets the base",
+			polls:       []int64{0},
+			upTimes:     []int64{1000},
+			counters:    []uint64{5000},
+			wantSeconds: nil,
+			wantDeltas:  nil,
+		},
+		{
+			name:        "steady traffic",
+			polls:       []int64{0, 60, 120},
+			upTimes:     []int64{1000, 1060, 1120},
+			counters:    []uint64{5000, 65000, 125000},
+			wantSeconds: []float64{60, 60},
+			wantDeltas:  []float64{60000, 60000},
+		},
+		{
+			name:        "32-bit wrap",
+			polls:       []int64{0, 60},
+			upTimes:     []int64{1000, 1060},
+			counters:    []uint64{math.MaxUint32 - 999, 59000},
+			wantSeconds: []float64{60},
+			wantDeltas:  []float64{60000},
+		},
+		{
+			// Counters restart from zero at boot, 20 s before the poll
+			name:        "reboot between polls",
+			polls:       []int64{0, 60},
+			upTimes:     []int64{1000, 20},
+			counters:    []uint64{900000, 20000},
+			wantSeconds: []float64{20},
+			wantDeltas:  []float64{20000},
+		},
+		{
+			// The switch came back long before the poll; only the time
+			// since the previous poll is covered
+			name:        "reboot longer ago than the poll interval",
+			polls:       []int64{0, 60},
+			upTimes:     []int64{1000, 500},
+			counters:    []uint64{900000, 60000},
+			wantSeconds: []float64{60},
+			wantDeltas:  []float64{60000},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ss := NewStatsStore(24*time.Hour, 7*24*time.Hour, 90*24*time.Hour)
+			for i, offset := range tt.polls {
+				ss.record(1, t0.Add(time.Duration(offset)*time.Second), tt.upTimes[i], octets(tt.counters[i]))
+			}
+
+			var raw []statsBucket
+			if tiers := ss.series[1][port]; len(tiers) > 0 {
+				raw = tiers[0].buckets
+			}
+			if len(raw) != len(tt.wantDeltas) {
+				t.Fatalf("got %d raw buckets, want %d", len(raw), len(tt.wantDeltas))
+			}
+			for i, b := range raw {
+				if b.seconds != tt.wantSeconds[i] {
+					t.Errorf("bucket %d covers %v s, want %v", i, b.seconds, tt.wantSeconds[i])
+				}
+				if got := b.deltas[counterInOctets]; got != tt.wantDeltas[i] {
+					t.Errorf("bucket %d in octets = %v, want %v", i, got, tt.wantDeltas[i])
+				}
+			}
+		})
+	}
+}
+
+func TestStatsStoreRollup(t *testing.T) {
+	ss := NewStatsStore(24*time.Hour, 7*24*time.Hour, 90*24*time.Hour)
+	t0 := time.Now().Truncate(time.Hour).Add(-2 * time.Hour)
+
+	// Twelve polls a minute apart, 1000 octets per second
+	for i := 0; i <= 12; i++ {
+		var c counterSet
+		c[counterInOctets] = uint64(i) * 60000
+		ss.record(1, t0.Add(time.Duration(i)*time.Minute), int64(1000+i*60), map[string]counterSet{"1/1": c})
+	}
+
+	for _, tier := range ss.series[1]["1/1"] {
+		var seconds, octets float64
+		for _, b := range tier.buckets {
+			seconds += b.seconds
+			octets += b.deltas[counterInOctets]
+		}
+		if seconds != 720 || octets != 720000 {
+			t.Errorf("tier %s holds %v octets over %v s, want 720000 over 720", tier.name, octets, seconds)
+		}
+	}
+	if n := len(ss.series[1]["1/1"][1].buckets); n != 3 {
+		t.Errorf("5m tier has %d buckets, want 3", n)
+	}
+}
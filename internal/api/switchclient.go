@@ -0,0 +1,86 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/JarvisTchibClawBot/OpenExtremeManagement/internal/logging"
+)
+
+// switchURL builds the URL of a Fabric Engine OpenAPI path on the switch
+func switchURL(sw *Switch, path string) string {
+	protocol := "http"
+	if sw.UseHTTPS {
+		protocol = "https"
+	}
+	return fmt.Sprintf("%s://%s:%d/rest/openapi%s", protocol, sw.IPAddress, sw.Port, path)
+}
+
+// newSwitchRequest builds a request to the switch carrying the auth token
+// and the request ID of the API call that caused it
+func newSwitchRequest(ctx context.Context, sw *Switch, method, path string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, switchURL(sw, path), body)
+	if err != nil {
+		return nil, err
+	}
+
+	if sw.AuthToken != "" {
+		req.Header.Set("X-Auth-Token", sw.AuthToken)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if id := logging.RequestID(ctx); id != "" {
+		req.Header.Set(requestIDHeader, id)
+	}
+
+	return req, nil
+}
+
+// ensureAuthenticated obtains a new token if the switch has none or it
+// has expired
+func (s *Server) ensureAuthenticated(ctx context.Context, sw *Switch) error {
+	s.mu.RLock()
+	valid := sw.AuthToken != "" && time.Now().Before(sw.TokenExpiry)
+	s.mu.RUnlock()
+
+	if valid {
+		return nil
+	}
+	return s.authenticateSwitch(ctx, sw)
+}
+
+// getSwitchState performs an authenticated GET against the switch and
+// decodes the JSON response into out
+func (s *Server) getSwitchState(ctx context.Context, sw *Switch, path string, out interface{}) error {
+	client, err := s.switchClient(sw)
+	if err != nil {
+		return err
+	}
+
+	req, err := newSwitchRequest(ctx, sw, "GET", path, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("status %d: %s", resp.StatusCode, string(body))
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("invalid response: %v", err)
+	}
+
+	return nil
+}
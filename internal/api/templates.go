@@ -0,0 +1,561 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/JarvisTchibClawBot/OpenExtremeManagement/internal/logging"
+	"github.com/gin-gonic/gin"
+)
+
+// Variable names must be usable as {{ .Vars.name }} in a template
+var variableNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ConfigTemplate is a stored Go text/template that renders to CLI lines.
+// Every non-blank line of the output is sent to the switch as one command,
+// so the template must include "configure terminal" itself if needed.
+type ConfigTemplate struct {
+	ID          int       `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	Body        string    `json:"body"`
+	CreatedBy   string    `json:"created_by"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+
+	parsed *template.Template
+}
+
+// TemplateSwitch exposes switch facts to templates as {{ .Switch.Name }} etc.
+type TemplateSwitch struct {
+	ID        int
+	Name      string
+	IPAddress string
+	Site      string
+	Model     string
+	Firmware  string
+	ChassisID string
+}
+
+// TemplateData is the value templates are executed against
+type TemplateData struct {
+	Switch TemplateSwitch
+	Vars   map[string]string
+}
+
+// TemplateStore holds templates and the variables they are rendered with.
+// Switch variables override site variables, which override global ones.
+type TemplateStore struct {
+	mu        sync.RWMutex
+	templates map[int]*ConfigTemplate
+	nextID    int
+
+	global   map[string]string
+	sites    map[string]map[string]string
+	switches map[int]map[string]string
+}
+
+func NewTemplateStore() *TemplateStore {
+	return &TemplateStore{
+		templates: make(map[int]*ConfigTemplate),
+		nextID:    1,
+		global:    make(map[string]string),
+		sites:     make(map[string]map[string]string),
+		switches:  make(map[int]map[string]string),
+	}
+}
+
+// forgetSwitch drops the variables of a deleted switch
+func (ts *TemplateStore) forgetSwitch(id int) {
+	ts.mu.Lock()
+	delete(ts.switches, id)
+	ts.mu.Unlock()
+}
+
+// variablesFor merges the global, site and switch variables
+func (ts *TemplateStore) variablesFor(switchID int, site string) map[string]string {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+
+	vars := make(map[string]string)
+	for k, v := range ts.global {
+		vars[k] = v
+	}
+	if site != "" {
+		for k, v := range ts.sites[site] {
+			vars[k] = v
+		}
+	}
+	for k, v := range ts.switches[switchID] {
+		vars[k] = v
+	}
+	return vars
+}
+
+var templateFuncs = template.FuncMap{
+	// split turns a list variable such as "10.0.0.1,10.0.0.2" into items
+	"split": func(s, sep string) []string {
+		var items []string
+		for _, item := range strings.Split(s, sep) {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		return items
+	},
+	"default": func(def, value string) string {
+		if value == "" {
+			return def
+		}
+		return value
+	},
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+}
+
+// parseConfigTemplate compiles body. Missing variables are an error rather
+// than rendering as "<no value>".
+func parseConfigTemplate(name, body string) (*template.Template, error) {
+	return template.New(name).Option("missingkey=error").Funcs(templateFuncs).Parse(body)
+}
+
+// renderConfigTemplate executes tmpl for sw and returns the CLI lines
+func (s *Server) renderConfigTemplate(tmpl *template.Template, sw *Switch) ([]string, error) {
+	s.mu.RLock()
+	data := TemplateData{Switch: TemplateSwitch{
+		ID:        sw.ID,
+		Name:      sw.Name,
+		IPAddress: sw.IPAddress,
+		Site:      sw.Site,
+	}}
+	if sw.SystemInfo != nil {
+		data.Switch.Model = sw.SystemInfo.ModelName
+		data.Switch.Firmware = sw.SystemInfo.FirmwareVersion
+		data.Switch.ChassisID = sw.SystemInfo.ChassisId
+	}
+	s.mu.RUnlock()
+	data.Vars = s.templates.variablesFor(sw.ID, data.Switch.Site)
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+
+	var lines []string
+	for _, line := range strings.Split(buf.String(), "\n") {
+		line = strings.TrimRight(line, " \t\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) == 0 {
+		return nil, fmt.Errorf("template rendered no commands")
+	}
+	if len(lines) > maxCLICommands {
+		return nil, fmt.Errorf("template rendered %d commands, at most %d are allowed", len(lines), maxCLICommands)
+	}
+	return lines, nil
+}
+
+type TemplateRequest struct {
+	Name        string `json:"name" binding:"required"`
+	Description string `json:"description"`
+	Body        string `json:"body" binding:"required"`
+}
+
+func (s *Server) listTemplates(c *gin.Context) {
+	s.templates.mu.RLock()
+	templates := make([]*ConfigTemplate, 0, len(s.templates.templates))
+	for _, t := range s.templates.templates {
+		templates = append(templates, t)
+	}
+	s.templates.mu.RUnlock()
+
+	sort.Slice(templates, func(i, j int) bool { return templates[i].ID < templates[j].ID })
+	c.JSON(http.StatusOK, gin.H{"templates": templates})
+}
+
+func (s *Server) createTemplate(c *gin.Context) {
+	var req TemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	parsed, err := parseConfigTemplate(req.Name, req.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid template: " + err.Error()})
+		return
+	}
+
+	now := time.Now()
+	t := &ConfigTemplate{
+		Name:        req.Name,
+		Description: req.Description,
+		Body:        req.Body,
+		CreatedBy:   c.GetString("username"),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		parsed:      parsed,
+	}
+
+	s.templates.mu.Lock()
+	t.ID = s.templates.nextID
+	s.templates.nextID++
+	s.templates.templates[t.ID] = t
+	s.templates.mu.Unlock()
+
+	s.audit(c, "template.create", 0, "success", gin.H{"template_id": t.ID, "name": t.Name})
+	c.JSON(http.StatusCreated, gin.H{"template": t})
+}
+
+func (s *Server) lookupTemplate(c *gin.Context) (*ConfigTemplate, bool) {
+	idStr := c.Param("id")
+	var id int
+	if _, err := fmt.Sscanf(idStr, "%d", &id); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid template ID"})
+		return nil, false
+	}
+
+	s.templates.mu.RLock()
+	t, exists := s.templates.templates[id]
+	s.templates.mu.RUnlock()
+
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
+		return nil, false
+	}
+	return t, true
+}
+
+func (s *Server) getTemplate(c *gin.Context) {
+	t, ok := s.lookupTemplate(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"template": t})
+}
+
+func (s *Server) updateTemplate(c *gin.Context) {
+	t, ok := s.lookupTemplate(c)
+	if !ok {
+		return
+	}
+
+	var req TemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	parsed, err := parseConfigTemplate(req.Name, req.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid template: " + err.Error()})
+		return
+	}
+
+	s.templates.mu.Lock()
+	t.Name = req.Name
+	t.Description = req.Description
+	t.Body = req.Body
+	t.UpdatedAt = time.Now()
+	t.parsed = parsed
+	s.templates.mu.Unlock()
+
+	s.audit(c, "template.update", 0, "success", gin.H{"template_id": t.ID, "name": t.Name})
+	c.JSON(http.StatusOK, gin.H{"template": t})
+}
+
+func (s *Server) deleteTemplate(c *gin.Context) {
+	t, ok := s.lookupTemplate(c)
+	if !ok {
+		return
+	}
+
+	s.templates.mu.Lock()
+	delete(s.templates.templates, t.ID)
+	s.templates.mu.Unlock()
+
+	s.audit(c, "template.delete", 0, "success", gin.H{"template_id": t.ID, "name": t.Name})
+	c.JSON(http.StatusOK, gin.H{"message": "Template deleted"})
+}
+
+// TemplateRender is the rendered CLI for one switch
+type TemplateRender struct {
+	SwitchID   int      `json:"switch_id"`
+	SwitchName string   `json:"switch_name"`
+	Commands   []string `json:"commands,omitempty"`
+	Error      string   `json:"error,omitempty"`
+}
+
+type TemplateTargetRequest struct {
+	Switches    SwitchSelector `json:"switches"`
+	Concurrency int            `json:"concurrency"`
+	Verify      *VerifySpec    `json:"verify"`    // Deploy only
+	AutoSave    bool           `json:"auto_save"` // Deploy only
+}
+
+// renderForTargets renders t for the switches selected by the request body
+func (s *Server) renderForTargets(c *gin.Context, t *ConfigTemplate) (*TemplateTargetRequest, []*Switch, []TemplateRender, bool) {
+	var req TemplateTargetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return nil, nil, nil, false
+	}
+
+	switches, err := s.selectSwitches(req.Switches)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, nil, nil, false
+	}
+	if len(switches) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No switches match the selector"})
+		return nil, nil, nil, false
+	}
+
+	s.templates.mu.RLock()
+	parsed := t.parsed
+	s.templates.mu.RUnlock()
+
+	renders := make([]TemplateRender, len(switches))
+	for i, sw := range switches {
+		lines, err := s.renderConfigTemplate(parsed, sw)
+		renders[i] = TemplateRender{SwitchID: sw.ID, SwitchName: sw.Name, Commands: lines}
+		if err != nil {
+			renders[i].Error = err.Error()
+		}
+	}
+	return &req, switches, renders, true
+}
+
+// renderTemplateEndpoint shows the exact commands a deploy would send to
+// each selected switch
+func (s *Server) renderTemplateEndpoint(c *gin.Context) {
+	t, ok := s.lookupTemplate(c)
+	if !ok {
+		return
+	}
+
+	_, _, renders, ok := s.renderForTargets(c, t)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"template_id": t.ID, "results": renders})
+}
+
+// deployTemplate renders the template for every selected switch and
+// pushes the result as a job. Nothing is sent unless every switch renders
+// and every line passes the caller's CLI policy.
+func (s *Server) deployTemplate(c *gin.Context) {
+	t, ok := s.lookupTemplate(c)
+	if !ok {
+		return
+	}
+
+	req, switches, renders, ok := s.renderForTargets(c, t)
+	if !ok {
+		return
+	}
+
+	for _, r := range renders {
+		if r.Error != "" {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Template failed to render for some switches", "results": renders})
+			return
+		}
+	}
+
+	role := c.GetString("role")
+	commands := make(map[int][]string, len(renders))
+	ids := make([]int, len(renders))
+	for i, r := range renders {
+		for _, cmd := range r.Commands {
+			if err := s.cliPolicy.Check(role, cmd); err != nil {
+				s.audit(c, "template.deploy", r.SwitchID, "denied", gin.H{"template_id": t.ID, "reason": logging.Scrub(err.Error())})
+				c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		commands[r.SwitchID] = r.Commands
+		ids[i] = r.SwitchID
+	}
+
+	concurrency := jobConcurrency(req.Concurrency)
+
+	job := &Job{
+		Type:        "template",
+		Status:      JobPending,
+		CreatedBy:   c.GetString("username"),
+		CreatedAt:   time.Now(),
+		TemplateID:  t.ID,
+		Verify:      req.Verify,
+		AutoSave:    req.AutoSave,
+		Concurrency: concurrency,
+		Progress:    JobProgress{Total: len(switches)},
+	}
+
+	s.startJob(c, job, switches, commands)
+	s.audit(c, "template.deploy", 0, "success", gin.H{"job_id": job.ID, "template_id": t.ID, "switch_ids": ids})
+
+	c.JSON(http.StatusAccepted, gin.H{"job": s.jobs.snapshot(job, true)})
+}
+
+type VariablesRequest struct {
+	Variables map[string]string `json:"variables" binding:"required"`
+}
+
+// bindVariables reads a variable map, rejecting names templates can't use
+func bindVariables(c *gin.Context) (map[string]string, bool) {
+	var req VariablesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return nil, false
+	}
+	for name := range req.Variables {
+		if !variableNamePattern.MatchString(name) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid variable name %q", name)})
+			return nil, false
+		}
+	}
+	return req.Variables, true
+}
+
+// variableNames lists the keys of vars for the audit trail; values may be
+// secrets such as SNMP communities
+func variableNames(vars map[string]string) []string {
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func copyVariables(vars map[string]string) map[string]string {
+	copied := make(map[string]string, len(vars))
+	for k, v := range vars {
+		copied[k] = v
+	}
+	return copied
+}
+
+func (s *Server) getGlobalVariables(c *gin.Context) {
+	s.templates.mu.RLock()
+	vars := copyVariables(s.templates.global)
+	s.templates.mu.RUnlock()
+	c.JSON(http.StatusOK, gin.H{"variables": vars})
+}
+
+func (s *Server) setGlobalVariables(c *gin.Context) {
+	vars, ok := bindVariables(c)
+	if !ok {
+		return
+	}
+
+	s.templates.mu.Lock()
+	s.templates.global = copyVariables(vars)
+	s.templates.mu.Unlock()
+
+	s.audit(c, "variables.update", 0, "success", gin.H{"scope": "global", "names": variableNames(vars)})
+	c.JSON(http.StatusOK, gin.H{"variables": vars})
+}
+
+func (s *Server) getSiteVariables(c *gin.Context) {
+	site := c.Param("site")
+
+	s.templates.mu.RLock()
+	vars := copyVariables(s.templates.sites[site])
+	s.templates.mu.RUnlock()
+	c.JSON(http.StatusOK, gin.H{"site": site, "variables": vars})
+}
+
+func (s *Server) setSiteVariables(c *gin.Context) {
+	site := c.Param("site")
+	vars, ok := bindVariables(c)
+	if !ok {
+		return
+	}
+
+	s.templates.mu.Lock()
+	if len(vars) == 0 {
+		delete(s.templates.sites, site)
+	} else {
+		s.templates.sites[site] = copyVariables(vars)
+	}
+	s.templates.mu.Unlock()
+
+	s.audit(c, "variables.update", 0, "success", gin.H{"scope": "site", "site": site, "names": variableNames(vars)})
+	c.JSON(http.StatusOK, gin.H{"site": site, "variables": vars})
+}
+
+func (s *Server) lookupSwitch(c *gin.Context) (*Switch, bool) {
+	idStr := c.Param("id")
+	var id int
+	if _, err := fmt.Sscanf(idStr, "%d", &id); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid switch ID"})
+		return nil, false
+	}
+
+	s.mu.RLock()
+	sw, exists := s.switches[id]
+	s.mu.RUnlock()
+
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Switch not found"})
+		return nil, false
+	}
+	return sw, true
+}
+
+// getSwitchVariables returns the switch's own variables and the merged set
+// its templates are rendered with
+func (s *Server) getSwitchVariables(c *gin.Context) {
+	sw, ok := s.lookupSwitch(c)
+	if !ok {
+		return
+	}
+
+	s.mu.RLock()
+	site := sw.Site
+	s.mu.RUnlock()
+
+	s.templates.mu.RLock()
+	vars := copyVariables(s.templates.switches[sw.ID])
+	s.templates.mu.RUnlock()
+
+	c.JSON(http.StatusOK, gin.H{
+		"switch_id": sw.ID,
+		"site":      site,
+		"variables": vars,
+		"effective": s.templates.variablesFor(sw.ID, site),
+	})
+}
+
+func (s *Server) setSwitchVariables(c *gin.Context) {
+	sw, ok := s.lookupSwitch(c)
+	if !ok {
+		return
+	}
+	vars, ok := bindVariables(c)
+	if !ok {
+		return
+	}
+
+	s.templates.mu.Lock()
+	if len(vars) == 0 {
+		delete(s.templates.switches, sw.ID)
+	} else {
+		s.templates.switches[sw.ID] = copyVariables(vars)
+	}
+	s.templates.mu.Unlock()
+
+	s.audit(c, "variables.update", sw.ID, "success", gin.H{"scope": "switch", "names": variableNames(vars)})
+	c.JSON(http.StatusOK, gin.H{"switch_id": sw.ID, "variables": vars})
+}
@@ -0,0 +1,313 @@
+package api
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/JarvisTchibClawBot/OpenExtremeManagement/internal/logging"
+	"github.com/gin-gonic/gin"
+)
+
+// TLS verification modes for switch connections
+const (
+	TLSModeTOFU     = "tofu"     // Pin the first certificate seen and verify against it
+	TLSModeCA       = "ca"       // Verify against a CA bundle
+	TLSModeInsecure = "insecure" // Skip verification entirely (lab use only)
+)
+
+// ErrCertificateMismatch is returned when a switch presents a certificate
+// that differs from the pinned one.
+var ErrCertificateMismatch = errors.New("certificate does not match pinned fingerprint")
+
+// CertificateInfo describes a certificate presented by a switch
+type CertificateInfo struct {
+	Subject      string    `json:"subject"`
+	Issuer       string    `json:"issuer"`
+	SerialNumber string    `json:"serial_number"`
+	DNSNames     []string  `json:"dns_names,omitempty"`
+	IPAddresses  []string  `json:"ip_addresses,omitempty"`
+	NotBefore    time.Time `json:"not_before"`
+	NotAfter     time.Time `json:"not_after"`
+	Fingerprint  string    `json:"fingerprint_sha256"`
+	SelfSigned   bool      `json:"self_signed"`
+}
+
+func newCertificateInfo(cert *x509.Certificate) *CertificateInfo {
+	info := &CertificateInfo{
+		Subject:      cert.Subject.String(),
+		Issuer:       cert.Issuer.String(),
+		SerialNumber: cert.SerialNumber.String(),
+		DNSNames:     cert.DNSNames,
+		NotBefore:    cert.NotBefore,
+		NotAfter:     cert.NotAfter,
+		Fingerprint:  certFingerprint(cert.Raw),
+		SelfSigned:   cert.Subject.String() == cert.Issuer.String(),
+	}
+	for _, ip := range cert.IPAddresses {
+		info.IPAddresses = append(info.IPAddresses, ip.String())
+	}
+	return info
+}
+
+// certFingerprint returns the SHA-256 fingerprint in the colon-separated
+// uppercase hex format used by openssl
+func certFingerprint(raw []byte) string {
+	sum := sha256.Sum256(raw)
+	parts := make([]string, len(sum))
+	for i, b := range sum {
+		parts[i] = fmt.Sprintf("%02X", b)
+	}
+	return strings.Join(parts, ":")
+}
+
+func normalizeFingerprint(fp string) string {
+	fp = strings.ToUpper(strings.TrimSpace(fp))
+	if !strings.Contains(fp, ":") && len(fp) == 64 {
+		parts := make([]string, 0, 32)
+		for i := 0; i < len(fp); i += 2 {
+			parts = append(parts, fp[i:i+2])
+		}
+		fp = strings.Join(parts, ":")
+	}
+	return fp
+}
+
+func validTLSMode(mode string) bool {
+	return mode == TLSModeTOFU || mode == TLSModeCA || mode == TLSModeInsecure
+}
+
+// switchClient returns the HTTP client for a switch, building it from the
+// switch's TLS policy on first use
+func (s *Server) switchClient(sw *Switch) (*http.Client, error) {
+	s.mu.RLock()
+	client := sw.client
+	s.mu.RUnlock()
+	if client != nil {
+		return client, nil
+	}
+
+	tlsConfig, err := s.switchTLSConfig(sw)
+	if err != nil {
+		return nil, err
+	}
+
+	client = &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			TLSClientConfig: tlsConfig,
+		},
+	}
+
+	s.mu.Lock()
+	sw.client = client
+	s.mu.Unlock()
+
+	return client, nil
+}
+
+// resetSwitchClient drops the cached client so TLS settings are re-read
+// on the next request. Caller must hold s.mu.
+func (sw *Switch) resetSwitchClient() {
+	if sw.client != nil {
+		sw.client.CloseIdleConnections()
+	}
+	sw.client = nil
+}
+
+func (s *Server) switchTLSConfig(sw *Switch) (*tls.Config, error) {
+	s.mu.RLock()
+	mode := sw.TLSMode
+	serverName := sw.TLSServerName
+	caCert := sw.CACert
+	s.mu.RUnlock()
+
+	// Verification is done in VerifyConnection for pinned and insecure
+	// modes, so the default chain check is disabled for those.
+	cfg := &tls.Config{
+		InsecureSkipVerify: true,
+		VerifyConnection: func(cs tls.ConnectionState) error {
+			return s.verifySwitchConnection(sw, cs)
+		},
+	}
+
+	if mode == TLSModeCA {
+		pool, err := s.caPool(caCert)
+		if err != nil {
+			return nil, err
+		}
+		cfg.InsecureSkipVerify = false
+		cfg.RootCAs = pool
+		cfg.ServerName = serverName
+	}
+
+	return cfg, nil
+}
+
+// caPool builds the trust pool from the per-switch CA certificate, falling
+// back to the configured bundle and finally the system roots
+func (s *Server) caPool(caCert string) (*x509.CertPool, error) {
+	pem := []byte(caCert)
+	if len(pem) == 0 && s.config.TLSCABundle != "" {
+		data, err := os.ReadFile(s.config.TLSCABundle)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle: %v", err)
+		}
+		pem = data
+	}
+
+	if len(pem) == 0 {
+		return x509.SystemCertPool()
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no valid certificates in CA bundle")
+	}
+	return pool, nil
+}
+
+// verifySwitchConnection records the presented certificate and, in TOFU
+// mode, checks it against the pinned fingerprint
+func (s *Server) verifySwitchConnection(sw *Switch, cs tls.ConnectionState) error {
+	if len(cs.PeerCertificates) == 0 {
+		return fmt.Errorf("switch presented no certificate")
+	}
+
+	presented := newCertificateInfo(cs.PeerCertificates[0])
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if sw.TLSMode != TLSModeTOFU {
+		sw.Certificate = presented
+		return nil
+	}
+
+	if sw.CertFingerprint == "" {
+		sw.CertFingerprint = presented.Fingerprint
+		sw.Certificate = presented
+		slog.Info("pinned switch certificate", "switch_id", sw.ID, "switch_ip", sw.IPAddress, "fingerprint", presented.Fingerprint)
+		return nil
+	}
+
+	if sw.CertFingerprint != presented.Fingerprint {
+		sw.PendingCertificate = presented
+		return fmt.Errorf("%w: presented %s, pinned %s", ErrCertificateMismatch, presented.Fingerprint, sw.CertFingerprint)
+	}
+
+	sw.Certificate = presented
+	sw.PendingCertificate = nil
+	return nil
+}
+
+// fetchCertificate connects to the switch and returns its leaf certificate
+// without verifying it
+func fetchCertificate(host string, port int) (*x509.Certificate, error) {
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+	addr := net.JoinHostPort(host, fmt.Sprintf("%d", port))
+
+	conn, err := tls.DialWithDialer(dialer, "tcp", addr, &tls.Config{InsecureSkipVerify: true})
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	certs := conn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("switch presented no certificate")
+	}
+	return certs[0], nil
+}
+
+func (s *Server) getCertificate(c *gin.Context) {
+	idStr := c.Param("id")
+	var id int
+	if _, err := fmt.Sscanf(idStr, "%d", &id); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid switch ID"})
+		return
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sw, exists := s.switches[id]
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Switch not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"tls_mode":            sw.TLSMode,
+		"pinned_fingerprint":  sw.CertFingerprint,
+		"certificate":         sw.Certificate,
+		"pending_certificate": sw.PendingCertificate,
+	})
+}
+
+type AcceptCertificateRequest struct {
+	Fingerprint string `json:"fingerprint" binding:"required"`
+}
+
+// acceptCertificate replaces the pinned fingerprint with the certificate the
+// switch is currently presenting. The caller must echo the fingerprint they
+// reviewed so a certificate that changed again in the meantime is not pinned.
+func (s *Server) acceptCertificate(c *gin.Context) {
+	idStr := c.Param("id")
+	var id int
+	if _, err := fmt.Sscanf(idStr, "%d", &id); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid switch ID"})
+		return
+	}
+
+	var req AcceptCertificateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	s.mu.Lock()
+	sw, exists := s.switches[id]
+	if !exists {
+		s.mu.Unlock()
+		c.JSON(http.StatusNotFound, gin.H{"error": "Switch not found"})
+		return
+	}
+
+	if sw.PendingCertificate == nil {
+		s.mu.Unlock()
+		c.JSON(http.StatusConflict, gin.H{"error": "No pending certificate to accept"})
+		return
+	}
+
+	if normalizeFingerprint(req.Fingerprint) != sw.PendingCertificate.Fingerprint {
+		s.mu.Unlock()
+		c.JSON(http.StatusConflict, gin.H{"error": "Fingerprint does not match the pending certificate"})
+		return
+	}
+
+	previous := sw.CertFingerprint
+	sw.CertFingerprint = sw.PendingCertificate.Fingerprint
+	sw.Certificate = sw.PendingCertificate
+	sw.PendingCertificate = nil
+	sw.AuthToken = ""
+	sw.resetSwitchClient()
+	s.mu.Unlock()
+
+	s.setSwitchStatus(sw, "connecting")
+
+	logging.FromContext(switchContext(c.Request.Context(), sw, "accept_certificate")).Warn("switch certificate re-pinned",
+		"user", c.GetString("username"), "previous_fingerprint", previous, "fingerprint", sw.CertFingerprint)
+
+	go s.syncSwitch(detach(c), sw)
+
+	c.JSON(http.StatusOK, gin.H{"switch": sw})
+}
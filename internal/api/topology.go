@@ -0,0 +1,321 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LLDPNeighbor is a device seen over LLDP on a port. Neighbors of ports
+// that went down are kept, marked stale, so the link still shows up in
+// the topology as down.
+type LLDPNeighbor struct {
+	LocalPort         string    `json:"local_port"`
+	ChassisID         string    `json:"chassis_id"`
+	PortID            string    `json:"port_id"`
+	PortDescription   string    `json:"port_description,omitempty"`
+	SysName           string    `json:"sys_name,omitempty"`
+	SysDescription    string    `json:"sys_description,omitempty"`
+	ManagementAddress string    `json:"management_address,omitempty"`
+	LastSeen          time.Time `json:"last_seen"`
+	Stale             bool      `json:"stale,omitempty"`
+}
+
+// fetchNeighbors reads the LLDP neighbor table from the switch
+func (s *Server) fetchNeighbors(ctx context.Context, sw *Switch) ([]LLDPNeighbor, error) {
+	var entries []struct {
+		LocalPort               string `json:"localPort"`
+		RemoteChassisId         string `json:"remoteChassisId"`
+		RemotePortId            string `json:"remotePortId"`
+		RemotePortDescription   string `json:"remotePortDescription"`
+		RemoteSysName           string `json:"remoteSysName"`
+		RemoteSysDescription    string `json:"remoteSysDescription"`
+		RemoteManagementAddress string `json:"remoteManagementAddress"`
+	}
+
+	if err := s.getSwitchState(ctx, sw, "/v0/state/lldp/neighbors", &entries); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	neighbors := make([]LLDPNeighbor, len(entries))
+	for i, e := range entries {
+		neighbors[i] = LLDPNeighbor{
+			LocalPort:         e.LocalPort,
+			ChassisID:         e.RemoteChassisId,
+			PortID:            e.RemotePortId,
+			PortDescription:   e.RemotePortDescription,
+			SysName:           e.RemoteSysName,
+			SysDescription:    e.RemoteSysDescription,
+			ManagementAddress: e.RemoteManagementAddress,
+			LastSeen:          now,
+		}
+	}
+	return neighbors, nil
+}
+
+// mergeNeighbors combines a fresh neighbor table with the previous one:
+// neighbors that aged out because their port is not up are carried over
+// as stale. Caller holds s.mu.
+func mergeNeighbors(previous, current []LLDPNeighbor, ports []Port) []LLDPNeighbor {
+	portUp := make(map[string]bool, len(ports))
+	for _, p := range ports {
+		portUp[p.Name] = p.Status == "up"
+	}
+	seen := make(map[string]bool, len(current))
+	for _, n := range current {
+		seen[n.LocalPort] = true
+	}
+
+	merged := append([]LLDPNeighbor(nil), current...)
+	for _, n := range previous {
+		if seen[n.LocalPort] || portUp[n.LocalPort] {
+			continue
+		}
+		n.Stale = true
+		merged = append(merged, n)
+	}
+	sort.Slice(merged, func(i, j int) bool { return portLess(merged[i].LocalPort, merged[j].LocalPort) })
+	return merged
+}
+
+// portLess orders port names like 1/2 before 1/10
+func portLess(a, b string) bool {
+	as, bs := strings.Split(a, "/"), strings.Split(b, "/")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		an, aErr := strconv.Atoi(as[i])
+		bn, bErr := strconv.Atoi(bs[i])
+		if aErr != nil || bErr != nil {
+			if as[i] != bs[i] {
+				return as[i] < bs[i]
+			}
+			continue
+		}
+		if an != bn {
+			return an < bn
+		}
+	}
+	return len(as) < len(bs)
+}
+
+// getNeighbors returns the LLDP neighbors collected at the last sync
+func (s *Server) getNeighbors(c *gin.Context) {
+	sw, ok := s.lookupSwitch(c)
+	if !ok {
+		return
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if sw.Neighbors == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "LLDP neighbors not collected yet"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"switch_id": sw.ID, "neighbors": sw.Neighbors})
+}
+
+// Topology node types
+const (
+	NodeManaged   = "managed"
+	NodeUnmanaged = "unmanaged"
+)
+
+// TopologyNode is a managed switch or a device only known from LLDP
+type TopologyNode struct {
+	ID        string `json:"id"`
+	Type      string `json:"type"`
+	Label     string `json:"label"`
+	SwitchID  int    `json:"switch_id,omitempty"`
+	ChassisID string `json:"chassis_id,omitempty"`
+	Status    string `json:"status,omitempty"` // Switch status, managed nodes only
+	Site      string `json:"site,omitempty"`
+}
+
+// TopologyEdge is a link between two nodes. It is down when a port at
+// either end is not up.
+type TopologyEdge struct {
+	Source     string    `json:"source"`
+	Target     string    `json:"target"`
+	SourcePort string    `json:"source_port"`
+	TargetPort string    `json:"target_port"`
+	Status     string    `json:"status"` // up or down
+	LastSeen   time.Time `json:"last_seen"`
+}
+
+// Topology is the graph served by /topology
+type Topology struct {
+	Nodes []TopologyNode `json:"nodes"`
+	Edges []TopologyEdge `json:"edges"`
+}
+
+func switchNodeID(id int) string { return "switch:" + strconv.Itoa(id) }
+
+// buildTopology links the neighbor tables of all switches into one graph.
+// Neighbors are matched to managed switches by chassis ID, and a link seen
+// from both ends is reported once. site limits the graph to the switches
+// of one site and their direct neighbors.
+func (s *Server) buildTopology(site string) Topology {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	byChassis := make(map[string]*Switch)
+	for _, sw := range s.switches {
+		if sw.SystemInfo != nil && sw.SystemInfo.ChassisId != "" {
+			byChassis[strings.ToLower(sw.SystemInfo.ChassisId)] = sw
+		}
+	}
+	portStatus := func(sw *Switch, name string) string {
+		for _, p := range sw.Ports {
+			if p.Name == name {
+				return p.Status
+			}
+		}
+		return ""
+	}
+
+	nodes := make(map[string]TopologyNode)
+	addSwitchNode := func(sw *Switch) string {
+		id := switchNodeID(sw.ID)
+		if _, ok := nodes[id]; !ok {
+			node := TopologyNode{ID: id, Type: NodeManaged, Label: sw.Name, SwitchID: sw.ID, Status: sw.Status, Site: sw.Site}
+			if sw.SystemInfo != nil {
+				node.ChassisID = sw.SystemInfo.ChassisId
+			}
+			nodes[id] = node
+		}
+		return id
+	}
+
+	edges := make(map[string]*TopologyEdge)
+	for _, sw := range s.switches {
+		if site != "" && sw.Site != site {
+			continue
+		}
+		source := addSwitchNode(sw)
+
+		for _, n := range sw.Neighbors {
+			status := "up"
+			if n.Stale || portStatus(sw, n.LocalPort) != "up" {
+				status = "down"
+			}
+
+			var target string
+			if peer, ok := byChassis[strings.ToLower(n.ChassisID)]; ok {
+				target = addSwitchNode(peer)
+				if ps := portStatus(peer, n.PortID); ps != "" && ps != "up" {
+					status = "down"
+				}
+			} else {
+				target = "chassis:" + strings.ToLower(n.ChassisID)
+				if _, ok := nodes[target]; !ok {
+					label := n.SysName
+					if label == "" {
+						label = n.ChassisID
+					}
+					nodes[target] = TopologyNode{ID: target, Type: NodeUnmanaged, Label: label, ChassisID: n.ChassisID}
+				}
+			}
+
+			// Both ends of a link between managed switches report it, so
+			// it is keyed and oriented the same way from either end
+			a, b := source+"|"+n.LocalPort, target+"|"+n.PortID
+			edge := TopologyEdge{Source: source, Target: target, SourcePort: n.LocalPort, TargetPort: n.PortID, Status: status, LastSeen: n.LastSeen}
+			if b < a && nodes[target].Type == NodeManaged {
+				a, b = b, a
+				edge.Source, edge.Target = target, source
+				edge.SourcePort, edge.TargetPort = n.PortID, n.LocalPort
+			}
+			key := a + "|" + b
+			if existing, ok := edges[key]; ok {
+				if status == "down" {
+					existing.Status = "down"
+				}
+				if n.LastSeen.After(existing.LastSeen) {
+					existing.LastSeen = n.LastSeen
+				}
+				continue
+			}
+			edges[key] = &edge
+		}
+	}
+
+	topo := Topology{Nodes: make([]TopologyNode, 0, len(nodes)), Edges: make([]TopologyEdge, 0, len(edges))}
+	for _, node := range nodes {
+		topo.Nodes = append(topo.Nodes, node)
+	}
+	for _, edge := range edges {
+		topo.Edges = append(topo.Edges, *edge)
+	}
+	sort.Slice(topo.Nodes, func(i, j int) bool {
+		if topo.Nodes[i].Type != topo.Nodes[j].Type {
+			return topo.Nodes[i].Type == NodeManaged
+		}
+		if topo.Nodes[i].SwitchID != topo.Nodes[j].SwitchID {
+			return topo.Nodes[i].SwitchID < topo.Nodes[j].SwitchID
+		}
+		return topo.Nodes[i].ID < topo.Nodes[j].ID
+	})
+	sort.Slice(topo.Edges, func(i, j int) bool {
+		if topo.Edges[i].Source != topo.Edges[j].Source {
+			return topo.Edges[i].Source < topo.Edges[j].Source
+		}
+		return portLess(topo.Edges[i].SourcePort, topo.Edges[j].SourcePort)
+	})
+	return topo
+}
+
+// dotQuote quotes a Graphviz ID
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", " ").Replace(s) + `"`
+}
+
+// dot renders the topology as an undirected Graphviz graph. Unmanaged
+// nodes are drawn dashed, degraded switches orange, down links red and
+// dashed.
+func (t Topology) dot() string {
+	var b strings.Builder
+	b.WriteString("graph topology {\n")
+	b.WriteString("\tnode [shape=box];\n")
+	for _, n := range t.Nodes {
+		attrs := "label=" + dotQuote(n.Label)
+		if n.Type == NodeUnmanaged {
+			attrs += ", style=dashed"
+		} else if n.Status == StatusDegraded {
+			attrs += ", color=orange"
+		} else if n.Status != "online" {
+			attrs += ", color=red"
+		}
+		fmt.Fprintf(&b, "\t%s [%s];\n", dotQuote(n.ID), attrs)
+	}
+	for _, e := range t.Edges {
+		attrs := "taillabel=" + dotQuote(e.SourcePort) + ", headlabel=" + dotQuote(e.TargetPort)
+		if e.Status != "up" {
+			attrs += ", color=red, style=dashed"
+		}
+		fmt.Fprintf(&b, "\t%s -- %s [%s];\n", dotQuote(e.Source), dotQuote(e.Target), attrs)
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// getTopology serves the LLDP topology as nodes/edges JSON, or as DOT
+// with ?format=dot. ?site limits it to one site.
+func (s *Server) getTopology(c *gin.Context) {
+	topo := s.buildTopology(c.Query("site"))
+
+	switch c.DefaultQuery("format", "json") {
+	case "json":
+		c.JSON(http.StatusOK, topo)
+	case "dot":
+		c.Data(http.StatusOK, "text/vnd.graphviz; charset=utf-8", []byte(topo.dot()))
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be json or dot"})
+	}
+}
@@ -0,0 +1,606 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/JarvisTchibClawBot/OpenExtremeManagement/internal/logging"
+	"github.com/gin-gonic/gin"
+)
+
+// Optics drift slowly, so their monitoring values are read on every sync
+// only once this much time has passed
+const domInterval = 5 * time.Minute
+
+// Transceiver health, worst first
+const (
+	DOMAlarm   = "alarm"
+	DOMWarning = "warning"
+	DOMOK      = "ok"
+)
+
+// DOM metrics, the keys of thresholds and alerts
+const (
+	MetricTxPower     = "tx_power"    // dBm
+	MetricRxPower     = "rx_power"    // dBm
+	MetricTemperature = "temperature" // °C
+	MetricBias        = "bias"        // mA
+)
+
+var domMetrics = []string{MetricTxPower, MetricRxPower, MetricTemperature, MetricBias}
+
+// DOMReading holds the digital optical monitoring values of a transceiver
+type DOMReading struct {
+	TxPower     float64 `json:"tx_power"`
+	RxPower     float64 `json:"rx_power"`
+	Temperature float64 `json:"temperature"`
+	Bias        float64 `json:"bias"`
+}
+
+func (r *DOMReading) value(metric string) float64 {
+	switch metric {
+	case MetricTxPower:
+		return r.TxPower
+	case MetricRxPower:
+		return r.RxPower
+	case MetricTemperature:
+		return r.Temperature
+	default:
+		return r.Bias
+	}
+}
+
+// DOMThresholds bound the normal range of one metric. Crossing a warning
+// threshold flags an optic that is degrading while the link still works.
+type DOMThresholds struct {
+	LowAlarm    float64 `json:"low_alarm"`
+	LowWarning  float64 `json:"low_warning"`
+	HighWarning float64 `json:"high_warning"`
+	HighAlarm   float64 `json:"high_alarm"`
+}
+
+func (t DOMThresholds) validate() error {
+	if t.LowAlarm > t.LowWarning || t.LowWarning >= t.HighWarning || t.HighWarning > t.HighAlarm {
+		return fmt.Errorf("thresholds must satisfy low_alarm <= low_warning < high_warning <= high_alarm")
+	}
+	return nil
+}
+
+// defaultDOMThresholds apply to metrics the module reports no thresholds
+// for; they suit typical 10G SFP+ optics
+var defaultDOMThresholds = map[string]DOMThresholds{
+	MetricTxPower:     {LowAlarm: -11, LowWarning: -8, HighWarning: 1, HighAlarm: 3},
+	MetricRxPower:     {LowAlarm: -18, LowWarning: -14, HighWarning: 1, HighAlarm: 3},
+	MetricTemperature: {LowAlarm: -5, LowWarning: 0, HighWarning: 70, HighAlarm: 75},
+	MetricBias:        {LowAlarm: 2, LowWarning: 3, HighWarning: 12, HighAlarm: 15},
+}
+
+// TransceiverAlert is a DOM value outside its thresholds
+type TransceiverAlert struct {
+	Metric    string  `json:"metric"`
+	Level     string  `json:"level"` // warning or alarm
+	Value     float64 `json:"value"`
+	Threshold float64 `json:"threshold"`
+}
+
+// Transceiver is the optic plugged into a port, as collected at the last
+// DOM poll
+type Transceiver struct {
+	Port         string                   `json:"port"`
+	Type         string                   `json:"type"` // SFP+, QSFP28, ...
+	Vendor       string                   `json:"vendor"`
+	PartNumber   string                   `json:"part_number"`
+	SerialNumber string                   `json:"serial_number"`
+	Wavelength   int                      `json:"wavelength,omitempty"` // nm
+	DOM          *DOMReading              `json:"dom,omitempty"`        // Absent on optics without monitoring
+	Thresholds   map[string]DOMThresholds `json:"thresholds,omitempty"` // As reported by the module
+	Status       string                   `json:"status"`
+	Alerts       []TransceiverAlert       `json:"alerts,omitempty"`
+	CollectedAt  time.Time                `json:"collected_at"`
+}
+
+// DOMSample is one DOM reading of a port
+type DOMSample struct {
+	Time time.Time `json:"time"`
+	DOMReading
+}
+
+// TransceiverStore keeps the DOM series of every port and the thresholds
+// configured in OEM, which take precedence over the module's own
+type TransceiverStore struct {
+	mu         sync.RWMutex
+	thresholds map[string]DOMThresholds
+	series     map[int]map[string][]DOMSample // Oldest first
+	lastPoll   map[int]time.Time
+	retention  time.Duration
+}
+
+func NewTransceiverStore(retention time.Duration) *TransceiverStore {
+	return &TransceiverStore{
+		thresholds: make(map[string]DOMThresholds),
+		series:     make(map[int]map[string][]DOMSample),
+		lastPoll:   make(map[int]time.Time),
+		retention:  retention,
+	}
+}
+
+// claimScheduled reports whether the switch's DOM poll is due and, if so,
+// records the attempt
+func (ts *TransceiverStore) claimScheduled(switchID int) bool {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	if time.Since(ts.lastPoll[switchID]) < domInterval {
+		return false
+	}
+	ts.lastPoll[switchID] = time.Now()
+	return true
+}
+
+// forgetSwitch drops the series of a deleted switch
+func (ts *TransceiverStore) forgetSwitch(switchID int) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	delete(ts.series, switchID)
+	delete(ts.lastPoll, switchID)
+}
+
+// thresholdsFor picks the thresholds of a metric: configured in OEM, else
+// reported by the module, else the defaults. Caller holds ts.mu.
+func (ts *TransceiverStore) thresholdsFor(t *Transceiver, metric string) DOMThresholds {
+	if th, ok := ts.thresholds[metric]; ok {
+		return th
+	}
+	if th, ok := t.Thresholds[metric]; ok {
+		return th
+	}
+	return defaultDOMThresholds[metric]
+}
+
+// evaluate sets the status and alerts of t. A port that is shut down has
+// its laser off, which is not a fault.
+func (ts *TransceiverStore) evaluate(t *Transceiver, portDisabled bool) {
+	t.Status, t.Alerts = DOMOK, nil
+	if t.DOM == nil || portDisabled {
+		return
+	}
+
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+	for _, metric := range domMetrics {
+		th, v := ts.thresholdsFor(t, metric), t.DOM.value(metric)
+		var alert *TransceiverAlert
+		switch {
+		case v <= th.LowAlarm:
+			alert = &TransceiverAlert{Metric: metric, Level: DOMAlarm, Value: v, Threshold: th.LowAlarm}
+		case v >= th.HighAlarm:
+			alert = &TransceiverAlert{Metric: metric, Level: DOMAlarm, Value: v, Threshold: th.HighAlarm}
+		case v <= th.LowWarning:
+			alert = &TransceiverAlert{Metric: metric, Level: DOMWarning, Value: v, Threshold: th.LowWarning}
+		case v >= th.HighWarning:
+			alert = &TransceiverAlert{Metric: metric, Level: DOMWarning, Value: v, Threshold: th.HighWarning}
+		}
+		if alert == nil {
+			continue
+		}
+		t.Alerts = append(t.Alerts, *alert)
+		if alert.Level == DOMAlarm || t.Status == DOMOK {
+			t.Status = alert.Level
+		}
+	}
+}
+
+// record appends the DOM readings of a poll to the series of each port
+func (ts *TransceiverStore) record(switchID int, transceivers []Transceiver) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if ts.series[switchID] == nil {
+		ts.series[switchID] = make(map[string][]DOMSample)
+	}
+	series := ts.series[switchID]
+	for _, t := range transceivers {
+		if t.DOM == nil {
+			continue
+		}
+		samples := append(series[t.Port], DOMSample{Time: t.CollectedAt, DOMReading: *t.DOM})
+		cutoff := t.CollectedAt.Add(-ts.retention)
+		drop := 0
+		for drop < len(samples) && samples[drop].Time.Before(cutoff) {
+			drop++
+		}
+		if drop > 0 {
+			samples = append([]DOMSample(nil), samples[drop:]...)
+		}
+		series[t.Port] = samples
+	}
+}
+
+// query returns the DOM readings of a port between from and to, averaged
+// per step
+func (ts *TransceiverStore) query(switchID int, port string, from, to time.Time, step time.Duration) []DOMSample {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+
+	points := []DOMSample{}
+	n := 0
+	for _, sample := range ts.series[switchID][port] {
+		if sample.Time.Before(from) || !sample.Time.Before(to) {
+			continue
+		}
+		start := sample.Time.Truncate(step)
+		if len(points) > 0 && points[len(points)-1].Time.Equal(start) {
+			// Running mean of the samples in the step
+			n++
+			p := &points[len(points)-1]
+			f := 1 / float64(n)
+			p.TxPower += (sample.TxPower - p.TxPower) * f
+			p.RxPower += (sample.RxPower - p.RxPower) * f
+			p.Temperature += (sample.Temperature - p.Temperature) * f
+			p.Bias += (sample.Bias - p.Bias) * f
+			continue
+		}
+		n = 1
+		sample.Time = start
+		points = append(points, sample)
+	}
+	return points
+}
+
+// fetchTransceivers reads the optics inventory and DOM values from the
+// switch
+func (s *Server) fetchTransceivers(ctx context.Context, sw *Switch) ([]Transceiver, error) {
+	type thresholds struct {
+		LowAlarm    float64 `json:"lowAlarm"`
+		LowWarning  float64 `json:"lowWarning"`
+		HighWarning float64 `json:"highWarning"`
+		HighAlarm   float64 `json:"highAlarm"`
+	}
+	var entries []struct {
+		PortName     string `json:"portName"`
+		Type         string `json:"type"`
+		VendorName   string `json:"vendorName"`
+		PartNumber   string `json:"partNumber"`
+		SerialNumber string `json:"serialNumber"`
+		Wavelength   int    `json:"wavelength"`
+		DDM          *struct {
+			TxPower     float64 `json:"txPower"`
+			RxPower     float64 `json:"rxPower"`
+			Temperature float64 `json:"temperature"`
+			Bias        float64 `json:"bias"`
+		} `json:"ddm"`
+		Thresholds map[string]*thresholds `json:"thresholds"`
+	}
+
+	if err := s.getSwitchState(ctx, sw, "/v0/state/transceivers", &entries); err != nil {
+		return nil, err
+	}
+
+	// The switch names thresholds like its DOM fields
+	metricOf := map[string]string{
+		"txPower":     MetricTxPower,
+		"rxPower":     MetricRxPower,
+		"temperature": MetricTemperature,
+		"bias":        MetricBias,
+	}
+
+	now := time.Now()
+	transceivers := make([]Transceiver, 0, len(entries))
+	for _, e := range entries {
+		t := Transceiver{
+			Port:         e.PortName,
+			Type:         e.Type,
+			Vendor:       strings.TrimSpace(e.VendorName),
+			PartNumber:   strings.TrimSpace(e.PartNumber),
+			SerialNumber: strings.TrimSpace(e.SerialNumber),
+			Wavelength:   e.Wavelength,
+			CollectedAt:  now,
+		}
+		if e.DDM != nil {
+			t.DOM = &DOMReading{TxPower: e.DDM.TxPower, RxPower: e.DDM.RxPower, Temperature: e.DDM.Temperature, Bias: e.DDM.Bias}
+		}
+		for name, th := range e.Thresholds {
+			metric, ok := metricOf[name]
+			if !ok || th == nil {
+				continue
+			}
+			reported := DOMThresholds{LowAlarm: th.LowAlarm, LowWarning: th.LowWarning, HighWarning: th.HighWarning, HighAlarm: th.HighAlarm}
+			if reported.validate() != nil {
+				continue
+			}
+			if t.Thresholds == nil {
+				t.Thresholds = make(map[string]DOMThresholds)
+			}
+			t.Thresholds[metric] = reported
+		}
+		transceivers = append(transceivers, t)
+	}
+	sort.Slice(transceivers, func(i, j int) bool { return portLess(transceivers[i].Port, transceivers[j].Port) })
+	return transceivers, nil
+}
+
+// collectTransceivers polls the optics of sw, evaluates their thresholds
+// and stores the readings. An optic whose status changes raises an event,
+// and a swapped optic is audited like a swapped card.
+func (s *Server) collectTransceivers(ctx context.Context, sw *Switch) error {
+	transceivers, err := s.fetchTransceivers(ctx, sw)
+	if err != nil {
+		return err
+	}
+
+	s.mu.RLock()
+	disabled := make(map[string]bool, len(sw.Ports))
+	for _, p := range sw.Ports {
+		disabled[p.Name] = p.Status == "disabled"
+	}
+	s.mu.RUnlock()
+	for i := range transceivers {
+		s.transceivers.evaluate(&transceivers[i], disabled[transceivers[i].Port])
+	}
+	s.transceivers.record(sw.ID, transceivers)
+
+	s.mu.Lock()
+	previous := sw.Transceivers
+	sw.Transceivers = transceivers
+	s.mu.Unlock()
+
+	logger := logging.FromContext(ctx)
+	before := make(map[string]Transceiver, len(previous))
+	for _, t := range previous {
+		before[t.Port] = t
+	}
+	var changes []gin.H
+	for _, t := range transceivers {
+		prev, known := before[t.Port]
+		delete(before, t.Port)
+
+		if known && prev.SerialNumber != t.SerialNumber {
+			changes = append(changes, gin.H{"port": t.Port, "removed": prev.SerialNumber, "added": t.SerialNumber})
+		} else if !known && previous != nil {
+			changes = append(changes, gin.H{"port": t.Port, "added": t.SerialNumber})
+		}
+
+		previousStatus := DOMOK
+		if known {
+			previousStatus = prev.Status
+		}
+		if t.Status == previousStatus {
+			continue
+		}
+		if t.Status == DOMOK {
+			logger.Info("transceiver recovered", "port", t.Port)
+		} else {
+			logger.Warn("transceiver out of range", "port", t.Port, "status", t.Status, "alerts", len(t.Alerts))
+		}
+		s.events.Publish(EventTransceiverAlert, sw.ID, gin.H{
+			"port":            t.Port,
+			"serial_number":   t.SerialNumber,
+			"status":          t.Status,
+			"previous_status": previousStatus,
+			"alerts":          t.Alerts,
+		})
+	}
+	for port, t := range before {
+		changes = append(changes, gin.H{"port": port, "removed": t.SerialNumber})
+	}
+
+	if len(changes) > 0 {
+		s.auditLog.record(ctx, AuditEntry{
+			User:     "system",
+			Action:   "transceiver.change",
+			SwitchID: sw.ID,
+			Result:   "success",
+			Details:  gin.H{"transceivers": changes},
+		})
+	}
+	return nil
+}
+
+// collectTransceiversEndpoint reads the optics of one switch now instead of
+// at the next scheduled poll
+func (s *Server) collectTransceiversEndpoint(c *gin.Context) {
+	sw, ok := s.lookupSwitch(c)
+	if !ok {
+		return
+	}
+
+	ctx := switchContext(c.Request.Context(), sw, "transceivers")
+	if err := s.ensureAuthenticated(ctx, sw); err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Switch authentication failed: " + err.Error()})
+		return
+	}
+	if err := s.collectTransceivers(ctx, sw); err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to collect transceivers: " + err.Error()})
+		return
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	c.JSON(http.StatusOK, gin.H{"switch_id": sw.ID, "transceivers": sw.Transceivers})
+}
+
+// getTransceivers returns the optics of a switch with their latest DOM
+// values and health
+func (s *Server) getTransceivers(c *gin.Context) {
+	sw, ok := s.lookupSwitch(c)
+	if !ok {
+		return
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if sw.Transceivers == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Transceivers not collected yet"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"switch_id": sw.ID, "transceivers": sw.Transceivers})
+}
+
+// getTransceiverDOM serves the DOM series of the optic in one port, with
+// the same ?from, ?to and ?step as the port traffic statistics
+func (s *Server) getTransceiverDOM(c *gin.Context) {
+	sw, ok := s.lookupSwitch(c)
+	if !ok {
+		return
+	}
+	port := c.Param("port")
+
+	to := time.Now()
+	if v := c.Query("to"); v != "" {
+		t, err := parseStatsTime(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to, use RFC 3339 or Unix seconds"})
+			return
+		}
+		to = t
+	}
+	from := to.Add(-24 * time.Hour)
+	if v := c.Query("from"); v != "" {
+		t, err := parseStatsTime(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from, use RFC 3339 or Unix seconds"})
+			return
+		}
+		from = t
+	}
+	if !from.Before(to) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must be before to"})
+		return
+	}
+	step := domInterval
+	if v := c.Query("step"); v != "" {
+		d, err := parseStatsStep(v)
+		if err != nil || d < time.Second {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid step, use a duration of at least 1s"})
+			return
+		}
+		step = d
+	}
+	if to.Sub(from)/step > maxStatsPoints {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Range too large for step, at most %d points", maxStatsPoints)})
+		return
+	}
+
+	s.mu.RLock()
+	var current *Transceiver
+	for i := range sw.Transceivers {
+		if sw.Transceivers[i].Port == port {
+			t := sw.Transceivers[i]
+			current = &t
+			break
+		}
+	}
+	s.mu.RUnlock()
+
+	points := s.transceivers.query(sw.ID, port, from, to, step)
+	if current == nil && len(points) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No transceiver on this port"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"switch_id":   sw.ID,
+		"port":        port,
+		"transceiver": current,
+		"from":        from,
+		"to":          to,
+		"step":        int(step.Seconds()),
+		"points":      points,
+	})
+}
+
+// TransceiverMatch is an optic found by the fleet-wide listing
+type TransceiverMatch struct {
+	SwitchID    int         `json:"switch_id"`
+	SwitchName  string      `json:"switch_name"`
+	Site        string      `json:"site,omitempty"`
+	Transceiver Transceiver `json:"transceiver"`
+}
+
+// listTransceivers lists the optics of every switch. ?status keeps those
+// in that health state; ?serial, ?part_number and ?vendor match
+// case-insensitive substrings.
+func (s *Server) listTransceivers(c *gin.Context) {
+	status := c.Query("status")
+	if status != "" && status != DOMOK && status != DOMWarning && status != DOMAlarm {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be ok, warning or alarm"})
+		return
+	}
+	filters := []struct {
+		value string
+		field func(Transceiver) string
+	}{
+		{c.Query("serial"), func(t Transceiver) string { return t.SerialNumber }},
+		{c.Query("part_number"), func(t Transceiver) string { return t.PartNumber }},
+		{c.Query("vendor"), func(t Transceiver) string { return t.Vendor }},
+	}
+
+	s.mu.RLock()
+	matches := []TransceiverMatch{}
+	for _, sw := range s.switches {
+	transceivers:
+		for _, t := range sw.Transceivers {
+			if status != "" && t.Status != status {
+				continue
+			}
+			for _, f := range filters {
+				if f.value != "" && !strings.Contains(strings.ToLower(f.field(t)), strings.ToLower(f.value)) {
+					continue transceivers
+				}
+			}
+			matches = append(matches, TransceiverMatch{SwitchID: sw.ID, SwitchName: sw.Name, Site: sw.Site, Transceiver: t})
+		}
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].SwitchID != matches[j].SwitchID {
+			return matches[i].SwitchID < matches[j].SwitchID
+		}
+		return portLess(matches[i].Transceiver.Port, matches[j].Transceiver.Port)
+	})
+	c.JSON(http.StatusOK, gin.H{"transceivers": matches})
+}
+
+// getDOMThresholds returns the thresholds configured in OEM and the
+// defaults used where neither OEM nor the module sets any
+func (s *Server) getDOMThresholds(c *gin.Context) {
+	s.transceivers.mu.RLock()
+	defer s.transceivers.mu.RUnlock()
+	c.JSON(http.StatusOK, gin.H{"thresholds": s.transceivers.thresholds, "defaults": defaultDOMThresholds})
+}
+
+// setDOMThresholds replaces the thresholds configured in OEM. Metrics left
+// out fall back to the module's own thresholds. They apply from the next
+// DOM poll.
+func (s *Server) setDOMThresholds(c *gin.Context) {
+	var req map[string]DOMThresholds
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req == nil {
+		req = make(map[string]DOMThresholds)
+	}
+	for metric, th := range req {
+		if _, ok := defaultDOMThresholds[metric]; !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Unknown metric %q, use one of %s", metric, strings.Join(domMetrics, ", "))})
+			return
+		}
+		if err := th.validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": metric + ": " + err.Error()})
+			return
+		}
+	}
+
+	s.transceivers.mu.Lock()
+	s.transceivers.thresholds = req
+	s.transceivers.mu.Unlock()
+
+	s.audit(c, "transceiver.thresholds", 0, "success", gin.H{"thresholds": req})
+	c.JSON(http.StatusOK, gin.H{"thresholds": req})
+}
@@ -0,0 +1,767 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/JarvisTchibClawBot/OpenExtremeManagement/internal/logging"
+	"github.com/gin-gonic/gin"
+)
+
+// Upgrade states
+const (
+	UpgradeStaging     = "staging"
+	UpgradeStaged      = "staged"
+	UpgradeActivating  = "activating"
+	UpgradeCompleted   = "completed"
+	UpgradeHalted      = "halted"
+	UpgradeCancelled   = "cancelled"
+	UpgradeRollingBack = "rolling_back"
+	UpgradeRolledBack  = "rolled_back"
+)
+
+// Per-switch upgrade states
+const (
+	UpgradeSwitchPending    = "pending"
+	UpgradeSwitchStaging    = "staging"
+	UpgradeSwitchStaged     = "staged"
+	UpgradeSwitchRebooting  = "rebooting"
+	UpgradeSwitchUpgraded   = "upgraded"
+	UpgradeSwitchFailed     = "failed"
+	UpgradeSwitchSkipped    = "skipped"
+	UpgradeSwitchRolledBack = "rolled_back"
+)
+
+const (
+	defaultUpgradeTimeout = 15 * time.Minute
+	maxUpgradeTimeout     = time.Hour
+	upgradePollInterval   = 5 * time.Second
+)
+
+// UpgradeSwitch tracks one switch through staging, activation and any
+// rollback
+type UpgradeSwitch struct {
+	SwitchID        int        `json:"switch_id"`
+	SwitchName      string     `json:"switch_name"`
+	PreviousVersion string     `json:"previous_version"`
+	Wave            int        `json:"wave,omitempty"` // 1-based, 0 until staged
+	Status          string     `json:"status"`
+	Error           string     `json:"error,omitempty"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+	UpgradedAt      *time.Time `json:"upgraded_at,omitempty"`
+}
+
+// Upgrade moves a set of switches to the firmware of one image. All
+// switches are staged first; activation then reboots them WaveSize at a
+// time and stops at the first wave with a failure.
+type Upgrade struct {
+	ID            int              `json:"id"`
+	ImageID       int              `json:"image_id"`
+	Version       string           `json:"version"`
+	Status        string           `json:"status"`
+	WaveSize      int              `json:"wave_size"`
+	Concurrency   int              `json:"concurrency"`
+	VerifyTimeout int              `json:"verify_timeout_seconds"`
+	AutoActivate  bool             `json:"auto_activate"`
+	CurrentWave   int              `json:"current_wave,omitempty"`
+	Error         string           `json:"error,omitempty"`
+	Switches      []*UpgradeSwitch `json:"switches"`
+
+	CreatedBy   string     `json:"created_by"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ActivatedBy string     `json:"activated_by,omitempty"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+
+	cancel context.CancelFunc
+}
+
+// running reports whether a background phase owns the upgrade
+func (u *Upgrade) running() bool {
+	return u.Status == UpgradeStaging || u.Status == UpgradeActivating || u.Status == UpgradeRollingBack
+}
+
+func (u *Upgrade) verifyTimeout() time.Duration {
+	timeout := time.Duration(u.VerifyTimeout) * time.Second
+	if timeout <= 0 {
+		return defaultUpgradeTimeout
+	}
+	if timeout > maxUpgradeTimeout {
+		return maxUpgradeTimeout
+	}
+	return timeout
+}
+
+// UpgradeStore keeps upgrades in memory
+type UpgradeStore struct {
+	mu       sync.RWMutex
+	upgrades map[int]*Upgrade
+	nextID   int
+}
+
+func NewUpgradeStore() *UpgradeStore {
+	return &UpgradeStore{upgrades: make(map[int]*Upgrade), nextID: 1}
+}
+
+// snapshot returns a copy that is safe to serialize while the upgrade runs
+func (us *UpgradeStore) snapshot(u *Upgrade) Upgrade {
+	us.mu.RLock()
+	defer us.mu.RUnlock()
+	copied := *u
+	copied.Switches = make([]*UpgradeSwitch, len(u.Switches))
+	for i, sw := range u.Switches {
+		swCopy := *sw
+		copied.Switches[i] = &swCopy
+	}
+	return copied
+}
+
+// imageInUse reports whether an unfinished upgrade uses the image. Caller
+// holds us.mu.
+func (us *UpgradeStore) imageInUse(imageID int) bool {
+	for _, u := range us.upgrades {
+		if u.ImageID == imageID && (u.running() || u.Status == UpgradeStaged) {
+			return true
+		}
+	}
+	return false
+}
+
+// busySwitches returns the switches of unfinished upgrades. Caller holds
+// us.mu.
+func (us *UpgradeStore) busySwitches() map[int]int {
+	busy := make(map[int]int)
+	for _, u := range us.upgrades {
+		if !u.running() && u.Status != UpgradeStaged {
+			continue
+		}
+		for _, sw := range u.Switches {
+			if sw.Status != UpgradeSwitchSkipped {
+				busy[sw.SwitchID] = u.ID
+			}
+		}
+	}
+	return busy
+}
+
+// setUpgradeSwitchStatus records progress of one switch and publishes it
+func (s *Server) setUpgradeSwitchStatus(u *Upgrade, us *UpgradeSwitch, status string, err error) {
+	s.upgrades.mu.Lock()
+	us.Status = status
+	us.UpdatedAt = time.Now()
+	us.Error = ""
+	if err != nil {
+		us.Error = err.Error()
+	}
+	if status == UpgradeSwitchUpgraded {
+		now := us.UpdatedAt
+		us.UpgradedAt = &now
+	}
+	s.upgrades.mu.Unlock()
+
+	s.events.Publish(EventUpgradeProgress, us.SwitchID, gin.H{"upgrade_id": u.ID, "switch_status": status})
+}
+
+// finishUpgrade sets the final state of a background phase
+func (s *Server) finishUpgrade(ctx context.Context, u *Upgrade, status, errMsg string) {
+	s.upgrades.mu.Lock()
+	u.Status = status
+	u.Error = errMsg
+	if status != UpgradeStaged {
+		now := time.Now()
+		u.FinishedAt = &now
+	}
+	s.upgrades.mu.Unlock()
+
+	outcome := "success"
+	if status == UpgradeHalted {
+		outcome = "failure"
+	}
+	s.auditLog.record(ctx, AuditEntry{
+		User:    "system",
+		Action:  "firmware.upgrade.finish",
+		Result:  outcome,
+		Details: gin.H{"upgrade_id": u.ID, "status": status, "error": errMsg},
+	})
+	s.events.Publish(EventUpgradeProgress, 0, gin.H{"upgrade_id": u.ID, "status": status})
+	logging.FromContext(ctx).Info("upgrade phase finished", "status", status)
+}
+
+// upgradeCommands are the CLI commands an upgrade may send, for the
+// policy check. The download URL is left out as it carries the token.
+func upgradeCommands(img *FirmwareImage) []string {
+	return []string{
+		"copy <image-url> /intflash/" + img.FileName,
+		"software add " + img.FileName,
+		"software activate " + img.Version,
+		"reset -y",
+		"software commit",
+	}
+}
+
+type UpgradeRequest struct {
+	ImageID              int            `json:"image_id" binding:"required"`
+	Switches             SwitchSelector `json:"switches"`
+	WaveSize             int            `json:"wave_size"`   // Switches rebooted at a time, default 1
+	Concurrency          int            `json:"concurrency"` // Switches staged at a time
+	VerifyTimeoutSeconds int            `json:"verify_timeout_seconds"`
+	AutoActivate         bool           `json:"auto_activate"` // Start the waves once every switch is staged
+}
+
+// createUpgrade checks the selected switches and starts staging the image
+// on those not already running its version
+func (s *Server) createUpgrade(c *gin.Context) {
+	var req UpgradeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	img, ok := s.images.get(req.ImageID)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Image not found"})
+		return
+	}
+
+	role := c.GetString("role")
+	for _, cmd := range upgradeCommands(img) {
+		if err := s.cliPolicy.Check(role, cmd); err != nil {
+			s.audit(c, "firmware.upgrade", 0, "denied", gin.H{"image_id": img.ID, "reason": logging.Scrub(err.Error())})
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	switches, err := s.selectSwitches(req.Switches)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(switches) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No switches match the selector"})
+		return
+	}
+
+	u := &Upgrade{
+		ImageID:       img.ID,
+		Version:       img.Version,
+		Status:        UpgradeStaging,
+		WaveSize:      req.WaveSize,
+		Concurrency:   jobConcurrency(req.Concurrency),
+		VerifyTimeout: req.VerifyTimeoutSeconds,
+		AutoActivate:  req.AutoActivate,
+		CreatedBy:     c.GetString("username"),
+		CreatedAt:     time.Now(),
+	}
+	if u.WaveSize <= 0 {
+		u.WaveSize = 1
+	}
+
+	var targets []*Switch
+	s.mu.RLock()
+	for _, sw := range switches {
+		if sw.SystemInfo == nil || sw.SystemInfo.FirmwareVersion == "" {
+			s.mu.RUnlock()
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Switch %s has not been synced yet", sw.Name)})
+			return
+		}
+		if img.Family != "" && !strings.HasPrefix(strings.ToLower(sw.SystemInfo.ModelName), strings.ToLower(img.Family)) {
+			s.mu.RUnlock()
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Image is for %s, switch %s is a %s", img.Family, sw.Name, sw.SystemInfo.ModelName)})
+			return
+		}
+
+		us := &UpgradeSwitch{
+			SwitchID:        sw.ID,
+			SwitchName:      sw.Name,
+			PreviousVersion: sw.SystemInfo.FirmwareVersion,
+			Status:          UpgradeSwitchPending,
+			UpdatedAt:       u.CreatedAt,
+		}
+		if sw.SystemInfo.FirmwareVersion == img.Version {
+			us.Status = UpgradeSwitchSkipped
+			us.Error = "already running " + img.Version
+		} else {
+			targets = append(targets, sw)
+		}
+		u.Switches = append(u.Switches, us)
+	}
+	s.mu.RUnlock()
+
+	if len(targets) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "All selected switches already run " + img.Version})
+		return
+	}
+
+	// The checks and the insert share one critical section, so concurrent
+	// requests can't claim the same switch and deleteImage, which holds
+	// the same lock, can't remove the image in between. The upgrade can
+	// be cancelled as soon as it is listed.
+	ctx, cancel := context.WithCancel(detach(c))
+	s.upgrades.mu.Lock()
+	if _, ok := s.images.get(img.ID); !ok {
+		s.upgrades.mu.Unlock()
+		cancel()
+		c.JSON(http.StatusConflict, gin.H{"error": "Image was deleted"})
+		return
+	}
+	busy := s.upgrades.busySwitches()
+	for _, us := range u.Switches {
+		if id, ok := busy[us.SwitchID]; ok {
+			s.upgrades.mu.Unlock()
+			cancel()
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Switch %s is part of upgrade %d", us.SwitchName, id)})
+			return
+		}
+	}
+	u.ID = s.upgrades.nextID
+	s.upgrades.nextID++
+	u.cancel = cancel
+	s.upgrades.upgrades[u.ID] = u
+	s.upgrades.mu.Unlock()
+
+	go s.stageUpgrade(logging.With(ctx, "upgrade_id", u.ID), u, img, s.publicBaseURL(c))
+
+	ids := make([]int, len(targets))
+	for i, sw := range targets {
+		ids[i] = sw.ID
+	}
+	s.audit(c, "firmware.upgrade", 0, "success", gin.H{"upgrade_id": u.ID, "image_id": img.ID, "version": img.Version, "switch_ids": ids})
+	c.JSON(http.StatusAccepted, gin.H{"upgrade": s.upgrades.snapshot(u)})
+}
+
+// upgradeTargets returns the upgrade's switches in the given state with
+// their current switch records
+func (s *Server) upgradeTargets(u *Upgrade, status string) ([]*UpgradeSwitch, []*Switch) {
+	s.upgrades.mu.RLock()
+	var entries []*UpgradeSwitch
+	for _, us := range u.Switches {
+		if us.Status == status {
+			entries = append(entries, us)
+		}
+	}
+	s.upgrades.mu.RUnlock()
+
+	switches := make([]*Switch, len(entries))
+	s.mu.RLock()
+	for i, us := range entries {
+		switches[i] = s.switches[us.SwitchID]
+	}
+	s.mu.RUnlock()
+	return entries, switches
+}
+
+// stageUpgrade copies the image to every pending switch and adds it to
+// the switch's software list, at most u.Concurrency switches at a time
+func (s *Server) stageUpgrade(ctx context.Context, u *Upgrade, img *FirmwareImage, baseURL string) {
+	logger := logging.FromContext(ctx)
+	entries, switches := s.upgradeTargets(u, UpgradeSwitchPending)
+	logger.Info("staging firmware", "version", img.Version, "switches", len(entries))
+
+	commands := []string{
+		fmt.Sprintf("copy %s /intflash/%s", img.downloadURL(baseURL), img.FileName),
+		"software add " + img.FileName,
+	}
+
+	sem := make(chan struct{}, u.Concurrency)
+	var wg sync.WaitGroup
+	for i, us := range entries {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func(us *UpgradeSwitch, sw *Switch) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			if sw == nil {
+				s.setUpgradeSwitchStatus(u, us, UpgradeSwitchFailed, fmt.Errorf("switch no longer exists"))
+				return
+			}
+			s.setUpgradeSwitchStatus(u, us, UpgradeSwitchStaging, nil)
+
+			swCtx := switchContext(context.WithoutCancel(ctx), sw, "firmware_stage")
+			err := s.ensureAuthenticated(swCtx, sw)
+			if err == nil {
+				var execution *CLICommandExecution
+				execution, err = s.runCLI(swCtx, sw, commands)
+				if err == nil {
+					err = firstFailedCommand(execution)
+				}
+			}
+			if err != nil {
+				// The command carries the download token
+				err = fmt.Errorf("staging failed: %s", logging.Scrub(err.Error()))
+				logger.Warn("staging failed", "switch_id", sw.ID, "error", err)
+				s.setUpgradeSwitchStatus(u, us, UpgradeSwitchFailed, err)
+				return
+			}
+			s.setUpgradeSwitchStatus(u, us, UpgradeSwitchStaged, nil)
+		}(us, switches[i])
+	}
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		s.finishUpgrade(ctx, u, UpgradeCancelled, "cancelled during staging")
+		return
+	}
+
+	// Waves are fixed once staging is done, in switch order
+	staged, _ := s.upgradeTargets(u, UpgradeSwitchStaged)
+	failed := len(entries) - len(staged)
+	s.upgrades.mu.Lock()
+	for i, us := range staged {
+		us.Wave = i/u.WaveSize + 1
+	}
+	s.upgrades.mu.Unlock()
+
+	if failed > 0 {
+		s.finishUpgrade(ctx, u, UpgradeHalted, fmt.Sprintf("staging failed on %d switch(es)", failed))
+		return
+	}
+	if !u.AutoActivate {
+		s.finishUpgrade(ctx, u, UpgradeStaged, "")
+		return
+	}
+
+	// Go straight to activating so there is no staged window in which the
+	// upgrade could be cancelled or activated a second time
+	s.upgrades.mu.Lock()
+	if u.Status != UpgradeStaging {
+		s.upgrades.mu.Unlock()
+		return
+	}
+	u.Status = UpgradeActivating
+	u.ActivatedBy = u.CreatedBy
+	s.upgrades.mu.Unlock()
+
+	s.events.Publish(EventUpgradeProgress, 0, gin.H{"upgrade_id": u.ID, "status": UpgradeActivating})
+	logger.Info("staging finished, activating")
+	s.activateUpgrade(ctx, u)
+}
+
+// activateUpgrade reboots the staged switches wave by wave into the new
+// version. A wave with a failure halts the rollout; cancelling stops it
+// before the next wave.
+func (s *Server) activateUpgrade(ctx context.Context, u *Upgrade) {
+	logger := logging.FromContext(ctx)
+
+	entries, switches := s.upgradeTargets(u, UpgradeSwitchStaged)
+	waves := make(map[int][]int)
+	var order []int
+	for i, us := range entries {
+		if _, ok := waves[us.Wave]; !ok {
+			order = append(order, us.Wave)
+		}
+		waves[us.Wave] = append(waves[us.Wave], i)
+	}
+	sort.Ints(order)
+
+	for _, wave := range order {
+		if ctx.Err() != nil {
+			s.finishUpgrade(ctx, u, UpgradeCancelled, fmt.Sprintf("cancelled before wave %d", wave))
+			return
+		}
+
+		s.upgrades.mu.Lock()
+		u.CurrentWave = wave
+		s.upgrades.mu.Unlock()
+		logger.Info("upgrade wave started", "wave", wave, "switches", len(waves[wave]))
+
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		failed := 0
+		for _, i := range waves[wave] {
+			wg.Add(1)
+			go func(us *UpgradeSwitch, sw *Switch) {
+				defer wg.Done()
+				if err := s.rebootInto(ctx, u, us, sw, u.Version, UpgradeSwitchUpgraded); err != nil {
+					mu.Lock()
+					failed++
+					mu.Unlock()
+				}
+			}(entries[i], switches[i])
+		}
+		wg.Wait()
+
+		if failed > 0 {
+			s.finishUpgrade(ctx, u, UpgradeHalted, fmt.Sprintf("%d switch(es) failed in wave %d", failed, wave))
+			return
+		}
+	}
+
+	s.finishUpgrade(ctx, u, UpgradeCompleted, "")
+}
+
+// rebootInto activates version on sw, reboots it and waits for it to come
+// back running that version, which is then committed. Without a commit
+// the switch falls back to its previous version on the next reboot.
+func (s *Server) rebootInto(ctx context.Context, u *Upgrade, us *UpgradeSwitch, sw *Switch, version, doneStatus string) error {
+	fail := func(err error) error {
+		s.setUpgradeSwitchStatus(u, us, UpgradeSwitchFailed, err)
+		return err
+	}
+	if sw == nil {
+		return fail(fmt.Errorf("switch no longer exists"))
+	}
+
+	// A reboot in progress is never abandoned, even if the upgrade is
+	// cancelled
+	ctx = switchContext(context.WithoutCancel(ctx), sw, "firmware_activate")
+	logger := logging.FromContext(ctx)
+
+	if err := s.ensureAuthenticated(ctx, sw); err != nil {
+		return fail(fmt.Errorf("authentication failed: %w", err))
+	}
+
+	s.setUpgradeSwitchStatus(u, us, UpgradeSwitchRebooting, nil)
+	resetAt := time.Now()
+	execution, err := s.runCLI(ctx, sw, []string{"software activate " + version, "reset -y"})
+	if err == nil {
+		if err := firstFailedCommand(execution); err != nil {
+			return fail(err)
+		}
+	} else {
+		// The switch may drop the connection as it reboots
+		logger.Warn("no reply to reset, waiting for the switch anyway", "error", err)
+	}
+
+	info, err := s.waitForReboot(ctx, sw, resetAt, u.verifyTimeout())
+	if err != nil {
+		return fail(err)
+	}
+	if info.FirmwareVersion != version {
+		return fail(fmt.Errorf("switch came back running %s instead of %s", info.FirmwareVersion, version))
+	}
+
+	execution, err = s.runCLI(ctx, sw, []string{"software commit"})
+	if err == nil {
+		err = firstFailedCommand(execution)
+	}
+	if err != nil {
+		return fail(fmt.Errorf("running %s but commit failed: %w", version, err))
+	}
+
+	logger.Info("switch running new firmware", "version", version)
+	s.setUpgradeSwitchStatus(u, us, doneStatus, nil)
+	s.syncSwitch(ctx, sw)
+	return nil
+}
+
+// waitForReboot polls sw until it answers with an uptime showing it
+// restarted after resetAt
+func (s *Server) waitForReboot(ctx context.Context, sw *Switch, resetAt time.Time, timeout time.Duration) (*SystemInfo, error) {
+	deadline := resetAt.Add(timeout)
+	lastErr := fmt.Errorf("switch did not reboot")
+	for time.Now().Before(deadline) {
+		select {
+		case <-time.After(upgradePollInterval):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+
+		// The reboot invalidates the session
+		s.mu.Lock()
+		sw.AuthToken = ""
+		s.mu.Unlock()
+		if err := s.ensureAuthenticated(ctx, sw); err != nil {
+			lastErr = err
+			continue
+		}
+		info, err := s.fetchSystemInfo(ctx, sw)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if time.Duration(info.SysUpTime)*time.Second > time.Since(resetAt)+upgradePollInterval {
+			lastErr = fmt.Errorf("switch did not reboot")
+			continue
+		}
+		return info, nil
+	}
+	return nil, fmt.Errorf("switch not back after %s: %v", timeout, lastErr)
+}
+
+func (s *Server) listUpgrades(c *gin.Context) {
+	s.upgrades.mu.RLock()
+	upgrades := make([]*Upgrade, 0, len(s.upgrades.upgrades))
+	for _, u := range s.upgrades.upgrades {
+		upgrades = append(upgrades, u)
+	}
+	s.upgrades.mu.RUnlock()
+
+	sort.Slice(upgrades, func(i, j int) bool { return upgrades[i].ID > upgrades[j].ID })
+
+	snapshots := make([]Upgrade, len(upgrades))
+	for i, u := range upgrades {
+		snapshots[i] = s.upgrades.snapshot(u)
+	}
+	c.JSON(http.StatusOK, gin.H{"upgrades": snapshots})
+}
+
+func (s *Server) lookupUpgrade(c *gin.Context) (*Upgrade, bool) {
+	var id int
+	if _, err := fmt.Sscanf(c.Param("id"), "%d", &id); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid upgrade ID"})
+		return nil, false
+	}
+
+	s.upgrades.mu.RLock()
+	u, exists := s.upgrades.upgrades[id]
+	s.upgrades.mu.RUnlock()
+
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Upgrade not found"})
+		return nil, false
+	}
+	return u, true
+}
+
+func (s *Server) getUpgrade(c *gin.Context) {
+	u, ok := s.lookupUpgrade(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"upgrade": s.upgrades.snapshot(u)})
+}
+
+// activateUpgradeEndpoint starts the waves of a staged upgrade
+func (s *Server) activateUpgradeEndpoint(c *gin.Context) {
+	u, ok := s.lookupUpgrade(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(detach(c))
+	s.upgrades.mu.Lock()
+	if u.Status != UpgradeStaged {
+		cancel()
+		status := u.Status
+		s.upgrades.mu.Unlock()
+		c.JSON(http.StatusConflict, gin.H{"error": "Upgrade is " + status + ", only staged upgrades can be activated"})
+		return
+	}
+	u.Status = UpgradeActivating
+	u.ActivatedBy = c.GetString("username")
+	u.cancel = cancel
+	s.upgrades.mu.Unlock()
+
+	go s.activateUpgrade(logging.With(ctx, "upgrade_id", u.ID), u)
+
+	s.audit(c, "firmware.upgrade.activate", 0, "success", gin.H{"upgrade_id": u.ID})
+	c.JSON(http.StatusAccepted, gin.H{"upgrade": s.upgrades.snapshot(u)})
+}
+
+// rollbackUpgrade reboots the switches the upgrade moved to the new
+// version back into their previous version, in the same waves
+func (s *Server) rollbackUpgrade(c *gin.Context) {
+	u, ok := s.lookupUpgrade(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(detach(c))
+	s.upgrades.mu.Lock()
+	if u.Status != UpgradeCompleted && u.Status != UpgradeHalted && u.Status != UpgradeCancelled {
+		cancel()
+		status := u.Status
+		s.upgrades.mu.Unlock()
+		c.JSON(http.StatusConflict, gin.H{"error": "Upgrade is " + status + ", only finished upgrades can be rolled back"})
+		return
+	}
+	upgraded := 0
+	for _, us := range u.Switches {
+		if us.Status == UpgradeSwitchUpgraded {
+			upgraded++
+		}
+	}
+	if upgraded == 0 {
+		cancel()
+		s.upgrades.mu.Unlock()
+		c.JSON(http.StatusConflict, gin.H{"error": "No switch has been upgraded"})
+		return
+	}
+	u.Status = UpgradeRollingBack
+	u.FinishedAt = nil
+	u.cancel = cancel
+	s.upgrades.mu.Unlock()
+
+	go s.runRollback(logging.With(ctx, "upgrade_id", u.ID), u)
+
+	s.audit(c, "firmware.upgrade.rollback", 0, "success", gin.H{"upgrade_id": u.ID, "switches": upgraded})
+	c.JSON(http.StatusAccepted, gin.H{"upgrade": s.upgrades.snapshot(u)})
+}
+
+func (s *Server) runRollback(ctx context.Context, u *Upgrade) {
+	entries, switches := s.upgradeTargets(u, UpgradeSwitchUpgraded)
+	for start := 0; start < len(entries); start += u.WaveSize {
+		if ctx.Err() != nil {
+			s.finishUpgrade(ctx, u, UpgradeHalted, "rollback cancelled")
+			return
+		}
+		end := start + u.WaveSize
+		if end > len(entries) {
+			end = len(entries)
+		}
+
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		failed := 0
+		for i := start; i < end; i++ {
+			wg.Add(1)
+			go func(us *UpgradeSwitch, sw *Switch) {
+				defer wg.Done()
+				if err := s.rebootInto(ctx, u, us, sw, us.PreviousVersion, UpgradeSwitchRolledBack); err != nil {
+					mu.Lock()
+					failed++
+					mu.Unlock()
+				}
+			}(entries[i], switches[i])
+		}
+		wg.Wait()
+
+		if failed > 0 {
+			s.finishUpgrade(ctx, u, UpgradeHalted, fmt.Sprintf("rollback failed on %d switch(es)", failed))
+			return
+		}
+	}
+	s.finishUpgrade(ctx, u, UpgradeRolledBack, "")
+}
+
+func (s *Server) cancelUpgrade(c *gin.Context) {
+	u, ok := s.lookupUpgrade(c)
+	if !ok {
+		return
+	}
+
+	s.upgrades.mu.Lock()
+	status, cancel := u.Status, u.cancel
+	if status == UpgradeStaged {
+		// Nothing runs between staging and activation
+		u.Status = UpgradeCancelled
+		now := time.Now()
+		u.FinishedAt = &now
+	}
+	s.upgrades.mu.Unlock()
+
+	switch {
+	case status == UpgradeStaged:
+	case status == UpgradeStaging || status == UpgradeActivating || status == UpgradeRollingBack:
+		cancel()
+	default:
+		c.JSON(http.StatusConflict, gin.H{"error": "Upgrade is not in progress"})
+		return
+	}
+
+	s.audit(c, "firmware.upgrade.cancel", 0, "success", gin.H{"upgrade_id": u.ID})
+	c.JSON(http.StatusOK, gin.H{"message": "Upgrade cancellation requested; switches already rebooting are finished first"})
+}
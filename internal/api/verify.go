@@ -0,0 +1,368 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/JarvisTchibClawBot/OpenExtremeManagement/internal/logging"
+)
+
+const (
+	defaultVerifyTimeout = 60 * time.Second
+	maxVerifyTimeout     = 10 * time.Minute
+	verifyPollInterval   = 5 * time.Second
+)
+
+// VerifySpec configures the checks run after a configuration push. The
+// switch must always still answer /v0/state/system; values pushed through
+// a system info update must read back, and the named ports and IS-IS
+// adjacencies (by interface or neighbor host name) must be up.
+type VerifySpec struct {
+	TimeoutSeconds int      `json:"timeout_seconds"`
+	Ports          []string `json:"ports,omitempty"`
+	Adjacencies    []string `json:"adjacencies,omitempty"`
+	Rollback       *bool    `json:"rollback,omitempty"` // Restore the pre-change config on failure, default true
+}
+
+func (v *VerifySpec) timeout() time.Duration {
+	timeout := time.Duration(v.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		return defaultVerifyTimeout
+	}
+	if timeout > maxVerifyTimeout {
+		return maxVerifyTimeout
+	}
+	return timeout
+}
+
+func (v *VerifySpec) rollback() bool {
+	return v.Rollback == nil || *v.Rollback
+}
+
+// VerifyCheck is the outcome of one post-change check
+type VerifyCheck struct {
+	Name   string `json:"name"`
+	Passed bool   `json:"passed"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// VerificationResult reports the checks after a push and any rollback
+type VerificationResult struct {
+	Passed           bool          `json:"passed"`
+	Attempts         int           `json:"attempts"`
+	Checks           []VerifyCheck `json:"checks,omitempty"`
+	PreChangeBackup  int           `json:"pre_change_backup_id,omitempty"`
+	RolledBack       bool          `json:"rolled_back"`
+	RollbackCommands []string      `json:"rollback_commands,omitempty"`
+	RollbackError    string        `json:"rollback_error,omitempty"`
+}
+
+// pushWithVerification runs commands on sw. With a spec it first backs up
+// the running config, then polls the checks until they pass or the spec's
+// timeout expires, and on failure restores the backed-up config. The
+// returned error is set if the change did not stick, whether because the
+// push failed or because verification did.
+func (s *Server) pushWithVerification(ctx context.Context, sw *Switch, commands []string, spec *VerifySpec, expected *UpdateSystemInfoRequest) (*CLICommandExecution, *VerificationResult, error) {
+	if spec == nil {
+		execution, err := s.runCLI(ctx, sw, commands)
+		if err == nil {
+			err = firstFailedCommand(execution)
+		}
+		return execution, nil, err
+	}
+
+	logger := logging.FromContext(ctx)
+	result := &VerificationResult{}
+
+	// Without a known-good config there is nothing to roll back to
+	before, err := s.backupConfig(ctx, sw, "pre-change")
+	if err != nil {
+		return nil, nil, fmt.Errorf("pre-change backup failed, change not applied: %w", err)
+	}
+	result.PreChangeBackup = before.ID
+
+	execution, pushErr := s.runCLI(ctx, sw, commands)
+	if pushErr == nil {
+		pushErr = firstFailedCommand(execution)
+	}
+
+	if pushErr == nil {
+		deadline := time.Now().Add(spec.timeout())
+		for {
+			result.Attempts++
+			result.Checks = s.runVerifyChecks(ctx, sw, spec, expected)
+			result.Passed = true
+			for _, check := range result.Checks {
+				if !check.Passed {
+					result.Passed = false
+				}
+			}
+			if result.Passed || time.Now().Add(verifyPollInterval).After(deadline) {
+				break
+			}
+			select {
+			case <-time.After(verifyPollInterval):
+			case <-ctx.Done():
+			}
+			if ctx.Err() != nil {
+				break
+			}
+		}
+		if result.Passed {
+			logger.Info("post-change verification passed", "attempts", result.Attempts)
+			return execution, result, nil
+		}
+		logger.Warn("post-change verification failed", "attempts", result.Attempts, "checks", result.Checks)
+	}
+
+	changeErr := pushErr
+	if changeErr == nil {
+		changeErr = fmt.Errorf("post-change verification failed")
+	}
+	if !spec.rollback() {
+		return execution, result, changeErr
+	}
+
+	// A partially applied push is rolled back too
+	if err := s.restoreConfig(ctx, sw, before, result); err != nil {
+		result.RollbackError = err.Error()
+		logger.Error("rollback failed", "backup_id", before.ID, "error", err)
+		return execution, result, fmt.Errorf("%v; rollback failed: %v", changeErr, err)
+	}
+	logger.Warn("change rolled back", "backup_id", before.ID)
+	return execution, result, fmt.Errorf("%v; pre-change config restored", changeErr)
+}
+
+func firstFailedCommand(execution *CLICommandExecution) error {
+	for _, r := range execution.Commands {
+		if !r.Success {
+			return fmt.Errorf("command %q failed: %s", r.Command, strings.TrimSpace(r.Output))
+		}
+	}
+	return nil
+}
+
+// runVerifyChecks runs every check once
+func (s *Server) runVerifyChecks(ctx context.Context, sw *Switch, spec *VerifySpec, expected *UpdateSystemInfoRequest) []VerifyCheck {
+	var checks []VerifyCheck
+
+	info, err := s.fetchSystemInfo(ctx, sw)
+	reach := VerifyCheck{Name: "reachable", Passed: err == nil}
+	if err != nil {
+		// The token may have been invalidated by the change
+		s.mu.Lock()
+		sw.AuthToken = ""
+		s.mu.Unlock()
+		if authErr := s.ensureAuthenticated(ctx, sw); authErr == nil {
+			info, err = s.fetchSystemInfo(ctx, sw)
+			reach.Passed = err == nil
+		}
+	}
+	if err != nil {
+		reach.Detail = err.Error()
+		// Nothing else can be checked on a switch that doesn't answer
+		return append(checks, reach)
+	}
+	checks = append(checks, reach)
+
+	if expected != nil {
+		for _, field := range []struct{ name, want, have string }{
+			{"sysName", expected.SysName, info.SysName},
+			{"sysLocation", expected.SysLocation, info.SysLocation},
+			{"sysContact", expected.SysContact, info.SysContact},
+		} {
+			if field.want == "" {
+				continue
+			}
+			check := VerifyCheck{Name: "value:" + field.name, Passed: field.want == field.have}
+			if !check.Passed {
+				check.Detail = fmt.Sprintf("expected %q, switch reports %q", field.want, field.have)
+			}
+			checks = append(checks, check)
+		}
+	}
+
+	if len(spec.Ports) > 0 {
+		ports, err := s.fetchPorts(ctx, sw)
+		status := make(map[string]string, len(ports))
+		for _, p := range ports {
+			status[p.Name] = p.Status
+		}
+		for _, name := range spec.Ports {
+			check := VerifyCheck{Name: "port:" + name}
+			switch {
+			case err != nil:
+				check.Detail = err.Error()
+			case status[name] == "up":
+				check.Passed = true
+			case status[name] == "":
+				check.Detail = "port not found"
+			default:
+				check.Detail = "port is " + status[name]
+			}
+			checks = append(checks, check)
+		}
+	}
+
+	if len(spec.Adjacencies) > 0 {
+		up, err := s.isisAdjacenciesUp(ctx, sw)
+		for _, name := range spec.Adjacencies {
+			check := VerifyCheck{Name: "adjacency:" + name, Passed: err == nil && up[name]}
+			if err != nil {
+				check.Detail = err.Error()
+			} else if !check.Passed {
+				check.Detail = "adjacency not up"
+			}
+			checks = append(checks, check)
+		}
+	}
+
+	return checks
+}
+
+// isisAdjacenciesUp returns the interfaces and neighbor host names of the
+// IS-IS adjacencies in state UP
+func (s *Server) isisAdjacenciesUp(ctx context.Context, sw *Switch) (map[string]bool, error) {
+	execution, err := s.runCLI(ctx, sw, []string{"show isis adjacencies"})
+	if err != nil {
+		return nil, err
+	}
+	if len(execution.Commands) != 1 || !execution.Succeeded() {
+		return nil, fmt.Errorf("show isis adjacencies failed")
+	}
+
+	up := make(map[string]bool)
+	for _, line := range strings.Split(execution.Commands[0].Output, "\n") {
+		fields := strings.Fields(line)
+		// INTERFACE L STATE UPTIME... HOST-NAME
+		if len(fields) < 4 || fields[2] != "UP" {
+			continue
+		}
+		up[fields[0]] = true
+		up[fields[len(fields)-1]] = true
+		// Accept "1/25" for "Port1/25"
+		up[strings.TrimPrefix(fields[0], "Port")] = true
+	}
+	return up, nil
+}
+
+// configBlocks splits config lines into top-level lines (key "") and the
+// contents of each section, keyed by its header line
+func configBlocks(lines []string) (map[string][]string, []string) {
+	blocks := make(map[string][]string)
+	var order []string
+	seen := make(map[string]bool)
+	add := func(key, line string) {
+		if !seen[key] {
+			seen[key] = true
+			order = append(order, key)
+		}
+		blocks[key] = append(blocks[key], line)
+	}
+
+	section := ""
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "config terminal" || line == "configure terminal" || line == "end":
+		case line == "exit":
+			section = ""
+		case section == "" && (strings.HasPrefix(line, "interface ") || strings.HasPrefix(line, "router ")):
+			section = line
+			if !seen[section] {
+				seen[section] = true
+				order = append(order, section)
+			}
+		default:
+			add(section, line)
+		}
+	}
+	return blocks, order
+}
+
+// restoreCommands builds the CLI that turns the after config back into
+// the before config: lines added by the change are negated, lines it
+// removed are re-entered, section by section
+func restoreCommands(before, after []string) []string {
+	beforeBlocks, beforeOrder := configBlocks(before)
+	afterBlocks, afterOrder := configBlocks(after)
+
+	keys := append([]string{}, beforeOrder...)
+	for _, key := range afterOrder {
+		if _, ok := beforeBlocks[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+
+	var commands []string
+	for _, key := range keys {
+		missing, extra := diffSets(beforeBlocks[key], afterBlocks[key])
+		if len(missing) == 0 && len(extra) == 0 {
+			continue
+		}
+		if key != "" {
+			commands = append(commands, key)
+		}
+		restored := make(map[string]bool, len(missing))
+		for _, line := range missing {
+			restored[line] = true
+		}
+		for _, line := range extra {
+			negated := "no " + line
+			if strings.HasPrefix(line, "no ") {
+				negated = strings.TrimPrefix(line, "no ")
+			}
+			// Shutdown replaced by no shutdown is undone by one command
+			if !restored[negated] {
+				commands = append(commands, negated)
+			}
+		}
+		commands = append(commands, missing...)
+		if key != "" {
+			commands = append(commands, "exit")
+		}
+	}
+	if len(commands) == 0 {
+		return nil
+	}
+	return append(append([]string{"configure terminal"}, commands...), "exit")
+}
+
+// restoreConfig reverts sw to the backed-up configuration
+func (s *Server) restoreConfig(ctx context.Context, sw *Switch, before *ConfigBackup, result *VerificationResult) error {
+	// The switch may have become unreachable with a stale token
+	s.mu.Lock()
+	sw.AuthToken = ""
+	s.mu.Unlock()
+	if err := s.ensureAuthenticated(ctx, sw); err != nil {
+		return fmt.Errorf("authentication failed: %w", err)
+	}
+
+	current, err := s.backupConfig(ctx, sw, "post-change")
+	if err != nil {
+		return err
+	}
+
+	commands := restoreCommands(before.Lines(), current.Lines())
+	result.RollbackCommands = commands
+	if len(commands) == 0 {
+		result.RolledBack = true
+		return nil
+	}
+
+	execution, err := s.runCLI(ctx, sw, commands)
+	if err != nil {
+		return err
+	}
+	if err := firstFailedCommand(execution); err != nil {
+		return err
+	}
+	result.RolledBack = true
+
+	if _, err := s.backupConfig(ctx, sw, "rollback"); err != nil {
+		logging.FromContext(ctx).Warn("config backup after rollback failed", "error", err)
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package api
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestConfigBlocks(t *testing.T) {
+	lines := []string{
+		"config terminal",
+		"snmp-server name core1",
+		"interface gigabitEthernet 1/1",
+		"  name uplink",
+		"  no shutdown",
+		"exit",
+		"ntp server 10.0.0.1",
+		"interface gigabitEthernet 1/2",
+		"exit",
+		"end",
+	}
+	blocks, order := configBlocks(lines)
+
+	wantOrder := []string{"", "interface gigabitEthernet 1/1", "interface gigabitEthernet 1/2"}
+	if !reflect.DeepEqual(order, wantOrder) {
+		t.Errorf("order = %q, want %q", order, wantOrder)
+	}
+	wantBlocks := map[string][]string{
+		"":                              {"snmp-server name core1", "ntp server 10.0.0.1"},
+		"interface gigabitEthernet 1/1": {"name uplink", "no shutdown"},
+	}
+	if !reflect.DeepEqual(blocks, wantBlocks) {
+		t.Errorf("blocks = %q, want %q", blocks, wantBlocks)
+	}
+}
+
+func TestRestoreCommands(t *testing.T) {
+	tests := []struct {
+		name          string
+		before, after []string
+		want          []string
+	}{
+		{
+			name:   "unchanged",
+			before: []string{"snmp-server name core1"},
+			after:  []string{"snmp-server name core1"},
+			want:   nil,
+		},
+		{
+			name:   "top-level line added",
+			before: []string{"snmp-server name core1"},
+			after:  []string{"snmp-server name core1", "ntp server 10.0.0.9"},
+			want:   []string{"configure terminal", "no ntp server 10.0.0.9", "exit"},
+		},
+		{
+			name:   "top-level line removed",
+			before: []string{"snmp-server name core1", "ntp server 10.0.0.1"},
+			after:  []string{"snmp-server name core1"},
+			want:   []string{"configure terminal", "ntp server 10.0.0.1", "exit"},
+		},
+		{
+			name: "port shut down",
+			before: []string{
+				"interface gigabitEthernet 1/1", "no shutdown", "exit",
+			},
+			after: []string{
+				"interface gigabitEthernet 1/1", "shutdown", "exit",
+			},
+			want: []string{
+				"configure terminal",
+				"interface gigabitEthernet 1/1", "no shutdown", "exit",
+				"exit",
+			},
+		},
+		{
+			name:   "section added by the change",
+			before: []string{"snmp-server name core1"},
+			after: []string{
+				"snmp-server name core1",
+				"interface gigabitEthernet 1/5", "name printer", "exit",
+			},
+			want: []string{
+				"configure terminal",
+				"interface gigabitEthernet 1/5", "no name printer", "exit",
+				"exit",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := restoreCommands(tt.before, tt.after); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("restoreCommands() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
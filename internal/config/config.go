@@ -7,6 +7,20 @@ type Config struct {
 	RedisURL    string
 	JWTSecret   string
 	Environment string
+	TLSCABundle string
+	LogLevel    string
+	LogFormat   string
+
+	CLIPolicyFile string
+
+	FirmwareImageDir string
+	PublicURL        string // Base URL switches use to reach OEM, e.g. http://oem.example.com:9301
+
+	// How long port counter series are kept at each resolution, e.g. 168h
+	StatsRawRetention    string
+	Stats5mRetention     string
+	StatsHourlyRetention string
+	DOMRetention         string // Transceiver DOM readings
 }
 
 func Load() *Config {
@@ -15,6 +29,19 @@ func Load() *Config {
 		RedisURL:    getEnv("REDIS_URL", "redis://localhost:6379"),
 		JWTSecret:   getEnv("JWT_SECRET", "change-me-in-production"),
 		Environment: getEnv("ENVIRONMENT", "development"),
+		TLSCABundle: getEnv("TLS_CA_BUNDLE", ""),
+		LogLevel:    getEnv("LOG_LEVEL", "info"),
+		LogFormat:   getEnv("LOG_FORMAT", "json"),
+
+		CLIPolicyFile: getEnv("CLI_POLICY_FILE", ""),
+
+		FirmwareImageDir: getEnv("FIRMWARE_IMAGE_DIR", "data/images"),
+		PublicURL:        getEnv("PUBLIC_URL", ""),
+
+		StatsRawRetention:    getEnv("STATS_RAW_RETENTION", "24h"),
+		Stats5mRetention:     getEnv("STATS_5M_RETENTION", "168h"),
+		StatsHourlyRetention: getEnv("STATS_HOURLY_RETENTION", "2160h"),
+		DOMRetention:         getEnv("DOM_RETENTION", "720h"),
 	}
 }
 
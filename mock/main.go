@@ -1,10 +1,22 @@
 package main
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
 	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/hex"
+	"fmt"
+	"io"
 	"log"
+	"math/big"
+	"net"
 	"net/http"
+	"os"
+	"regexp"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -12,6 +24,14 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// MOCK_PORT sets the listen port, default 9443. Instances on other ports
+// report their own chassis ID, MACs and serial numbers, so several mocks
+// look like different switches.
+var (
+	mockPort     = 9443
+	mockInstance = 0
+)
+
 // Token storage
 var (
 	tokenStore = make(map[string]time.Time)
@@ -26,8 +46,45 @@ var (
 		SysContact:  "",
 	}
 	systemMu sync.RWMutex
+
+	// Set by configuration commands, cleared by "save config"
+	configDirty = true
+
+	// Other configuration lines, in running-config order. Configuration
+	// commands add a line, "no <line>" removes it.
+	configLines = []string{
+		"boot config flags sshd",
+		"boot config flags telnetd",
+		"ntp server 192.0.2.1",
+		"ip name-server 192.0.2.53",
+		"syslog host 1 address 192.0.2.50",
+		"snmp-server community public ro",
+	}
+)
+
+// Firmware state, also guarded by systemMu. "reset -y" makes the mock
+// unreachable for MOCK_REBOOT_SECONDS and then boots the activated
+// version, unless it is the one named by MOCK_BAD_FIRMWARE, which fails
+// to come up so the switch falls back to the version it ran before.
+var (
+	firmwareVersion = "9.3.0.0"
+	activeVersion   = ""
+	softwareList    = []string{"9.3.0.0"}
+	flashFiles      = map[string]int64{}
+	bootedAt        = time.Now().Add(-915500 * time.Second)
+	rebootCount     = 0
+	rebootUntil     time.Time
+
+	softwareVersionPattern = regexp.MustCompile(`\d+(\.\d+){3,}`)
 )
 
+func rebootDuration() time.Duration {
+	if secs, err := strconv.Atoi(os.Getenv("MOCK_REBOOT_SECONDS")); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second
+	}
+	return 20 * time.Second
+}
+
 // AuthRequest represents the authentication request
 type AuthRequest struct {
 	Username string `json:"username"`
@@ -65,6 +122,8 @@ type SystemState struct {
 	RebootCount          int    `json:"rebootCount"`
 	SysDescription       string `json:"sysDescription"`
 	SysName              string `json:"sysName"`
+	SysLocation          string `json:"sysLocation"`
+	SysContact           string `json:"sysContact"`
 	TelegrafVersion      string `json:"telegrafVersion"`
 }
 
@@ -88,6 +147,44 @@ type Card struct {
 	Vims            []string `json:"vims"`
 }
 
+// PortState represents the operational state of a port
+type PortState struct {
+	PortName    string `json:"portName"`
+	AdminStatus string `json:"adminStatus"`
+	OperStatus  string `json:"operStatus"`
+	Speed       int    `json:"speed"` // Mbps
+	Description string `json:"description"`
+}
+
+// Port state storage, seeded so the port map has a mix of states
+var (
+	portStates = initialPortStates(27)
+	portMu     sync.RWMutex
+)
+
+func initialPortStates(numPorts int) []PortState {
+	ports := make([]PortState, numPorts)
+	for i := range ports {
+		port := PortState{
+			PortName:    fmt.Sprintf("1/%d", i+1),
+			AdminStatus: "UP",
+			OperStatus:  "UP",
+			Speed:       1000,
+		}
+		if i%7 == 0 {
+			port.OperStatus = "DOWN"
+		} else if i%11 == 0 {
+			port.AdminStatus = "DOWN"
+			port.OperStatus = "DOWN"
+		}
+		if i%4 == 0 {
+			port.Speed = 10000
+		}
+		ports[i] = port
+	}
+	return ports
+}
+
 // CLICommandRequest represents CLI commands to execute
 type CLICommandRequest struct {
 	Commands []string `json:"commands"`
@@ -105,10 +202,41 @@ type CLICommandResult struct {
 	Status  string `json:"status"`
 }
 
+// Canned output for common troubleshooting commands
+var showOutputs = map[string]string{
+	"show isis adjacencies": `================================================================================
+                              ISIS Adjacencies
+================================================================================
+INTERFACE        L STATE   UPTIME      PRI HOLDTIME SYSID          HOST-NAME
+--------------------------------------------------------------------------------
+Port1/25         1 UP      10d 14:22:05 127 27       0cfa.b298.1000 5520-core-1
+Port1/26         1 UP      10d 14:21:58 127 25       0cfa.b298.2000 5520-core-2
+--------------------------------------------------------------------------------
+ 2 out of 2 interfaces have formed an adjacency`,
+	"show mac-address-table": `================================================================================
+                              Vlan Fdb
+================================================================================
+VLAN    STATUS  MAC                INTERFACE   TYPE
+ID              ADDRESS
+--------------------------------------------------------------------------------
+10      learned 00:11:22:33:44:55  Port1/2     LOCAL
+10      learned 00:11:22:33:44:66  Port1/3     LOCAL
+20      learned 3c:22:fb:01:02:03  Port1/5     LOCAL
+--------------------------------------------------------------------------------
+3 out of 3 entries in all fdb(s) displayed.`,
+}
+
 func main() {
 	gin.SetMode(gin.ReleaseMode)
 	router := gin.Default()
 
+	if port, err := strconv.Atoi(os.Getenv("MOCK_PORT")); err == nil && port > 0 && port < 65536 {
+		mockPort = port
+		mockInstance = (port - 9443) & 0xff
+	}
+	systemConfig.SysName = mockSysName(mockInstance)
+	addr := fmt.Sprintf(":%d", mockPort)
+
 	// CORS middleware
 	router.Use(func(c *gin.Context) {
 		c.Header("Access-Control-Allow-Origin", "*")
@@ -121,6 +249,18 @@ func main() {
 		c.Next()
 	})
 
+	// A rebooting switch doesn't answer
+	router.Use(func(c *gin.Context) {
+		systemMu.RLock()
+		rebooting := time.Now().Before(rebootUntil)
+		systemMu.RUnlock()
+		if rebooting {
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "Switch is rebooting"})
+			return
+		}
+		c.Next()
+	})
+
 	// Auth endpoint
 	router.POST("/rest/openapi/auth/token", handleAuth)
 
@@ -129,19 +269,85 @@ func main() {
 	protected.Use(authMiddleware())
 	{
 		protected.GET("/v0/state/system", getSystemState)
+		protected.GET("/v0/state/ports", getPortStates)
+		protected.GET("/v0/state/ports/statistics", getPortStatistics)
+		protected.GET("/v0/state/transceivers", getTransceivers)
+		protected.GET("/v0/state/poe", getPoEState)
+		protected.GET("/v0/state/environment", getEnvironment)
+		protected.GET("/v0/state/lldp/neighbors", getLLDPNeighbors)
+		protected.GET("/v0/state/spbm", getSPBMState)
+		protected.GET("/v0/state/isis/adjacencies", getISISAdjacencies)
+		protected.GET("/v0/state/isids", getISIDs)
+		protected.GET("/v0/state/fdb", getFDB)
+		protected.GET("/v0/state/arp", getARP)
 		protected.POST("/v0/operation/system/cli", executeCLICommands)
 	}
 
-	log.Printf("🔌 Extreme Networks Fabric Engine Mock - Port 9443")
+	log.Printf("🔌 Extreme Networks Fabric Engine Mock - Port %d", mockPort)
 	log.Printf("🔗 POST /rest/openapi/auth/token")
 	log.Printf("🔗 GET  /rest/openapi/v0/state/system (requires X-Auth-Token)")
+	log.Printf("🔗 GET  /rest/openapi/v0/state/ports (requires X-Auth-Token)")
+	log.Printf("🔗 GET  /rest/openapi/v0/state/ports/statistics (requires X-Auth-Token)")
+	log.Printf("🔗 GET  /rest/openapi/v0/state/transceivers (requires X-Auth-Token)")
+	log.Printf("🔗 GET  /rest/openapi/v0/state/poe (requires X-Auth-Token)")
+	log.Printf("🔗 GET  /rest/openapi/v0/state/environment (requires X-Auth-Token)")
+	log.Printf("🔗 GET  /rest/openapi/v0/state/lldp/neighbors (requires X-Auth-Token)")
+	log.Printf("🔗 GET  /rest/openapi/v0/state/{spbm,isis/adjacencies,isids} (requires X-Auth-Token)")
+	log.Printf("🔗 GET  /rest/openapi/v0/state/{fdb,arp} (requires X-Auth-Token)")
 	log.Printf("🔗 POST /rest/openapi/v0/operation/system/cli (requires X-Auth-Token)")
 
-	if err := router.Run(":9443"); err != nil {
+	// Real switches serve a self-signed certificate; MOCK_TLS=true mimics
+	// that so certificate pinning can be exercised
+	if os.Getenv("MOCK_TLS") == "true" {
+		cert, err := selfSignedCertificate()
+		if err != nil {
+			log.Fatalf("Failed to generate certificate: %v", err)
+		}
+		srv := &http.Server{
+			Addr:      addr,
+			Handler:   router,
+			TLSConfig: &tls.Config{Certificates: []tls.Certificate{cert}},
+		}
+		log.Printf("🔒 Serving HTTPS with self-signed certificate")
+		if err := srv.ListenAndServeTLS("", ""); err != nil {
+			log.Fatalf("Failed to start mock server: %v", err)
+		}
+		return
+	}
+
+	if err := router.Run(addr); err != nil {
 		log.Fatalf("Failed to start mock server: %v", err)
 	}
 }
 
+// selfSignedCertificate generates a fresh certificate on every start, so a
+// restarted mock looks like a switch whose certificate was regenerated
+func selfSignedCertificate() (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	serial, _ := rand.Int(rand.Reader, big.NewInt(1<<62))
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: "5520-24T-FabricEngine", Organization: []string{"Extreme Networks"}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().AddDate(1, 0, 0),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		DNSNames:     []string{"localhost", "mock-switch"},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
+
 func generateToken() string {
 	bytes := make([]byte, 32)
 	rand.Read(bytes)
@@ -155,6 +361,16 @@ func handleAuth(c *gin.Context) {
 		return
 	}
 
+	// Any login is accepted unless MOCK_USERNAME or MOCK_PASSWORD is set
+	if user := os.Getenv("MOCK_USERNAME"); user != "" && req.Username != user {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		return
+	}
+	if password := os.Getenv("MOCK_PASSWORD"); password != "" && req.Password != password {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		return
+	}
+
 	ttl := req.TTL
 	if ttl <= 0 {
 		ttl = 3600
@@ -202,50 +418,813 @@ func authMiddleware() gin.HandlerFunc {
 
 func getSystemState(c *gin.Context) {
 	systemMu.RLock()
-	sysName := systemConfig.SysName
+	config := systemConfig
+	dirty := configDirty
+	version := firmwareVersion
+	upTime := int(time.Since(bootedAt).Seconds())
+	reboots := rebootCount
 	systemMu.RUnlock()
 
 	state := SystemState{
-		BootConfigType: "FACTORY_DEFAULT",
-		Cards: []Card{
-			{
-				AdminStatus:     "UP",
-				BaseMacAddress:  "0c:fa:b2:98:00:00",
-				BrandName:       "Extreme Networks.",
-				FirmwareVersion: "9.3.0.0",
-				HardwareRev:     "1",
-				IsPowerEnabled:  true,
-				MacAddrCapacity: 1024,
-				ModelName:       "5520-24T-FabricEngine",
-				NumPorts:        27,
-				OperationStatus: "UP",
-				PartNumber:      "DSGDPM624",
-				SerialNumber:    "SIMB298-0000",
-				SlotNumber:      1,
-				SysBuildTime:    "Tue/Sep/9/14:14:20/EDT/2025",
-				SysUpTime:       915500,
-				Vims:            []string{},
-			},
-		},
-		ChassisId:            "0cfab2980000",
+		BootConfigType:       "FACTORY_DEFAULT",
+		Cards:                stackCards(version, upTime),
+		ChassisId:            fmt.Sprintf("0cfab298%02x00", mockInstance),
 		ChassisIdSubtype:     "MAC_ADDRESS",
 		InletsVersion:        "N/A",
 		IqAgentVersion:       "0.9.22",
-		IsConfigDirty:        true,
+		IsConfigDirty:        dirty,
 		IsDigitalTwin:        true,
 		NosType:              "FABRIC_ENGINE",
 		NumSlots:             2,
 		OpenApiAppVersion:    "0.1.10.10",
 		OpenApiSchemaVersion: "0.2.0",
-		RebootCount:          0,
-		SysDescription:       "5520-24T-FabricEngine (9.3.0.0)",
-		SysName:              sysName,
+		RebootCount:          reboots,
+		SysDescription:       "5520-24T-FabricEngine (" + version + ")",
+		SysName:              config.SysName,
+		SysLocation:          config.SysLocation,
+		SysContact:           config.SysContact,
 		TelegrafVersion:      "1.21.4-58592c4a",
 	}
 
 	c.JSON(http.StatusOK, state)
 }
 
+// stackCards returns one card per stack unit; MOCK_STACK_SIZE sets the
+// number of units, default 1
+func stackCards(version string, upTime int) []Card {
+	units, err := strconv.Atoi(os.Getenv("MOCK_STACK_SIZE"))
+	if err != nil || units < 1 {
+		units = 1
+	}
+	cards := make([]Card, units)
+	for i := range cards {
+		cards[i] = Card{
+			AdminStatus:     "UP",
+			BaseMacAddress:  fmt.Sprintf("0c:fa:b2:98:%02x:%02x", i*16, mockInstance),
+			BrandName:       "Extreme Networks.",
+			FirmwareVersion: version,
+			HardwareRev:     "1",
+			IsPowerEnabled:  true,
+			MacAddrCapacity: 1024,
+			ModelName:       "5520-24T-FabricEngine",
+			NumPorts:        27,
+			OperationStatus: "UP",
+			PartNumber:      "DSGDPM624",
+			SerialNumber:    fmt.Sprintf("SIMB298-%02d%02d", mockInstance, i),
+			SlotNumber:      i + 1,
+			SysBuildTime:    "Tue/Sep/9/14:14:20/EDT/2025",
+			SysUpTime:       upTime,
+			Vims:            []string{},
+		}
+	}
+	return cards
+}
+
+// runningConfig renders the configuration; callers hold systemMu
+func runningConfig() string {
+	var b strings.Builder
+	b.WriteString("#\n# Running configuration of " + systemConfig.SysName + "\n#\n")
+	b.WriteString("config terminal\n")
+	b.WriteString("hostname " + systemConfig.SysName + "\n")
+	if systemConfig.SysLocation != "" {
+		b.WriteString("snmp-server location " + systemConfig.SysLocation + "\n")
+	}
+	if systemConfig.SysContact != "" {
+		b.WriteString("snmp-server contact " + systemConfig.SysContact + "\n")
+	}
+	for _, line := range configLines {
+		b.WriteString(line + "\n")
+	}
+
+	// Only ports that differ from the defaults appear, as on a real switch
+	portMu.RLock()
+	for _, p := range portStates {
+		if p.AdminStatus != "DOWN" && p.Description == "" {
+			continue
+		}
+		b.WriteString("interface gigabitEthernet " + p.PortName + "\n")
+		if p.AdminStatus == "DOWN" {
+			b.WriteString("shutdown\n")
+		}
+		if p.Description != "" {
+			b.WriteString(fmt.Sprintf("name %q\n", p.Description))
+		}
+		b.WriteString("exit\n")
+	}
+	portMu.RUnlock()
+
+	b.WriteString("end\n")
+	return b.String()
+}
+
+// addConfigLine records a configuration command; callers hold systemMu
+func addConfigLine(line string) {
+	for _, l := range configLines {
+		if l == line {
+			return
+		}
+	}
+	configLines = append(configLines, line)
+}
+
+// removeConfigLine undoes a configuration command, which may name only
+// the start of the line (e.g. "no syslog host 1"); callers hold systemMu
+func removeConfigLine(line string) {
+	kept := configLines[:0]
+	for _, l := range configLines {
+		if l != line && !strings.HasPrefix(l, line+" ") {
+			kept = append(kept, l)
+		}
+	}
+	configLines = kept
+}
+
+// copyToFlash handles "copy <url> /intflash/<file>" by downloading the
+// file; callers hold systemMu
+func copyToFlash(cmd string) (string, string) {
+	fields := strings.Fields(cmd)
+	if len(fields) != 3 || !strings.HasPrefix(fields[2], "/intflash/") {
+		return "% Usage: copy <url> /intflash/<file>", "FAILURE"
+	}
+	src := strings.Trim(fields[1], "\"")
+	name := strings.TrimPrefix(fields[2], "/intflash/")
+
+	client := &http.Client{Timeout: 10 * time.Minute}
+	resp, err := client.Get(src)
+	if err != nil {
+		return "% Copy failed: " + err.Error(), "FAILURE"
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Sprintf("%% Copy failed: server returned %d", resp.StatusCode), "FAILURE"
+	}
+	size, err := io.Copy(io.Discard, resp.Body)
+	if err != nil {
+		return "% Copy failed: " + err.Error(), "FAILURE"
+	}
+
+	flashFiles[name] = size
+	log.Printf("💾 Mock: Copied %s to flash (%d bytes)", name, size)
+	return fmt.Sprintf("%d bytes copied to /intflash/%s", size, name), "SUCCESS"
+}
+
+// softwareCommand handles software add/activate/commit and show software;
+// callers hold systemMu
+func softwareCommand(cmd string) (string, string) {
+	fields := strings.Fields(cmd)
+	switch {
+	case cmd == "show software":
+		var b strings.Builder
+		for _, v := range softwareList {
+			state := ""
+			if v == firmwareVersion {
+				state = " (Primary Release)"
+			} else if v == activeVersion {
+				state = " (Activated, pending reset)"
+			}
+			b.WriteString(v + state + "\n")
+		}
+		return b.String(), "SUCCESS"
+	case len(fields) == 3 && fields[1] == "add":
+		if _, ok := flashFiles[fields[2]]; !ok {
+			return "% File /intflash/" + fields[2] + " not found", "FAILURE"
+		}
+		// Image names are <model>.<version>.tgz, e.g. 5520.9.3.1.0.tgz
+		parts := strings.Split(softwareVersionPattern.FindString(fields[2]), ".")
+		if len(parts) < 4 {
+			return "% Not a software image: " + fields[2], "FAILURE"
+		}
+		version := strings.Join(parts[len(parts)-4:], ".")
+		for _, v := range softwareList {
+			if v == version {
+				return "Software " + version + " already added", "SUCCESS"
+			}
+		}
+		softwareList = append(softwareList, version)
+		return "Software " + version + " added", "SUCCESS"
+	case len(fields) == 3 && fields[1] == "activate":
+		for _, v := range softwareList {
+			if v == fields[2] {
+				activeVersion = v
+				return "Software " + v + " activated, reset to boot it", "SUCCESS"
+			}
+		}
+		return "% Software " + fields[2] + " not added", "FAILURE"
+	case cmd == "software commit":
+		return "Software " + firmwareVersion + " committed", "SUCCESS"
+	}
+	return "% Invalid software command", "FAILURE"
+}
+
+// setPortState applies update to the named port and reports whether the
+// port exists; a nil update only checks
+func setPortState(name string, update func(*PortState)) bool {
+	portMu.Lock()
+	defer portMu.Unlock()
+	for i := range portStates {
+		if portStates[i].PortName == name {
+			if update != nil {
+				update(&portStates[i])
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// LLDPNeighbor is a neighbor learned on a port
+type LLDPNeighbor struct {
+	LocalPort               string `json:"localPort"`
+	RemoteChassisId         string `json:"remoteChassisId"`
+	RemoteChassisIdSubtype  string `json:"remoteChassisIdSubtype"`
+	RemotePortId            string `json:"remotePortId"`
+	RemotePortDescription   string `json:"remotePortDescription"`
+	RemoteSysName           string `json:"remoteSysName"`
+	RemoteSysDescription    string `json:"remoteSysDescription"`
+	RemoteManagementAddress string `json:"remoteManagementAddress"`
+}
+
+// lldpNeighbors returns an unmanaged server on 1/24 plus the mocks listed
+// in MOCK_LLDP, e.g. "1/2>1:1/2,1/3>2:1/5" wires local 1/2 to port 1/2 of
+// the mock on 9443+1. Neighbors on ports that are not up age out, as on a
+// real switch.
+func lldpNeighbors() []LLDPNeighbor {
+	neighbors := []LLDPNeighbor{{
+		LocalPort:               "1/24",
+		RemoteChassisId:         fmt.Sprintf("00:50:56:%02x:00:01", mockInstance),
+		RemoteChassisIdSubtype:  "MAC_ADDRESS",
+		RemotePortId:            "vmnic0",
+		RemotePortDescription:   "vmnic0",
+		RemoteSysName:           fmt.Sprintf("esx-%02d", mockInstance+1),
+		RemoteSysDescription:    "VMware ESX Releasebuild-8.0.2",
+		RemoteManagementAddress: fmt.Sprintf("192.0.2.%d", 100+mockInstance),
+	}}
+
+	for _, link := range mockLinks() {
+		neighbors = append(neighbors, LLDPNeighbor{
+			LocalPort:               link.localPort,
+			RemoteChassisId:         fmt.Sprintf("0cfab298%02x00", link.instance),
+			RemoteChassisIdSubtype:  "MAC_ADDRESS",
+			RemotePortId:            link.remotePort,
+			RemotePortDescription:   "Port " + link.remotePort,
+			RemoteSysName:           mockSysName(link.instance),
+			RemoteSysDescription:    "5520-24T-FabricEngine (9.3.0.0)",
+			RemoteManagementAddress: "127.0.0.1",
+		})
+	}
+
+	active := []LLDPNeighbor{}
+	for _, n := range neighbors {
+		if portUp(n.LocalPort) {
+			active = append(active, n)
+		}
+	}
+	return active
+}
+
+// mockLink is a cable from a local port to a port of another mock
+type mockLink struct {
+	localPort  string
+	instance   int
+	remotePort string
+}
+
+// mockLinks parses MOCK_LLDP
+func mockLinks() []mockLink {
+	var links []mockLink
+	for _, entry := range strings.Split(os.Getenv("MOCK_LLDP"), ",") {
+		local, remote, ok := strings.Cut(strings.TrimSpace(entry), ">")
+		if !ok {
+			continue
+		}
+		instance, remotePort, ok := strings.Cut(remote, ":")
+		n, err := strconv.Atoi(instance)
+		if !ok || err != nil {
+			log.Printf("⚠️  Mock: ignoring MOCK_LLDP entry %q", entry)
+			continue
+		}
+		links = append(links, mockLink{localPort: local, instance: n, remotePort: remotePort})
+	}
+	return links
+}
+
+// mockSysName is the default system name of the mock instance n
+func mockSysName(n int) string {
+	if n == 0 {
+		return "5520-24T-FabricEngine"
+	}
+	return fmt.Sprintf("5520-24T-FabricEngine-%d", n)
+}
+
+func portUp(name string) bool {
+	portMu.RLock()
+	defer portMu.RUnlock()
+	for _, p := range portStates {
+		if p.PortName == name {
+			return p.OperStatus == "UP"
+		}
+	}
+	return false
+}
+
+func getLLDPNeighbors(c *gin.Context) {
+	c.JSON(http.StatusOK, lldpNeighbors())
+}
+
+// SPBM nodal parameters of the mock instance n
+func spbmNickname(n int) string { return fmt.Sprintf("0.00.%02x", n+1) }
+func spbmSystemId(n int) string { return fmt.Sprintf("0cfa.b298.%02x01", n) }
+
+// ISISAdjacency is an IS-IS adjacency formed over a fabric link
+type ISISAdjacency struct {
+	Interface        string `json:"interface"`
+	NeighborSystemId string `json:"neighborSystemId"`
+	NeighborHostName string `json:"neighborHostName"`
+	State            string `json:"state"`
+	Level            string `json:"level"`
+	HoldTime         int    `json:"holdTime"`
+}
+
+// ISIDAssignment maps a service instance to a customer VLAN
+type ISIDAssignment struct {
+	Isid  int    `json:"isid"`
+	Type  string `json:"type"` // ELAN or ELAN_TRANSPARENT
+	Cvlan int    `json:"cvlan,omitempty"`
+	Name  string `json:"name"`
+}
+
+func getSPBMState(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"spbmInstance": 1,
+		"nickname":     spbmNickname(mockInstance),
+		"systemId":     spbmSystemId(mockInstance),
+		"bvlans":       []int{4051, 4052},
+		"ipShortcuts":  false,
+	})
+}
+
+// getISISAdjacencies reports an adjacency on every MOCK_LLDP link whose
+// port is up, except ports listed in MOCK_NO_ISIS, which lack the fabric
+// configuration
+func getISISAdjacencies(c *gin.Context) {
+	noISIS := make(map[string]bool)
+	for _, port := range strings.Split(os.Getenv("MOCK_NO_ISIS"), ",") {
+		noISIS[strings.TrimSpace(port)] = true
+	}
+
+	adjacencies := []ISISAdjacency{}
+	for _, link := range mockLinks() {
+		if noISIS[link.localPort] || !portUp(link.localPort) {
+			continue
+		}
+		adjacencies = append(adjacencies, ISISAdjacency{
+			Interface:        "Port" + link.localPort,
+			NeighborSystemId: spbmSystemId(link.instance),
+			NeighborHostName: mockSysName(link.instance),
+			State:            "UP",
+			Level:            "1",
+			HoldTime:         27,
+		})
+	}
+	c.JSON(http.StatusOK, adjacencies)
+}
+
+// getISIDs returns the I-SIDs of MOCK_ISIDS, given as isid:vlan pairs,
+// e.g. "20010:10,20020:20"; a VLAN of 0 makes a transparent service
+func getISIDs(c *gin.Context) {
+	spec := os.Getenv("MOCK_ISIDS")
+	if spec == "" {
+		spec = "20010:10,20020:20"
+	}
+	isids := []ISIDAssignment{}
+	for _, entry := range strings.Split(spec, ",") {
+		isidStr, vlanStr, _ := strings.Cut(strings.TrimSpace(entry), ":")
+		isid, err := strconv.Atoi(isidStr)
+		if err != nil {
+			continue
+		}
+		vlan, _ := strconv.Atoi(vlanStr)
+		a := ISIDAssignment{Isid: isid, Type: "ELAN", Cvlan: vlan, Name: fmt.Sprintf("ISID-%d", isid)}
+		if vlan == 0 {
+			a.Type = "ELAN_TRANSPARENT"
+		}
+		isids = append(isids, a)
+	}
+	c.JSON(http.StatusOK, isids)
+}
+
+// FDBEntry is a MAC address learned on a port
+type FDBEntry struct {
+	MacAddress string `json:"macAddress"`
+	VlanId     int    `json:"vlanId"`
+	PortName   string `json:"portName"`
+	Type       string `json:"type"` // LEARNED or SELF
+}
+
+// ARPEntry maps an IP address to a MAC address on a VLAN interface
+type ARPEntry struct {
+	IpAddress  string `json:"ipAddress"`
+	MacAddress string `json:"macAddress"`
+	VlanId     int    `json:"vlanId"`
+	PortName   string `json:"portName"`
+}
+
+// mockEndpoints returns the hosts attached to this mock: one per access
+// port 1/3-1/10 not used by a MOCK_LLDP link, alternating VLANs 10 and 20,
+// the host on port 1/10 of the mock behind every link, and a laptop that
+// moves to the next of ports 1/3-1/6 every MOCK_ROAM_SECONDS when set
+func mockEndpoints() []ARPEntry {
+	links := mockLinks()
+	uplinks := make(map[string]bool, len(links))
+	for _, link := range links {
+		uplinks[link.localPort] = true
+	}
+
+	var hosts []ARPEntry
+	for i := 3; i <= 10; i++ {
+		if uplinks[fmt.Sprintf("1/%d", i)] {
+			continue
+		}
+		vlan := 10 + 10*(i%2)
+		hosts = append(hosts, ARPEntry{
+			IpAddress:  fmt.Sprintf("10.%d.%d.%d", mockInstance, vlan, i),
+			MacAddress: fmt.Sprintf("00:1b:21:%02x:00:%02x", mockInstance, i),
+			VlanId:     vlan,
+			PortName:   fmt.Sprintf("1/%d", i),
+		})
+	}
+	for _, link := range links {
+		hosts = append(hosts, ARPEntry{
+			IpAddress:  fmt.Sprintf("10.%d.10.10", link.instance),
+			MacAddress: fmt.Sprintf("00:1b:21:%02x:00:0a", link.instance),
+			VlanId:     10,
+			PortName:   link.localPort,
+		})
+	}
+	if secs, err := strconv.Atoi(os.Getenv("MOCK_ROAM_SECONDS")); err == nil && secs > 0 {
+		step := int(time.Now().Unix()/int64(secs)) % 4
+		hosts = append(hosts, ARPEntry{
+			IpAddress:  fmt.Sprintf("10.%d.10.200", mockInstance),
+			MacAddress: fmt.Sprintf("00:1b:21:%02x:ff:01", mockInstance),
+			VlanId:     10,
+			PortName:   fmt.Sprintf("1/%d", 3+step),
+		})
+	}
+
+	// Hosts behind a port that is down are not learned
+	active := hosts[:0]
+	for _, h := range hosts {
+		if portUp(h.PortName) {
+			active = append(active, h)
+		}
+	}
+	return active
+}
+
+func getFDB(c *gin.Context) {
+	fdb := []FDBEntry{}
+	for _, h := range mockEndpoints() {
+		fdb = append(fdb, FDBEntry{MacAddress: h.MacAddress, VlanId: h.VlanId, PortName: h.PortName, Type: "LEARNED"})
+	}
+	c.JSON(http.StatusOK, fdb)
+}
+
+func getARP(c *gin.Context) {
+	c.JSON(http.StatusOK, append([]ARPEntry{}, mockEndpoints()...))
+}
+
+func getPortStates(c *gin.Context) {
+	portMu.RLock()
+	ports := make([]PortState, len(portStates))
+	copy(ports, portStates)
+	portMu.RUnlock()
+
+	c.JSON(http.StatusOK, ports)
+}
+
+// PortStatistics holds the traffic counters of a port
+type PortStatistics struct {
+	PortName    string `json:"portName"`
+	InOctets    uint64 `json:"inOctets"`
+	OutOctets   uint64 `json:"outOctets"`
+	InPkts      uint64 `json:"inPkts"`
+	OutPkts     uint64 `json:"outPkts"`
+	InErrors    uint64 `json:"inErrors"`
+	OutErrors   uint64 `json:"outErrors"`
+	InDiscards  uint64 `json:"inDiscards"`
+	OutDiscards uint64 `json:"outDiscards"`
+	CRCErrors   uint64 `json:"crcErrors"`
+}
+
+// Traffic counters, guarded by portMu. They advance while a port is up,
+// port 1/n carrying n MB/s in and half that out, and count from zero
+// after a reboot. Port 1/7 has a bad cable and logs CRC errors.
+// MOCK_COUNTER32=true reports them as 32-bit counters, which wrap.
+var (
+	portCounters   = map[string]*[9]float64{}
+	countersAt     time.Time
+	countersBootAt time.Time
+)
+
+func advanceCounters() {
+	systemMu.RLock()
+	boot := bootedAt
+	systemMu.RUnlock()
+
+	now := time.Now()
+	if !boot.Equal(countersBootAt) {
+		portCounters = map[string]*[9]float64{}
+		countersBootAt = boot
+		countersAt = boot
+	}
+	elapsed := now.Sub(countersAt).Seconds()
+	countersAt = now
+
+	for i, p := range portStates {
+		counters, ok := portCounters[p.PortName]
+		if !ok {
+			counters = &[9]float64{}
+			portCounters[p.PortName] = counters
+		}
+		if p.OperStatus != "UP" {
+			continue
+		}
+		in := float64(i+1) * 1e6 * elapsed
+		out := in / 2
+		counters[0] += in
+		counters[1] += out
+		counters[2] += in / 500
+		counters[3] += out / 500
+		counters[6] += elapsed / 60
+		counters[7] += elapsed / 120
+		if p.PortName == "1/7" {
+			counters[4] += 2 * elapsed
+			counters[8] += 2 * elapsed
+		}
+	}
+}
+
+func getPortStatistics(c *gin.Context) {
+	portMu.Lock()
+	advanceCounters()
+	stats := make([]PortStatistics, 0, len(portStates))
+	for _, p := range portStates {
+		v := portCounters[p.PortName]
+		counter := func(i int) uint64 {
+			if os.Getenv("MOCK_COUNTER32") == "true" {
+				return uint64(v[i]) % (1 << 32)
+			}
+			return uint64(v[i])
+		}
+		stats = append(stats, PortStatistics{
+			PortName:    p.PortName,
+			InOctets:    counter(0),
+			OutOctets:   counter(1),
+			InPkts:      counter(2),
+			OutPkts:     counter(3),
+			InErrors:    counter(4),
+			OutErrors:   counter(5),
+			InDiscards:  counter(6),
+			OutDiscards: counter(7),
+			CRCErrors:   counter(8),
+		})
+	}
+	portMu.Unlock()
+
+	c.JSON(http.StatusOK, stats)
+}
+
+// TransceiverDDM holds the digital diagnostics of an optic
+type TransceiverDDM struct {
+	TxPower     float64 `json:"txPower"`     // dBm
+	RxPower     float64 `json:"rxPower"`     // dBm
+	Temperature float64 `json:"temperature"` // °C
+	Bias        float64 `json:"bias"`        // mA
+}
+
+// DDMThresholds are the alarm and warning limits an optic reports
+type DDMThresholds struct {
+	LowAlarm    float64 `json:"lowAlarm"`
+	LowWarning  float64 `json:"lowWarning"`
+	HighWarning float64 `json:"highWarning"`
+	HighAlarm   float64 `json:"highAlarm"`
+}
+
+// TransceiverState is the optic plugged into a port
+type TransceiverState struct {
+	PortName     string                   `json:"portName"`
+	Type         string                   `json:"type"`
+	VendorName   string                   `json:"vendorName"`
+	PartNumber   string                   `json:"partNumber"`
+	SerialNumber string                   `json:"serialNumber"`
+	Wavelength   int                      `json:"wavelength"`
+	DDM          *TransceiverDDM          `json:"ddm"`
+	Thresholds   map[string]DDMThresholds `json:"thresholds,omitempty"`
+}
+
+var startedAt = time.Now()
+
+// getTransceivers reports a DAC on 1/1, LR and SR optics on 1/25 and 1/26
+// and a QSFP28 without thresholds on 1/27. The SR optic's receive power
+// falls by MOCK_DOM_DRIFT dB per minute, like a dirty connector getting
+// worse. A port that is down receives nothing; a disabled one also has
+// its laser off.
+func getTransceivers(c *gin.Context) {
+	drift, _ := strconv.ParseFloat(os.Getenv("MOCK_DOM_DRIFT"), 64)
+	sfpThresholds := map[string]DDMThresholds{
+		"txPower":     {LowAlarm: -8.2, LowWarning: -7.3, HighWarning: 0.5, HighAlarm: 1.5},
+		"rxPower":     {LowAlarm: -16.4, LowWarning: -14.4, HighWarning: 0.5, HighAlarm: 1.5},
+		"temperature": {LowAlarm: -5, LowWarning: 0, HighWarning: 70, HighAlarm: 75},
+		"bias":        {LowAlarm: 2, LowWarning: 2.5, HighWarning: 10.5, HighAlarm: 11.8},
+	}
+	serial := func(port int) string { return fmt.Sprintf("OPT%02d%05d", mockInstance, port) }
+
+	optics := []TransceiverState{
+		{PortName: "1/1", Type: "SFP+", VendorName: "EXTREME", PartNumber: "10GB-C01-SFPP", SerialNumber: serial(1)},
+		{PortName: "1/25", Type: "SFP+", VendorName: "EXTREME", PartNumber: "10302", SerialNumber: serial(25), Wavelength: 1310,
+			DDM: &TransceiverDDM{TxPower: -2.1, RxPower: -3.4, Temperature: 34.5, Bias: 6.2}, Thresholds: sfpThresholds},
+		{PortName: "1/26", Type: "SFP+", VendorName: "EXTREME", PartNumber: "10301", SerialNumber: serial(26), Wavelength: 850,
+			DDM: &TransceiverDDM{TxPower: -2.6, RxPower: -4.0 - drift*time.Since(startedAt).Minutes(), Temperature: 38.1, Bias: 7.4}, Thresholds: sfpThresholds},
+		{PortName: "1/27", Type: "QSFP28", VendorName: "EXTREME", PartNumber: "10403", SerialNumber: serial(27), Wavelength: 850,
+			DDM: &TransceiverDDM{TxPower: -0.8, RxPower: -1.5, Temperature: 41.0, Bias: 7.9}},
+	}
+
+	portMu.RLock()
+	for _, p := range portStates {
+		for i := range optics {
+			if optics[i].PortName != p.PortName || optics[i].DDM == nil {
+				continue
+			}
+			if p.AdminStatus != "UP" {
+				optics[i].DDM.TxPower, optics[i].DDM.Bias = -40, 0
+			}
+			if p.OperStatus != "UP" {
+				optics[i].DDM.RxPower = -40
+			}
+		}
+	}
+	portMu.RUnlock()
+
+	c.JSON(http.StatusOK, optics)
+}
+
+// PoEPortState is the PoE state of one port
+type PoEPortState struct {
+	PortName        string  `json:"portName"`
+	AdminStatus     string  `json:"adminStatus"`     // ENABLED, DISABLED
+	DetectionStatus string  `json:"detectionStatus"` // DELIVERING_POWER, SEARCHING, DISABLED, FAULT
+	PowerClass      int     `json:"powerClass"`
+	Priority        string  `json:"priority"` // LOW, HIGH, CRITICAL
+	PowerDraw       float64 `json:"powerDraw"`
+}
+
+// PoE settings of ports 1/1-1/24, guarded by portMu. APs are attached to
+// 1/3 and 1/5, phones to 1/4 and 1/6, a camera to 1/8, and the device on
+// 1/9 is faulty.
+var (
+	poeShutdown = map[string]bool{}
+	poePriority = map[string]string{}
+	poeDevices  = map[string]struct {
+		class int
+		watts float64
+	}{
+		"1/3": {4, 18.2},
+		"1/4": {2, 4.1},
+		"1/5": {4, 17.6},
+		"1/6": {2, 3.9},
+		"1/8": {3, 9.8},
+		"1/9": {-1, 0},
+	}
+)
+
+const poeBudget = 370.0
+
+func getPoEState(c *gin.Context) {
+	portMu.RLock()
+	defer portMu.RUnlock()
+
+	ports := []PoEPortState{}
+	consumption := 0.0
+	for _, p := range portStates[:24] {
+		state := PoEPortState{PortName: p.PortName, AdminStatus: "ENABLED", DetectionStatus: "SEARCHING", PowerClass: -1, Priority: "LOW"}
+		if priority, ok := poePriority[p.PortName]; ok {
+			state.Priority = strings.ToUpper(priority)
+		}
+		device, attached := poeDevices[p.PortName]
+		switch {
+		case poeShutdown[p.PortName]:
+			state.AdminStatus, state.DetectionStatus = "DISABLED", "DISABLED"
+		case !attached || p.OperStatus != "UP":
+		case device.class < 0:
+			state.DetectionStatus = "FAULT"
+		default:
+			state.DetectionStatus = "DELIVERING_POWER"
+			state.PowerClass = device.class
+			state.PowerDraw = device.watts
+			consumption += device.watts
+		}
+		ports = append(ports, state)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"powerBudget": poeBudget, "powerConsumption": consumption, "ports": ports})
+}
+
+// poeCommand applies a PoE setting to the interface being configured
+func poeCommand(port, cmd string) (string, string) {
+	portMu.Lock()
+	defer portMu.Unlock()
+
+	if i, err := strconv.Atoi(strings.TrimPrefix(port, "1/")); err != nil || i < 1 || i > 24 {
+		return "% PoE is not supported on port " + port, "FAILURE"
+	}
+	switch {
+	case cmd == "poe poe-shutdown":
+		poeShutdown[port] = true
+	case cmd == "no poe poe-shutdown":
+		delete(poeShutdown, port)
+	case strings.HasPrefix(cmd, "poe poe-priority "):
+		priority := strings.TrimPrefix(cmd, "poe poe-priority ")
+		if priority != "low" && priority != "high" && priority != "critical" {
+			return "% Invalid priority " + priority, "FAILURE"
+		}
+		poePriority[port] = priority
+	default:
+		return "% Invalid input detected", "FAILURE"
+	}
+	return "OK", "SUCCESS"
+}
+
+// FanState is a fan of a unit
+type FanState struct {
+	SlotNumber int    `json:"slotNumber"`
+	FanId      int    `json:"fanId"`
+	OperStatus string `json:"operStatus"` // UP, DOWN
+	SpeedRpm   int    `json:"speedRpm"`
+}
+
+// PowerSupplyState is a power supply bay of a unit
+type PowerSupplyState struct {
+	SlotNumber int    `json:"slotNumber"`
+	PsuId      int    `json:"psuId"`
+	OperStatus string `json:"operStatus"` // UP, DOWN, NOT_PRESENT
+	ModelName  string `json:"modelName,omitempty"`
+}
+
+// TemperatureSensorState is a temperature sensor of a unit
+type TemperatureSensorState struct {
+	SlotNumber        int     `json:"slotNumber"`
+	Name              string  `json:"name"`
+	Temperature       float64 `json:"temperature"`
+	WarningThreshold  float64 `json:"warningThreshold"`
+	CriticalThreshold float64 `json:"criticalThreshold"`
+}
+
+// getEnvironment reports three fans, two power supplies and two sensors
+// per stack unit. MOCK_FAILED_FAN and MOCK_FAILED_PSU fail that fan or
+// power supply of unit 1, MOCK_INLET_TEMP sets its inlet temperature.
+func getEnvironment(c *gin.Context) {
+	units, err := strconv.Atoi(os.Getenv("MOCK_STACK_SIZE"))
+	if err != nil || units < 1 {
+		units = 1
+	}
+	failedFan, _ := strconv.Atoi(os.Getenv("MOCK_FAILED_FAN"))
+	failedPSU, _ := strconv.Atoi(os.Getenv("MOCK_FAILED_PSU"))
+	inlet, err := strconv.ParseFloat(os.Getenv("MOCK_INLET_TEMP"), 64)
+	if err != nil {
+		inlet = 27.5
+	}
+
+	fans := []FanState{}
+	psus := []PowerSupplyState{}
+	sensors := []TemperatureSensorState{}
+	for unit := 1; unit <= units; unit++ {
+		for id := 1; id <= 3; id++ {
+			fan := FanState{SlotNumber: unit, FanId: id, OperStatus: "UP", SpeedRpm: 8800 + 150*id}
+			if unit == 1 && id == failedFan {
+				fan.OperStatus, fan.SpeedRpm = "DOWN", 0
+			}
+			fans = append(fans, fan)
+		}
+		for id := 1; id <= 2; id++ {
+			psu := PowerSupplyState{SlotNumber: unit, PsuId: id, OperStatus: "UP", ModelName: "XN-ACPWR-350W-FB"}
+			if unit == 1 && id == failedPSU {
+				psu.OperStatus = "DOWN"
+			}
+			psus = append(psus, psu)
+		}
+		temp := 27.5
+		if unit == 1 {
+			temp = inlet
+		}
+		sensors = append(sensors,
+			TemperatureSensorState{SlotNumber: unit, Name: "Inlet", Temperature: temp, WarningThreshold: 50, CriticalThreshold: 60},
+			TemperatureSensorState{SlotNumber: unit, Name: "CPU", Temperature: 48 + temp - 27.5, WarningThreshold: 85, CriticalThreshold: 95},
+		)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"fans": fans, "powerSupplies": psus, "temperatureSensors": sensors})
+}
+
 func executeCLICommands(c *gin.Context) {
 	var req CLICommandRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -258,6 +1237,9 @@ func executeCLICommands(c *gin.Context) {
 	systemMu.Lock()
 	defer systemMu.Unlock()
 
+	// Interface being configured, set by "interface gigabitEthernet 1/5"
+	currentPort := ""
+
 	for _, cmd := range req.Commands {
 		result := CLICommandResult{
 			Command: cmd,
@@ -272,23 +1254,104 @@ func executeCLICommands(c *gin.Context) {
 			newName = strings.TrimSpace(newName)
 			systemConfig.SysName = newName
 			result.Output = "Hostname changed to " + newName
+			configDirty = true
 			log.Printf("📝 Mock: Hostname changed to: %s", newName)
 		} else if strings.HasPrefix(cmd, "snmp-server location ") {
 			location := strings.TrimPrefix(cmd, "snmp-server location ")
 			location = strings.TrimSpace(location)
 			systemConfig.SysLocation = location
 			result.Output = "SNMP location set to " + location
+			configDirty = true
 			log.Printf("📝 Mock: Location changed to: %s", location)
 		} else if strings.HasPrefix(cmd, "snmp-server contact ") {
 			contact := strings.TrimPrefix(cmd, "snmp-server contact ")
 			contact = strings.TrimSpace(contact)
 			systemConfig.SysContact = contact
 			result.Output = "SNMP contact set to " + contact
+			configDirty = true
 			log.Printf("📝 Mock: Contact changed to: %s", contact)
+		} else if strings.HasPrefix(cmd, "interface gigabitEthernet ") {
+			currentPort = strings.TrimSpace(strings.TrimPrefix(cmd, "interface gigabitEthernet "))
+			if !setPortState(currentPort, nil) {
+				result.Status = "FAILURE"
+				result.Output = "% Invalid port " + currentPort
+				currentPort = ""
+			} else {
+				result.Output = "OK"
+			}
+		} else if currentPort != "" && (cmd == "shutdown" || cmd == "no shutdown" || strings.HasPrefix(cmd, "name ") || strings.HasPrefix(cmd, "no name")) {
+			port := currentPort
+			setPortState(port, func(p *PortState) {
+				switch {
+				case cmd == "shutdown":
+					p.AdminStatus, p.OperStatus = "DOWN", "DOWN"
+				case cmd == "no shutdown":
+					p.AdminStatus, p.OperStatus = "UP", "UP"
+				case strings.HasPrefix(cmd, "no name"):
+					p.Description = ""
+				default:
+					p.Description = strings.Trim(strings.TrimPrefix(cmd, "name "), "\"")
+				}
+			})
+			result.Output = "OK"
+			log.Printf("📝 Mock: Port %s: %s", port, cmd)
+			configDirty = true
+		} else if currentPort != "" && (strings.HasPrefix(cmd, "poe ") || strings.HasPrefix(cmd, "no poe ")) {
+			result.Output, result.Status = poeCommand(currentPort, cmd)
+			if result.Status == "SUCCESS" {
+				log.Printf("📝 Mock: Port %s: %s", currentPort, cmd)
+				configDirty = true
+			}
+		} else if cmd == "exit" && currentPort != "" {
+			currentPort = ""
+			result.Output = "OK"
 		} else if cmd == "configure terminal" || cmd == "exit" {
 			result.Output = "OK"
+		} else if strings.HasPrefix(cmd, "copy ") {
+			result.Output, result.Status = copyToFlash(cmd)
+		} else if strings.HasPrefix(cmd, "software ") || cmd == "show software" {
+			result.Output, result.Status = softwareCommand(cmd)
+		} else if cmd == "reset -y" {
+			next := firmwareVersion
+			if activeVersion != "" && activeVersion != os.Getenv("MOCK_BAD_FIRMWARE") {
+				next = activeVersion
+			}
+			activeVersion = ""
+			firmwareVersion = next
+			rebootUntil = time.Now().Add(rebootDuration())
+			bootedAt = rebootUntil
+			rebootCount++
+			tokenMu.Lock()
+			tokenStore = make(map[string]time.Time)
+			tokenMu.Unlock()
+			result.Output = "Rebooting..."
+			log.Printf("🔄 Mock: Rebooting into %s", next)
+		} else if cmd == "show running-config" {
+			result.Output = runningConfig()
+		} else if output, ok := showOutputs[cmd]; ok {
+			result.Output = output
+		} else if strings.HasPrefix(cmd, "show ") {
+			result.Output = "Command executed"
+		} else if strings.HasPrefix(cmd, "no snmp-server location") {
+			systemConfig.SysLocation = ""
+			result.Output = "SNMP location cleared"
+			configDirty = true
+		} else if strings.HasPrefix(cmd, "no snmp-server contact") {
+			systemConfig.SysContact = ""
+			result.Output = "SNMP contact cleared"
+			configDirty = true
+		} else if cmd == "save config" {
+			configDirty = false
+			result.Output = "Save config to file /intflash/config.cfg successful."
+			log.Printf("💾 Mock: Configuration saved")
+		} else if strings.HasPrefix(cmd, "no ") {
+			removeConfigLine(strings.TrimSpace(strings.TrimPrefix(cmd, "no ")))
+			result.Output = "Command executed"
+			configDirty = true
 		} else {
+			addConfigLine(cmd)
 			result.Output = "Command executed"
+			configDirty = true
 		}
 
 		results = append(results, result)
@@ -70,8 +70,8 @@ export default function Dashboard() {
       
       setStats({
         totalSwitches: switches.length,
-        onlineSwitches: switches.filter(s => s.status === 'online').length,
-        offlineSwitches: switches.filter(s => s.status !== 'online' && s.status !== 'connecting').length,
+        onlineSwitches: switches.filter(s => s.status === 'online' || s.status === 'degraded').length,
+        offlineSwitches: switches.filter(s => !['online', 'degraded', 'connecting'].includes(s.status)).length,
         totalPorts: switches.reduce((acc, s) => acc + (s.system_info?.numPorts || 0), 0),
         digitalTwins: switches.filter(s => s.system_info?.isDigitalTwin).length,
       });
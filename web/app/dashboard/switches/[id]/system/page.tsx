@@ -23,12 +23,27 @@ interface SystemInfo {
   numPorts: number;
 }
 
+interface CertificateInfo {
+  subject: string;
+  issuer: string;
+  serial_number: string;
+  not_before: string;
+  not_after: string;
+  fingerprint_sha256: string;
+  self_signed: boolean;
+}
+
 interface Switch {
   id: number;
   name: string;
   ip_address: string;
   port: number;
   status: string;
+  use_https: boolean;
+  tls_mode: string;
+  cert_fingerprint?: string;
+  certificate?: CertificateInfo;
+  pending_certificate?: CertificateInfo;
   system_info: SystemInfo | null;
 }
 
@@ -109,6 +124,28 @@ export default function SwitchSystemPage() {
     }
   };
 
+  const handleAcceptCertificate = async () => {
+    if (!switchData?.pending_certificate) return;
+    if (!confirm(`Trust the new certificate ${switchData.pending_certificate.fingerprint_sha256}?`)) return;
+
+    setError('');
+    try {
+      const token = localStorage.getItem('token');
+      await axios.post(
+        `/api/v1/switches/${switchId}/certificate/accept`,
+        { fingerprint: switchData.pending_certificate.fingerprint_sha256 },
+        { headers: { Authorization: `Bearer ${token}` } }
+      );
+      fetchSwitch();
+    } catch (err: any) {
+      setError(err.response?.data?.error || 'Failed to accept certificate');
+    }
+  };
+
+  const daysUntil = (date: string) => {
+    return Math.floor((new Date(date).getTime() - Date.now()) / (1000 * 60 * 60 * 24));
+  };
+
   const handleCancel = () => {
     if (switchData?.system_info) {
       setSysName(switchData.system_info.sysName || '');
@@ -273,10 +310,66 @@ export default function SwitchSystemPage() {
                     <p className="text-gray-400 text-sm mb-1">Status</p>
                     <p className="text-white font-medium">{switchData.status}</p>
                   </div>
+                  <div>
+                    <p className="text-gray-400 text-sm mb-1">TLS Verification</p>
+                    <p className="text-white font-medium">{switchData.use_https ? switchData.tls_mode : 'disabled (HTTP)'}</p>
+                  </div>
                 </div>
               </div>
             </>
           )}
+
+          {/* TLS Certificate */}
+          {switchData.use_https && (switchData.certificate || switchData.pending_certificate) && (
+            <div className="bg-gray-800 rounded-xl p-6">
+              <h2 className="text-xl font-semibold text-white mb-4">TLS Certificate</h2>
+              {switchData.pending_certificate && (
+                <div className="mb-4 p-4 bg-yellow-500/10 border border-yellow-500/50 rounded-lg">
+                  <p className="text-yellow-400 font-medium mb-2">The switch presented a certificate that does not match the pinned fingerprint.</p>
+                  <p className="text-gray-300 text-sm mb-1">Subject: {switchData.pending_certificate.subject}</p>
+                  <p className="text-gray-300 text-sm mb-1">Expires: {new Date(switchData.pending_certificate.not_after).toLocaleDateString()}</p>
+                  <p className="text-gray-300 text-sm font-mono break-all mb-3">{switchData.pending_certificate.fingerprint_sha256}</p>
+                  {user.role === 'admin' && (
+                    <button
+                      onClick={handleAcceptCertificate}
+                      className="px-4 py-2 bg-yellow-600 text-white font-semibold rounded-lg hover:opacity-90 transition"
+                    >
+                      Accept New Certificate
+                    </button>
+                  )}
+                </div>
+              )}
+              {switchData.certificate && (
+                <div className="grid grid-cols-2 gap-4">
+                  <div>
+                    <p className="text-gray-400 text-sm mb-1">Subject</p>
+                    <p className="text-white font-medium break-all">{switchData.certificate.subject}</p>
+                  </div>
+                  <div>
+                    <p className="text-gray-400 text-sm mb-1">Issuer</p>
+                    <p className="text-white font-medium break-all">
+                      {switchData.certificate.issuer}
+                      {switchData.certificate.self_signed && <span className="text-gray-400 text-sm"> (self-signed)</span>}
+                    </p>
+                  </div>
+                  <div>
+                    <p className="text-gray-400 text-sm mb-1">Valid From</p>
+                    <p className="text-white font-medium">{new Date(switchData.certificate.not_before).toLocaleDateString()}</p>
+                  </div>
+                  <div>
+                    <p className="text-gray-400 text-sm mb-1">Expires</p>
+                    <p className={`font-medium ${daysUntil(switchData.certificate.not_after) < 30 ? 'text-red-400' : 'text-white'}`}>
+                      {new Date(switchData.certificate.not_after).toLocaleDateString()} ({daysUntil(switchData.certificate.not_after)} days)
+                    </p>
+                  </div>
+                  <div className="col-span-2">
+                    <p className="text-gray-400 text-sm mb-1">SHA-256 Fingerprint</p>
+                    <p className="text-white font-medium font-mono text-sm break-all">{switchData.certificate.fingerprint_sha256}</p>
+                  </div>
+                </div>
+              )}
+            </div>
+          )}
         </div>
       </main>
     </div>
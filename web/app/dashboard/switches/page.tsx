@@ -20,6 +20,7 @@ interface SystemInfo {
   firmwareVersion: string;
   numPorts: number;
   isDigitalTwin: boolean;
+  isConfigDirty?: boolean;
 }
 
 interface Switch {
@@ -71,8 +72,18 @@ export default function SwitchesPage() {
     }
 
     fetchSwitches();
-    const interval = setInterval(fetchSwitches, 10000);
-    return () => clearInterval(interval);
+
+    // Refresh as soon as the backend reports a change; the interval is only
+    // a fallback for when the event stream is unavailable
+    const events = new EventSource(`/api/v1/events?access_token=${encodeURIComponent(token)}`);
+    ['switch.status', 'switch.synced', 'switch.config_changed', 'switch.added', 'switch.deleted'].forEach((type) => {
+      events.addEventListener(type, () => fetchSwitches());
+    });
+    const interval = setInterval(fetchSwitches, 60000);
+    return () => {
+      events.close();
+      clearInterval(interval);
+    };
   }, [router]);
 
   useEffect(() => {
@@ -122,6 +133,18 @@ export default function SwitchesPage() {
     }
   };
 
+  const handleSaveConfig = async (id: number) => {
+    try {
+      const token = localStorage.getItem('token');
+      await axios.post(`/api/v1/switches/${id}/save-config`, {}, {
+        headers: { Authorization: `Bearer ${token}` }
+      });
+      fetchSwitches();
+    } catch (err) {
+      console.error('Failed to save config:', err);
+    }
+  };
+
   const handleLogout = () => {
     localStorage.removeItem('token');
     localStorage.removeItem('user');
@@ -136,12 +159,14 @@ export default function SwitchesPage() {
   const getStatusBadge = (status: string) => {
     const styles: Record<string, string> = {
       online: 'bg-green-500/20 text-green-400',
+      degraded: 'bg-orange-500/20 text-orange-400',
       connecting: 'bg-yellow-500/20 text-yellow-400',
       auth_failed: 'bg-red-500/20 text-red-400',
       error: 'bg-red-500/20 text-red-400',
     };
     const labels: Record<string, string> = {
       online: 'Online',
+      degraded: 'Degraded',
       connecting: 'Connecting',
       auth_failed: 'Auth Failed',
       error: 'Error',
@@ -296,6 +321,7 @@ export default function SwitchesPage() {
           >
             <option value="all">All Status</option>
             <option value="online">Online</option>
+            <option value="degraded">Degraded</option>
             <option value="connecting">Connecting</option>
             <option value="auth_failed">Auth Failed</option>
             <option value="error">Error</option>
@@ -466,7 +492,19 @@ export default function SwitchesPage() {
                       <td className="px-6 py-4 text-gray-300">{sw.ip_address}:{sw.port}</td>
                       <td className="px-6 py-4 text-gray-300">{sw.system_info?.modelName || '-'}</td>
                       <td className="px-6 py-4 text-gray-300">{sw.system_info?.firmwareVersion || '-'}</td>
-                      <td className="px-6 py-4">{getStatusBadge(sw.status)}</td>
+                      <td className="px-6 py-4">
+                        <div className="flex items-center gap-2">
+                          {getStatusBadge(sw.status)}
+                          {sw.system_info?.isConfigDirty && (
+                            <span
+                              className="px-2 py-1 text-xs font-semibold rounded-full bg-orange-500/20 text-orange-400"
+                              title="The running config has changes that are lost on reboot"
+                            >
+                              Unsaved
+                            </span>
+                          )}
+                        </div>
+                      </td>
                       <td className="px-6 py-4 text-gray-500 text-sm">
                         {sw.last_sync ? new Date(sw.last_sync).toLocaleTimeString() : '-'}
                       </td>
@@ -501,6 +539,20 @@ export default function SwitchesPage() {
                                 </svg>
                                 Refresh
                               </button>
+                              {sw.system_info?.isConfigDirty && (
+                                <button
+                                  onClick={() => {
+                                    handleSaveConfig(sw.id);
+                                    setOpenMenuId(null);
+                                  }}
+                                  className="w-full px-4 py-2 text-left text-gray-300 hover:bg-gray-600 hover:text-white flex items-center gap-2"
+                                >
+                                  <svg className="w-4 h-4" fill="none" stroke="currentColor" viewBox="0 0 24 24">
+                                    <path strokeLinecap="round" strokeLinejoin="round" strokeWidth={2} d="M8 7H5a2 2 0 00-2 2v9a2 2 0 002 2h14a2 2 0 002-2V9a2 2 0 00-2-2h-3m-1 4l-3 3m0 0l-3-3m3 3V4" />
+                                  </svg>
+                                  Save Config
+                                </button>
+                              )}
                               <button
                                 onClick={() => {
                                   setEditSwitch(sw);
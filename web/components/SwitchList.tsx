@@ -66,6 +66,8 @@ export default function SwitchList() {
     switch (status) {
       case 'online':
         return 'bg-green-500/20 text-green-400';
+      case 'degraded':
+        return 'bg-orange-500/20 text-orange-400';
       case 'connecting':
         return 'bg-yellow-500/20 text-yellow-400';
       case 'auth_failed':
@@ -81,6 +83,8 @@ export default function SwitchList() {
     switch (status) {
       case 'online':
         return 'Online';
+      case 'degraded':
+        return 'Degraded';
       case 'connecting':
         return 'Connecting...';
       case 'auth_failed':